@@ -0,0 +1,66 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/martialanouman/personal-library/internal/helpers"
+	"github.com/martialanouman/personal-library/internal/middleware"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+type AuthorHandler struct {
+	store  store.AuthorStore
+	logger *log.Logger
+}
+
+func NewAuthorHandler(store store.AuthorStore, logger *log.Logger) AuthorHandler {
+	return AuthorHandler{store: store, logger: logger}
+}
+
+func (h *AuthorHandler) HandleGetAuthors(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	authors, err := h.store.GetUserAuthors(user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting authors %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"authors": authors})
+}
+
+// HandleGetAuthor returns the author with the books of the current user they
+// took part in. Authors are shared between users, so an author without any
+// book in the user's library is reported as not found.
+func (h *AuthorHandler) HandleGetAuthor(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid author id"})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	author, err := h.store.GetAuthorById(id, user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting author by id %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	if author == nil || author.BooksCount == 0 {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "author not found"})
+		return
+	}
+
+	books, err := h.store.GetAuthorBooks(author.ID, user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting author books %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"author": author, "books": books})
+}
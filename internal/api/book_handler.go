@@ -2,37 +2,132 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
 	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
 	"github.com/martialanouman/personal-library/internal/helpers"
+	"github.com/martialanouman/personal-library/internal/isbn"
+	"github.com/martialanouman/personal-library/internal/markdown"
 	"github.com/martialanouman/personal-library/internal/middleware"
 	"github.com/martialanouman/personal-library/internal/services"
 	"github.com/martialanouman/personal-library/internal/store"
 )
 
+const (
+	unknownAuthor      = "Unknown"
+	ratingErrorMessage = "rating must be between 0.5 and 5, in steps of 0.5"
+	maxReviewLength    = 100000
+
+	maxBatchOperations = 500
+	maxBulkBooks       = 1000
+
+	batchModeAtomic     = "atomic"
+	batchModeBestEffort = "best_effort"
+
+	batchStatusFailed  = "failed"
+	batchStatusSkipped = "skipped"
+)
+
+// batchStatuses are the statuses of the batch operations that succeeded.
+var batchStatuses = map[string]string{
+	store.BookOperationCreate: "created",
+	store.BookOperationUpdate: "updated",
+	store.BookOperationDelete: "deleted",
+}
+
+// isValidRating accepts half-star ratings from 0.5 to 5.
+func isValidRating(rating float64) bool {
+	return rating >= 0.5 && rating <= 5 && rating*2 == math.Trunc(rating*2)
+}
+
 type BookHandler struct {
-	store   store.BookStore
-	bookApi *services.BookAPIClient
-	logger  *log.Logger
+	store       store.BookStore
+	seriesStore store.SeriesStore
+	shelfStore  store.ShelfStore
+	bigBook     *services.BigBookProvider
+	metadata    *services.MetadataChain
+	logger      *log.Logger
+}
+
+type authorRequest struct {
+	Name string `json:"name"`
+	Role string `json:"role,omitempty"`
+}
+
+// validateAuthors checks the contributors of a payload and defaults their
+// role to author.
+func validateAuthors(authors []authorRequest, errorMessages map[string]string) {
+	for i := range authors {
+		if authors[i].Role == "" {
+			authors[i].Role = store.AuthorRoleAuthor
+		}
+
+		if store.NormalizeAuthorName(authors[i].Name) == "" {
+			errorMessages["authors"] = "authors must all have a name"
+		} else if len(authors[i].Name) > 255 {
+			errorMessages["authors"] = "author names must be at most 255 characters"
+		}
+
+		if !slices.Contains(store.AuthorRoles, authors[i].Role) {
+			errorMessages["authors"] = "author roles must be one of: author, translator, illustrator, editor"
+		}
+	}
+}
+
+func toBookAuthors(authors []authorRequest) []store.BookAuthor {
+	bookAuthors := make([]store.BookAuthor, 0, len(authors))
+	for _, author := range authors {
+		bookAuthors = append(bookAuthors, store.BookAuthor{Name: author.Name, Role: author.Role})
+	}
+
+	return bookAuthors
+}
+
+func isMainAuthor(author store.BookAuthor) bool {
+	return author.Role == store.AuthorRoleAuthor
+}
+
+// withMainAuthor replaces the main authors of the list with the given name,
+// keeping the other contributors after it.
+func withMainAuthor(name string, authors []store.BookAuthor) []store.BookAuthor {
+	result := []store.BookAuthor{{Name: name, Role: store.AuthorRoleAuthor}}
+	for _, author := range authors {
+		if !isMainAuthor(author) {
+			result = append(result, author)
+		}
+	}
+
+	return result
 }
 
 type createBookRequest struct {
-	Title        string  `json:"title"`
-	Author       string  `json:"author"`
-	Isbn         *string `json:"isbn,omitempty"`
-	Description  *string `json:"description,omitempty"`
-	CoverUrl     *string `json:"cover_url,omitempty"`
-	Genre        *string `json:"genre,omitempty"`
-	Status       string  `json:"status"`
-	Rating       byte    `json:"rating"`
-	Notes        *string `json:"notes,omitempty"`
-	DateStarted  *string `json:"date_started,omitempty"`
-	DateFinished *string `json:"date_finished,omitempty"`
-	DateAdded    *string `json:"date_added,omitempty"`
+	Title          string          `json:"title"`
+	Author         string          `json:"author"`
+	Authors        []authorRequest `json:"authors,omitempty"`
+	Isbn           *string         `json:"isbn,omitempty"`
+	Description    *string         `json:"description,omitempty"`
+	CoverUrl       *string         `json:"cover_url,omitempty"`
+	Genre          *string         `json:"genre,omitempty"`
+	Status         string          `json:"status"`
+	Rating         float64         `json:"rating"`
+	Notes          *string         `json:"notes,omitempty"`
+	Review         *string         `json:"review,omitempty"`
+	DateStarted    *string         `json:"date_started,omitempty"`
+	DateFinished   *string         `json:"date_finished,omitempty"`
+	DateAdded      *string         `json:"date_added,omitempty"`
+	SeriesID       *string         `json:"series_id,omitempty"`
+	SeriesPosition *float64        `json:"series_position,omitempty"`
+	PageCount      *int            `json:"page_count,omitempty"`
 }
 
 func (r *createBookRequest) validate() map[string]string {
@@ -42,23 +137,28 @@ func (r *createBookRequest) validate() map[string]string {
 		errorMessages["title"] = "title is required"
 	}
 
-	if r.Author == "" {
+	validateAuthors(r.Authors, errorMessages)
+
+	if r.Author == "" && !slices.ContainsFunc(toBookAuthors(r.Authors), isMainAuthor) {
 		errorMessages["author"] = "author is required"
 	}
 
 	if r.Status == "" {
 		errorMessages["status"] = "status is required"
 	} else {
-		validStatuses := []string{"to_read", "reading", "read"}
-		isValidStatus := slices.Contains(validStatuses, r.Status)
+		isValidStatus := slices.Contains(store.BookStatuses, r.Status)
 
 		if !isValidStatus {
 			errorMessages["status"] = "status must be one of: to_read, reading, read"
 		}
 	}
 
-	if r.Rating < 1 || r.Rating > 5 {
-		errorMessages["rating"] = "rating must be between 1 and 5"
+	if !isValidRating(r.Rating) {
+		errorMessages["rating"] = ratingErrorMessage
+	}
+
+	if r.Review != nil && len(*r.Review) > maxReviewLength {
+		errorMessages["review"] = "review must be at most 100000 characters"
 	}
 
 	if r.DateAdded != nil {
@@ -82,77 +182,115 @@ func (r *createBookRequest) validate() map[string]string {
 		}
 	}
 
+	if r.SeriesPosition != nil {
+		if r.SeriesID == nil {
+			errorMessages["series_position"] = "series_position requires a series_id"
+		} else if *r.SeriesPosition <= 0 {
+			errorMessages["series_position"] = "series_position must be greater than 0"
+		}
+	}
+
+	if r.PageCount != nil && *r.PageCount < 1 {
+		errorMessages["page_count"] = "page_count must be greater than 0"
+	}
+
+	normalizeIsbn(r.Isbn, errorMessages)
+
 	return errorMessages
 }
 
+// toBook builds the book without its status and reading dates, which are set
+// by applying statusChange to it.
 func (r *createBookRequest) toBook() *store.Book {
-	var (
-		dateAdded    time.Time = time.Now()
-		dateFinished *time.Time
-		DateStarted  *time.Time
-	)
+	var dateAdded time.Time = time.Now()
 	if r.DateAdded != nil {
 		parsedDate, _ := time.Parse(time.DateOnly, *r.DateAdded)
 		dateAdded = parsedDate
 	}
 
-	if r.DateFinished != nil {
-		parsedDate, _ := time.Parse(time.DateOnly, *r.DateFinished)
-		dateFinished = &parsedDate
+	authors := toBookAuthors(r.Authors)
+	if !slices.ContainsFunc(authors, isMainAuthor) {
+		authors = withMainAuthor(r.Author, authors)
 	}
 
-	if r.DateStarted != nil {
-		parsedDate, _ := time.Parse(time.DateOnly, *r.DateStarted)
-		DateStarted = &parsedDate
+	author := r.Author
+	if author == "" {
+		author = store.AuthorsDisplayName(authors)
 	}
 
 	return &store.Book{
-		Title:        r.Title,
-		Author:       r.Author,
-		Isbn:         r.Isbn,
-		Description:  r.Description,
-		CoverUrl:     r.CoverUrl,
-		Genre:        r.Genre,
-		Status:       r.Status,
-		Rating:       r.Rating,
-		Notes:        r.Notes,
-		DateStarted:  DateStarted,
-		DateFinished: dateFinished,
-		DateAdded:    dateAdded,
+		Title:          r.Title,
+		Author:         author,
+		Authors:        authors,
+		Isbn:           r.Isbn,
+		Description:    r.Description,
+		CoverUrl:       r.CoverUrl,
+		Genre:          r.Genre,
+		Rating:         r.Rating,
+		Notes:          r.Notes,
+		Review:         r.Review,
+		DateAdded:      dateAdded,
+		SeriesID:       r.SeriesID,
+		SeriesPosition: r.SeriesPosition,
+		PageCount:      r.PageCount,
+	}
+}
+
+func (r *createBookRequest) statusChange() services.BookStatusChange {
+	return services.BookStatusChange{
+		Status:       &r.Status,
+		DateStarted:  parseOptionalDate(r.DateStarted),
+		DateFinished: parseOptionalDate(r.DateFinished),
 	}
 }
 
 type updateBookRequest struct {
-	Title        *string `json:"title,omitempty"`
-	Author       *string `json:"author,omitempty"`
-	Isbn         *string `json:"isbn,omitempty"`
-	Description  *string `json:"description,omitempty"`
-	CoverUrl     *string `json:"cover_url,omitempty"`
-	Genre        *string `json:"genre,omitempty"`
-	Status       *string `json:"status,omitempty"`
-	Rating       *byte   `json:"rating,omitempty"`
-	Notes        *string `json:"notes,omitempty"`
-	DateStarted  *string `json:"date_started,omitempty"`
-	DateFinished *string `json:"date_finished,omitempty"`
-	DateAdded    *string `json:"date_added,omitempty"`
+	Title          *string          `json:"title,omitempty"`
+	Author         *string          `json:"author,omitempty"`
+	Authors        []authorRequest  `json:"authors,omitempty"`
+	Isbn           *string          `json:"isbn,omitempty"`
+	Description    *string          `json:"description,omitempty"`
+	CoverUrl       *string          `json:"cover_url,omitempty"`
+	Genre          *string          `json:"genre,omitempty"`
+	Status         *string          `json:"status,omitempty"`
+	Rating         *float64         `json:"rating,omitempty"`
+	Notes          *string          `json:"notes,omitempty"`
+	Review         *string          `json:"review,omitempty"`
+	DateStarted    *string          `json:"date_started,omitempty"`
+	DateFinished   *string          `json:"date_finished,omitempty"`
+	DateAdded      *string          `json:"date_added,omitempty"`
+	SeriesID       nullable[string] `json:"series_id"`
+	SeriesPosition *float64         `json:"series_position,omitempty"`
+	PageCount      *int             `json:"page_count,omitempty"`
 }
 
 func (r *updateBookRequest) validate() map[string]string {
 	errorMessages := make(map[string]string)
 
+	if r.Author != nil && store.NormalizeAuthorName(*r.Author) == "" {
+		errorMessages["author"] = "author cannot be empty"
+	}
+
+	validateAuthors(r.Authors, errorMessages)
+
+	if r.Authors != nil && r.Author == nil && !slices.ContainsFunc(toBookAuthors(r.Authors), isMainAuthor) {
+		errorMessages["authors"] = "authors must contain at least one author"
+	}
+
 	if r.Status != nil {
-		validStatuses := []string{"to_read", "reading", "read"}
-		isValidStatus := slices.Contains(validStatuses, *r.Status)
+		isValidStatus := slices.Contains(store.BookStatuses, *r.Status)
 
 		if !isValidStatus {
 			errorMessages["status"] = "status must be one of: to_read, reading, read"
 		}
 	}
 
-	if r.Rating != nil {
-		if *r.Rating < 1 || *r.Rating > 5 {
-			errorMessages["rating"] = "rating must be between 1 and 5"
-		}
+	if r.Rating != nil && !isValidRating(*r.Rating) {
+		errorMessages["rating"] = ratingErrorMessage
+	}
+
+	if r.Review != nil && len(*r.Review) > maxReviewLength {
+		errorMessages["review"] = "review must be at most 100000 characters"
 	}
 
 	if r.DateAdded != nil {
@@ -176,15 +314,43 @@ func (r *updateBookRequest) validate() map[string]string {
 		}
 	}
 
+	if r.SeriesPosition != nil {
+		if r.SeriesID.Set && r.SeriesID.Value == nil {
+			errorMessages["series_position"] = "series_position requires a series_id"
+		} else if *r.SeriesPosition <= 0 {
+			errorMessages["series_position"] = "series_position must be greater than 0"
+		}
+	}
+
+	if r.PageCount != nil && *r.PageCount < 1 {
+		errorMessages["page_count"] = "page_count must be greater than 0"
+	}
+
+	normalizeIsbn(r.Isbn, errorMessages)
+
 	return errorMessages
 }
 
+// toBook applies the payload to the book, except for its status and reading
+// dates, which are changed by applying statusChange to it.
 func (r *updateBookRequest) toBook(book *store.Book) *store.Book {
 	if r.Title != nil {
 		book.Title = *r.Title
 	}
 
-	if r.Author != nil {
+	switch {
+	case r.Authors != nil:
+		book.Authors = toBookAuthors(r.Authors)
+		book.Author = store.AuthorsDisplayName(book.Authors)
+		if r.Author != nil {
+			if !slices.ContainsFunc(book.Authors, isMainAuthor) {
+				book.Authors = withMainAuthor(*r.Author, book.Authors)
+			}
+			book.Author = *r.Author
+		}
+	case r.Author != nil:
+		// Only the main author changed: keep translators, illustrators and editors.
+		book.Authors = withMainAuthor(*r.Author, book.Authors)
 		book.Author = *r.Author
 	}
 
@@ -194,20 +360,18 @@ func (r *updateBookRequest) toBook(book *store.Book) *store.Book {
 
 	if r.Description != nil {
 		book.Description = r.Description
+		book.MetadataSources = store.WithMetadataSource(book.MetadataSources, store.MetadataSourceUser, "description")
 	}
 
 	if r.CoverUrl != nil {
 		book.CoverUrl = r.CoverUrl
+		book.MetadataSources = store.WithMetadataSource(book.MetadataSources, store.MetadataSourceUser, "cover_url")
 	}
 
 	if r.Genre != nil {
 		book.Genre = r.Genre
 	}
 
-	if r.Status != nil {
-		book.Status = *r.Status
-	}
-
 	if r.Rating != nil {
 		book.Rating = *r.Rating
 	}
@@ -216,40 +380,134 @@ func (r *updateBookRequest) toBook(book *store.Book) *store.Book {
 		book.Notes = r.Notes
 	}
 
+	if r.Review != nil {
+		book.Review = r.Review
+	}
+
 	if r.DateAdded != nil {
 		parsedDate, _ := time.Parse(time.DateOnly, *r.DateAdded)
 		book.DateAdded = parsedDate
 	}
 
-	if r.DateStarted != nil {
-		parsedDate, _ := time.Parse(time.DateOnly, *r.DateStarted)
-		book.DateStarted = &parsedDate
+	if r.SeriesID.Set {
+		book.SeriesID = r.SeriesID.Value
+		if r.SeriesID.Value == nil {
+			book.SeriesPosition = nil
+		}
 	}
 
-	if r.DateFinished != nil {
-		parsedDate, _ := time.Parse(time.DateOnly, *r.DateFinished)
-		book.DateFinished = &parsedDate
+	if r.SeriesPosition != nil {
+		book.SeriesPosition = r.SeriesPosition
+	}
+
+	if r.PageCount != nil {
+		book.PageCount = r.PageCount
+		book.MetadataSources = store.WithMetadataSource(book.MetadataSources, store.MetadataSourceUser, "page_count")
 	}
 
 	return book
 }
 
-func NewBookHandler(store store.BookStore, bookApi *services.BookAPIClient, logger *log.Logger) BookHandler {
-	return BookHandler{store: store, bookApi: bookApi, logger: logger}
+func (r *updateBookRequest) statusChange() services.BookStatusChange {
+	return services.BookStatusChange{
+		Status:       r.Status,
+		DateStarted:  parseOptionalDate(r.DateStarted),
+		DateFinished: parseOptionalDate(r.DateFinished),
+	}
+}
+
+// normalizeIsbn replaces the ISBN-10 or ISBN-13 with its ISBN-13 form, which
+// is the one stored, or records a validation error.
+func normalizeIsbn(value *string, errorMessages map[string]string) {
+	if value == nil {
+		return
+	}
+
+	normalized, err := isbn.Parse(*value)
+	if err != nil {
+		errorMessages["isbn"] = "isbn must be a valid ISBN-10 or ISBN-13"
+		return
+	}
+
+	*value = normalized
+}
+
+// parseOptionalDate parses a YYYY-MM-DD date validated beforehand.
+func parseOptionalDate(value *string) *time.Time {
+	if value == nil {
+		return nil
+	}
+
+	parsedDate, _ := time.Parse(time.DateOnly, *value)
+
+	return &parsedDate
+}
+
+// wantsHTML tells whether the client asked for reviews rendered to HTML with
+// the render=html query parameter.
+func wantsHTML(r *http.Request) bool {
+	return r.URL.Query().Get("render") == "html"
+}
+
+func renderReview(review *string) *string {
+	if review == nil {
+		return nil
+	}
+
+	rendered := markdown.ToHTML(*review)
+
+	return &rendered
+}
+
+func bookFiltersFromQuery(q url.Values) store.BookFilters {
+	var filters store.BookFilters
+
+	if shelf := q.Get("shelf"); shelf != "" {
+		filters.ShelfId = &shelf
+	}
+
+	filters.Tags, filters.TagMode = tagFiltersFromQuery(q)
+
+	if format := q.Get("format"); format != "" {
+		filters.Format = &format
+	}
+
+	if location := strings.TrimSpace(q.Get("location")); location != "" {
+		filters.Location = &location
+	}
+
+	return filters
+}
+
+// NewBookHandler creates the book handler. bigBook is nil when Big Book API is
+// not configured, in which case imports by Big Book id are unavailable.
+func NewBookHandler(store store.BookStore, seriesStore store.SeriesStore, shelfStore store.ShelfStore, bigBook *services.BigBookProvider, metadata *services.MetadataChain, logger *log.Logger) BookHandler {
+	return BookHandler{store: store, seriesStore: seriesStore, shelfStore: shelfStore, bigBook: bigBook, metadata: metadata, logger: logger}
 }
 
 func (h *BookHandler) HandleGetBooks(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
 	pagination := middleware.GetPagination(r)
+	filters := bookFiltersFromQuery(r.URL.Query())
+
+	if filters.ShelfId != nil && !uuidPattern.MatchString(*filters.ShelfId) {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "shelf must be a valid shelf id"})
+		return
+	}
+
+	if filters.Format != nil && !slices.Contains(store.CopyFormats, *filters.Format) {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "format must be one of: " + strings.Join(store.CopyFormats, ", ")})
+		return
+	}
 
-	books, err := h.store.GetBooks(user.ID, pagination.Page, pagination.Take)
+	books, err := h.store.GetBooks(user.ID, filters, pagination.Page, pagination.Take)
 	if err != nil {
 		h.logger.Printf("ERROR: getting books %v", err)
 		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
 		return
 	}
 
-	count, err := h.store.GetBooksCount(user.ID)
+	count, err := h.store.GetBooksCount(user.ID, filters)
 	if err != nil {
 		h.logger.Printf("ERROR: getting books count %v", err)
 		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
@@ -273,58 +531,51 @@ func (h *BookHandler) HandlerCreateBook(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !checkUserSeries(w, r, h.seriesStore, h.logger, req.SeriesID) {
+		return
+	}
+
 	user := middleware.GetUser(r)
 	book := req.toBook()
 	book.UserId = user.ID
 
+	if validationErrors := services.ApplyBookStatusChange(book, req.statusChange(), time.Now()); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
 	if err := h.store.CreateBook(book); err != nil {
+		if store.IsUniqueViolation(err) {
+			helpers.WriteJson(w, http.StatusConflict, helpers.Envelop{"error": "a book with this isbn is already in your library"})
+			return
+		}
+
 		h.logger.Printf("ERROR: creating book %v", err)
 		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
 		return
 	}
 
+	w.Header().Set("ETag", versionETag(book.Version))
 	helpers.WriteJson(w, http.StatusCreated, helpers.Envelop{"book": book})
 }
 
+// HandleGetBookById answers with a 304 when the If-None-Match header lists
+// the ETag of the current version of the book.
 func (h *BookHandler) HandleGetBookById(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	if id == "" {
-		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid book id"})
-		return
-	}
-
-	book, err := h.store.GetBookById(id)
-	if err != nil {
-		h.logger.Printf("ERROR: getting book by id %v", err)
-		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
-		return
-	}
-
+	book := getUserBook(w, r, h.store, h.logger)
 	if book == nil {
-		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "book not found"})
 		return
 	}
 
-	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"book": book})
-}
-
-func (h *BookHandler) HandleGetBook(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	if id == "" {
-		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid book id"})
+	etag := versionETag(book.Version)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
-	book, err := h.store.GetBookById(id)
-	if err != nil {
-		h.logger.Printf("ERROR: getting book by id %v", err)
-		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
-		return
-	}
-
-	if book == nil {
-		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "book not found"})
-		return
+	if wantsHTML(r) {
+		book.ReviewHTML = renderReview(book.Review)
 	}
 
 	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"book": book})
@@ -333,12 +584,6 @@ func (h *BookHandler) HandleGetBook(w http.ResponseWriter, r *http.Request) {
 func (h *BookHandler) HandleUpdateBook(w http.ResponseWriter, r *http.Request) {
 	var req updateBookRequest
 
-	id := chi.URLParam(r, "id")
-	if id == "" {
-		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid book id"})
-		return
-	}
-
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.logger.Printf("ERROR: decoding update book request %v", err)
 		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
@@ -350,92 +595,667 @@ func (h *BookHandler) HandleUpdateBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	book, err := h.store.GetBookById(id)
-	if err != nil {
-		h.logger.Printf("ERROR: getting book by id %v", err)
-		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+	book := getUserBook(w, r, h.store, h.logger)
+	if book == nil {
 		return
 	}
 
-	if book == nil {
-		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "book not found"})
+	if !checkIfMatch(w, r, versionETag(book.Version)) {
+		return
+	}
+
+	if !checkUserSeries(w, r, h.seriesStore, h.logger, req.SeriesID.Value) {
 		return
 	}
 
 	updatedBook := req.toBook(book)
+	if validationErrors := services.ApplyBookStatusChange(updatedBook, req.statusChange(), time.Now()); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	if err := h.store.UpdateBook(updatedBook, revisionActor(r)); err != nil {
+		if store.IsUniqueViolation(err) {
+			helpers.WriteJson(w, http.StatusConflict, helpers.Envelop{"error": "a book with this isbn is already in your library"})
+			return
+		}
+
+		if errors.Is(err, store.ErrVersionConflict) {
+			writeVersionConflict(w, r)
+			return
+		}
 
-	if err := h.store.UpdateBook(updatedBook); err != nil {
 		h.logger.Printf("ERROR: updating book %v", err)
 		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
 		return
 	}
 
+	w.Header().Set("ETag", versionETag(updatedBook.Version))
 	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"book": updatedBook})
 }
 
 func (h *BookHandler) HandleDeleteBook(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	if id == "" {
-		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid book id"})
+	book := getUserBook(w, r, h.store, h.logger)
+	if book == nil {
 		return
 	}
 
-	book, err := h.store.GetBookById(id)
-	if err != nil {
-		h.logger.Printf("ERROR: getting book by id %v", err)
+	if err := h.store.DeleteBook(book.ID); err != nil {
+		h.logger.Printf("ERROR: deleting book %v", err)
 		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
 		return
 	}
 
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *BookHandler) HandleGetBookHistory(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.store, h.logger)
 	if book == nil {
-		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "book not found"})
 		return
 	}
 
-	if err := h.store.DeleteBook(id); err != nil {
-		h.logger.Printf("ERROR: deleting book %v", err)
+	history, err := h.store.GetBookStatusHistory(book.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting book status history %v", err)
 		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"history": history})
 }
 
-func (h *BookHandler) HandleAddBookByISBN(w http.ResponseWriter, r *http.Request) {
+func (h *BookHandler) HandleAddBookByBigBookId(w http.ResponseWriter, r *http.Request) {
 	bbId := chi.URLParam(r, "bbId")
 	if bbId == "" {
-		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid isbn"})
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid big book id"})
+		return
+	}
+
+	if h.bigBook == nil {
+		helpers.WriteJson(w, http.StatusServiceUnavailable, helpers.Envelop{"error": "big book api is not configured"})
+		return
+	}
+
+	bookInfo, err := h.bigBook.GetBookByBigBookId(r.Context(), bbId)
+	if err != nil {
+		writeMetadataError(w, h.logger, err, "book not found")
+		return
+	}
+
+	metadata := bookInfo.Metadata()
+	h.metadata.Complete(r.Context(), metadata)
+
+	user := middleware.GetUser(r)
+	book, err := bookFromMetadata(h.seriesStore, user.ID, metadata)
+	if err != nil {
+		h.logger.Printf("ERROR: finding or creating series %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
 		return
 	}
 
-	bookInfo, err := h.bookApi.GetBookByBigBookId(r.Context(), bbId)
+	err = h.store.CreateBook(&book)
 	if err != nil {
-		h.logger.Printf("ERROR: fetching book info from external API %v", err)
+		if store.IsUniqueViolation(err) {
+			helpers.WriteJson(w, http.StatusConflict, helpers.Envelop{"error": "a book with this isbn is already in your library"})
+			return
+		}
+
+		h.logger.Printf("ERROR: creating book %v", err)
 		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
 		return
 	}
 
+	helpers.WriteJson(w, http.StatusCreated, helpers.Envelop{"book": book})
+}
+
+// HandleAddBookByISBN adds the book with the given ISBN-10 or ISBN-13 to the
+// library, looking its details up through the metadata providers.
+func (h *BookHandler) HandleAddBookByISBN(w http.ResponseWriter, r *http.Request) {
+	isbn13, err := isbn.Parse(chi.URLParam(r, "isbn"))
+	if err != nil {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "isbn must be a valid ISBN-10 or ISBN-13"})
+		return
+	}
+
+	metadata, err := h.metadata.LookupISBN(r.Context(), isbn13)
+	if err != nil {
+		writeMetadataError(w, h.logger, err, "no book found for this isbn")
+		return
+	}
+
 	user := middleware.GetUser(r)
-	rating := int(bookInfo.Rating.Average * 5)
+	book, err := bookFromMetadata(h.seriesStore, user.ID, metadata)
+	if err != nil {
+		h.logger.Printf("ERROR: finding or creating series %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	// Providers may answer with another edition: keep the ISBN asked for.
+	book.Isbn = &isbn13
+
+	if err := h.store.CreateBook(&book); err != nil {
+		if store.IsUniqueViolation(err) {
+			helpers.WriteJson(w, http.StatusConflict, helpers.Envelop{"error": "a book with this isbn is already in your library"})
+			return
+		}
+
+		h.logger.Printf("ERROR: creating book %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusCreated, helpers.Envelop{"book": book, "sources": metadata.Sources})
+}
+
+// bookFromMetadata builds a book to read from the metadata of an external
+// catalog, creating its series if needed.
+func bookFromMetadata(seriesStore store.SeriesStore, userId string, metadata *services.BookMetadata) (store.Book, error) {
+	// Round the rating to the closest half star. A book no one rated stays
+	// unrated.
+	rating := math.Round(metadata.Rating*2) / 2
+
+	authors := make([]store.BookAuthor, 0, len(metadata.Authors))
+	for _, name := range metadata.Authors {
+		if store.NormalizeAuthorName(name) != "" {
+			authors = append(authors, store.BookAuthor{Name: name, Role: store.AuthorRoleAuthor})
+		}
+	}
+
+	author := store.AuthorsDisplayName(authors)
+	if author == "" {
+		author = unknownAuthor
+	}
 
 	book := store.Book{
-		Title:       bookInfo.Title,
-		UserId:      user.ID,
-		Author:      bookInfo.Authors[0].Name,
-		Isbn:        &bookInfo.Identifiers.Isbn13,
-		CoverUrl:    &bookInfo.Image,
-		Description: &bookInfo.Description,
-		Status:      "to_read",
-		Rating:      byte(rating),
+		Title:       metadata.Title,
+		UserId:      userId,
+		Author:      author,
+		Authors:     authors,
+		PageCount:   optionalInt(metadata.PageCount),
+		CoverUrl:    optionalString(metadata.CoverURL),
+		Description: optionalString(metadata.Description),
+		Status:      store.BookStatusToRead,
+		Rating:      rating,
 		DateAdded:   time.Now(),
 	}
 
-	err = h.store.CreateBook(&book)
+	for _, candidate := range []string{metadata.Isbn13, metadata.Isbn10} {
+		if normalized, err := isbn.Parse(candidate); err == nil {
+			book.Isbn = &normalized
+			break
+		}
+	}
+
+	if bigBookId, err := strconv.ParseInt(metadata.BigBookID, 10, 64); err == nil {
+		book.BigBookID = &bigBookId
+	}
+
+	// Remember which provider filled the fields that enrichment may refresh.
+	for _, field := range []string{"description", "cover_url", "page_count", "big_book_id"} {
+		if source, ok := metadata.Sources[field]; ok {
+			book.MetadataSources = store.WithMetadataSource(book.MetadataSources, source, field)
+		}
+	}
+
+	if metadata.Series != nil && metadata.Series.Name != "" {
+		series, err := seriesStore.FindOrCreateSeries(userId, metadata.Series.Name, optionalInt(metadata.Series.TotalVolumes))
+		if err != nil {
+			return book, err
+		}
+
+		book.SeriesID = &series.ID
+		if metadata.Series.Position > 0 {
+			book.SeriesPosition = &metadata.Series.Position
+		}
+	}
+
+	return book, nil
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
+
+func optionalInt(value int) *int {
+	if value <= 0 {
+		return nil
+	}
+
+	return &value
+}
+
+type batchOperationRequest struct {
+	Op   string          `json:"op"`
+	ID   string          `json:"id,omitempty"`
+	Book json.RawMessage `json:"book,omitempty"`
+}
+
+type batchBooksRequest struct {
+	Mode       string                  `json:"mode"`
+	Operations []batchOperationRequest `json:"operations"`
+}
+
+func (r *batchBooksRequest) validate() map[string]string {
+	errorMessages := make(map[string]string)
+
+	if r.Mode == "" {
+		r.Mode = batchModeAtomic
+	}
+
+	if r.Mode != batchModeAtomic && r.Mode != batchModeBestEffort {
+		errorMessages["mode"] = "mode must be one of: atomic, best_effort"
+	}
+
+	if len(r.Operations) == 0 || len(r.Operations) > maxBatchOperations {
+		errorMessages["operations"] = fmt.Sprintf("operations must contain between 1 and %d operations", maxBatchOperations)
+	}
+
+	return errorMessages
+}
+
+// BatchResult is the outcome of the operation at Index of a batch. Skipped
+// operations were valid but not saved because another one failed in atomic
+// mode.
+type BatchResult struct {
+	Index  int               `json:"index"`
+	Op     string            `json:"op"`
+	Status string            `json:"status"`
+	ID     string            `json:"id,omitempty"`
+	Book   *store.Book       `json:"book,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// HandleBatchBooks creates, updates and deletes books in a single
+// transaction. In atomic mode, the default, nothing is saved unless every
+// operation succeeds. In best_effort mode, the operations that succeed are
+// saved and the others are reported as failed.
+func (h *BookHandler) HandleBatchBooks(w http.ResponseWriter, r *http.Request) {
+	var req batchBooksRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding batch books request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	atomic := req.Mode == batchModeAtomic
+	results := make([]BatchResult, len(req.Operations))
+	operations := []store.BookOperation{}
+	// positions maps the operations sent to the store to their result.
+	positions := []int{}
+	seen := make(map[string]bool)
+	failed := false
+
+	for i, op := range req.Operations {
+		results[i] = BatchResult{Index: i, Op: op.Op, ID: op.ID}
+
+		if op.ID != "" && seen[op.ID] {
+			results[i].Status, results[i].Error = batchStatusFailed, "book appears more than once in the batch"
+			failed = true
+			continue
+		}
+		seen[op.ID] = op.ID != ""
+
+		operation, validationErrors, err := h.prepareOperation(user.ID, op)
+		if err != nil {
+			h.logger.Printf("ERROR: preparing batch operation %v", err)
+			helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+			return
+		}
+
+		if len(validationErrors) > 0 {
+			results[i].Status, results[i].Errors = batchStatusFailed, validationErrors
+			failed = true
+			continue
+		}
+
+		operations = append(operations, *operation)
+		positions = append(positions, i)
+	}
+
+	if atomic && failed {
+		for _, i := range positions {
+			results[i].Status = batchStatusSkipped
+		}
+
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"results": results, "committed": false})
+		return
+	}
+
+	errs, err := h.store.ApplyBookOperations(operations, atomic, revisionActor(r))
 	if err != nil {
-		h.logger.Printf("ERROR: creating book %v", err)
+		h.logger.Printf("ERROR: applying batch operations %v", err)
 		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
 		return
 	}
 
-	helpers.WriteJson(w, http.StatusCreated, helpers.Envelop{"book": book})
+	for j, opErr := range errs {
+		result := &results[positions[j]]
+		operation := operations[j]
+
+		switch {
+		case opErr == nil:
+			result.Status = batchStatuses[operation.Type]
+			if operation.Book != nil {
+				result.ID, result.Book = operation.Book.ID, operation.Book
+			}
+		case store.IsUniqueViolation(opErr):
+			result.Status, result.Error = batchStatusFailed, "a book with this isbn is already in your library"
+			failed = true
+		case errors.Is(opErr, pgx.ErrNoRows):
+			result.Status, result.Error = batchStatusFailed, "book not found"
+			failed = true
+		case errors.Is(opErr, store.ErrVersionConflict):
+			result.Status, result.Error = batchStatusFailed, "the book was changed since it was read"
+			failed = true
+		default:
+			h.logger.Printf("ERROR: applying batch operation %d %v", positions[j], opErr)
+			result.Status, result.Error = batchStatusFailed, "internal server error"
+			failed = true
+		}
+	}
+
+	if atomic && failed {
+		for _, i := range positions {
+			if results[i].Status != batchStatusFailed {
+				results[i].Status, results[i].ID, results[i].Book = batchStatusSkipped, req.Operations[i].ID, nil
+			}
+		}
+
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"results": results, "committed": false})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"results": results, "committed": true})
+}
+
+// prepareOperation validates the operation like the single book endpoints
+// do and returns what to send to the store, or the validation errors.
+func (h *BookHandler) prepareOperation(userId string, op batchOperationRequest) (*store.BookOperation, map[string]string, error) {
+	now := time.Now()
+
+	switch op.Op {
+	case store.BookOperationCreate:
+		var req createBookRequest
+		if len(op.Book) == 0 || json.Unmarshal(op.Book, &req) != nil {
+			return nil, map[string]string{"book": "book must be a valid book payload"}, nil
+		}
+
+		if validationErrors := req.validate(); len(validationErrors) > 0 {
+			return nil, validationErrors, nil
+		}
+
+		if ok, err := isUserSeries(h.seriesStore, userId, req.SeriesID); err != nil || !ok {
+			return nil, map[string]string{"series_id": "series not found"}, err
+		}
+
+		book := req.toBook()
+		book.UserId = userId
+		if validationErrors := services.ApplyBookStatusChange(book, req.statusChange(), now); len(validationErrors) > 0 {
+			return nil, validationErrors, nil
+		}
+
+		return &store.BookOperation{Type: op.Op, Book: book}, nil, nil
+
+	case store.BookOperationUpdate:
+		var req updateBookRequest
+		if len(op.Book) == 0 || json.Unmarshal(op.Book, &req) != nil {
+			return nil, map[string]string{"book": "book must be a valid book payload"}, nil
+		}
+
+		if validationErrors := req.validate(); len(validationErrors) > 0 {
+			return nil, validationErrors, nil
+		}
+
+		book, validationErrors, err := h.batchBook(userId, op.ID)
+		if book == nil {
+			return nil, validationErrors, err
+		}
+
+		if ok, err := isUserSeries(h.seriesStore, userId, req.SeriesID.Value); err != nil || !ok {
+			return nil, map[string]string{"series_id": "series not found"}, err
+		}
+
+		book = req.toBook(book)
+		if validationErrors := services.ApplyBookStatusChange(book, req.statusChange(), now); len(validationErrors) > 0 {
+			return nil, validationErrors, nil
+		}
+
+		return &store.BookOperation{Type: op.Op, Book: book}, nil, nil
+
+	case store.BookOperationDelete:
+		book, validationErrors, err := h.batchBook(userId, op.ID)
+		if book == nil {
+			return nil, validationErrors, err
+		}
+
+		return &store.BookOperation{Type: op.Op, ID: book.ID}, nil, nil
+
+	default:
+		return nil, map[string]string{"op": "op must be one of: create, update, delete"}, nil
+	}
+}
+
+// batchBook returns the book of the user targeted by an operation, or the
+// validation errors when there is none.
+func (h *BookHandler) batchBook(userId, id string) (*store.Book, map[string]string, error) {
+	if id == "" {
+		return nil, map[string]string{"id": "id is required"}, nil
+	}
+
+	book, err := h.store.GetBookById(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if book == nil || book.UserId != userId {
+		return nil, map[string]string{"id": "book not found"}, nil
+	}
+
+	return book, nil, nil
+}
+
+type bulkBooksFilter struct {
+	Ids      []string `json:"ids,omitempty"`
+	Shelf    *string  `json:"shelf,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	TagMode  string   `json:"tag_mode,omitempty"`
+	Format   *string  `json:"format,omitempty"`
+	Location *string  `json:"location,omitempty"`
+	Status   *string  `json:"status,omitempty"`
+}
+
+type bulkBooksChanges struct {
+	Status          *string  `json:"status,omitempty"`
+	AddTags         []string `json:"add_tags,omitempty"`
+	RemoveTags      []string `json:"remove_tags,omitempty"`
+	AddToShelf      *string  `json:"add_to_shelf,omitempty"`
+	RemoveFromShelf *string  `json:"remove_from_shelf,omitempty"`
+}
+
+type bulkUpdateBooksRequest struct {
+	Filter bulkBooksFilter  `json:"filter"`
+	Set    bulkBooksChanges `json:"set"`
+}
+
+func (r *bulkUpdateBooksRequest) validate() map[string]string {
+	errorMessages := make(map[string]string)
+	f, set := &r.Filter, &r.Set
+
+	if f.Ids == nil && f.Shelf == nil && len(f.Tags) == 0 && f.Format == nil && f.Location == nil && f.Status == nil {
+		errorMessages["filter"] = "filter must have at least one of ids, shelf, tags, format, location or status"
+	}
+
+	if f.Ids != nil && (len(f.Ids) == 0 || slices.Contains(f.Ids, "")) {
+		errorMessages["filter.ids"] = "ids cannot be empty"
+	} else if invalid := invalidIds(f.Ids); len(invalid) > 0 {
+		errorMessages["filter.ids"] = "invalid book ids: " + strings.Join(invalid, ", ")
+	}
+
+	for field, shelfId := range map[string]*string{"filter.shelf": f.Shelf, "set.add_to_shelf": set.AddToShelf, "set.remove_from_shelf": set.RemoveFromShelf} {
+		if shelfId != nil && len(invalidIds([]string{*shelfId})) > 0 {
+			errorMessages[field] = "invalid shelf id"
+		}
+	}
+
+	if f.TagMode == "" {
+		f.TagMode = store.TagModeAny
+	} else if f.TagMode != store.TagModeAny && f.TagMode != store.TagModeAll {
+		errorMessages["filter.tag_mode"] = "tag_mode must be one of: any, all"
+	}
+
+	if f.Format != nil && !slices.Contains(store.CopyFormats, *f.Format) {
+		errorMessages["filter.format"] = "format must be one of: " + strings.Join(store.CopyFormats, ", ")
+	}
+
+	if f.Status != nil && !slices.Contains(store.BookStatuses, *f.Status) {
+		errorMessages["filter.status"] = "status must be one of: to_read, reading, read"
+	}
+
+	if set.Status == nil && len(set.AddTags) == 0 && len(set.RemoveTags) == 0 && set.AddToShelf == nil && set.RemoveFromShelf == nil {
+		errorMessages["set"] = "set must have at least one of status, add_tags, remove_tags, add_to_shelf or remove_from_shelf"
+	}
+
+	if set.Status != nil && !slices.Contains(store.BookStatuses, *set.Status) {
+		errorMessages["set.status"] = "status must be one of: to_read, reading, read"
+	}
+
+	for field, tags := range map[string]*[]string{"filter.tags": &f.Tags, "set.add_tags": &set.AddTags, "set.remove_tags": &set.RemoveTags} {
+		names := []string{}
+		for _, tag := range *tags {
+			name := normalizeTagName(tag)
+			if name == "" || len(name) > maxTagLength {
+				errorMessages[field] = fmt.Sprintf("tags must have between 1 and %d characters", maxTagLength)
+				continue
+			}
+
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+		*tags = names
+	}
+
+	return errorMessages
+}
+
+func (r *bulkUpdateBooksRequest) filters() store.BookFilters {
+	return store.BookFilters{
+		ShelfId:  r.Filter.Shelf,
+		Tags:     r.Filter.Tags,
+		TagMode:  r.Filter.TagMode,
+		Format:   r.Filter.Format,
+		Location: r.Filter.Location,
+		Status:   r.Filter.Status,
+		Ids:      r.Filter.Ids,
+	}
+}
+
+// HandleBulkUpdateBooks changes the status, tags and shelves of every book
+// matching a filter, all or nothing. Books whose status cannot move to the
+// requested one keep it and are reported, the other changes still apply to
+// them.
+func (h *BookHandler) HandleBulkUpdateBooks(w http.ResponseWriter, r *http.Request) {
+	var req bulkUpdateBooksRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding bulk update books request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	for field, shelfId := range map[string]*string{"set.add_to_shelf": req.Set.AddToShelf, "set.remove_from_shelf": req.Set.RemoveFromShelf} {
+		if shelfId == nil {
+			continue
+		}
+
+		shelf, err := h.shelfStore.GetShelfById(*shelfId)
+		if err != nil {
+			h.logger.Printf("ERROR: getting shelf by id %v", err)
+			helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+			return
+		}
+
+		if shelf == nil || shelf.UserID != user.ID {
+			helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": map[string]string{field: "shelf not found"}})
+			return
+		}
+	}
+
+	filters := req.filters()
+	count, err := h.store.GetBooksCount(user.ID, filters)
+	if err != nil {
+		h.logger.Printf("ERROR: getting books count %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	if count > maxBulkBooks {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": map[string]string{"filter": fmt.Sprintf("filter matches %d books, at most %d can be updated at once", count, maxBulkBooks)}})
+		return
+	}
+
+	books, err := h.store.GetBooks(user.ID, filters, 1, maxBulkBooks)
+	if err != nil {
+		h.logger.Printf("ERROR: getting books %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	bookIds := make([]string, 0, len(books))
+	changed := []store.Book{}
+	statusErrors := map[string]map[string]string{}
+	now := time.Now()
+
+	for _, book := range books {
+		bookIds = append(bookIds, book.ID)
+		if req.Set.Status == nil || book.Status == *req.Set.Status {
+			continue
+		}
+
+		if validationErrors := services.ApplyBookStatusChange(&book, services.BookStatusChange{Status: req.Set.Status}, now); len(validationErrors) > 0 {
+			statusErrors[book.ID] = validationErrors
+			continue
+		}
+		changed = append(changed, book)
+	}
+
+	if len(bookIds) > 0 {
+		changes := store.BookBulkChanges{
+			AddTags:         req.Set.AddTags,
+			RemoveTags:      req.Set.RemoveTags,
+			AddToShelf:      req.Set.AddToShelf,
+			RemoveFromShelf: req.Set.RemoveFromShelf,
+		}
+
+		if err := h.store.BulkUpdateBooks(user.ID, bookIds, changed, changes, revisionActor(r)); err != nil {
+			if errors.Is(err, store.ErrVersionConflict) {
+				writeVersionConflict(w, r)
+				return
+			}
+
+			h.logger.Printf("ERROR: bulk updating books %v", err)
+			helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+			return
+		}
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"matched": len(bookIds), "book_ids": bookIds, "status_errors": statusErrors})
 }
@@ -0,0 +1,258 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/martialanouman/personal-library/internal/helpers"
+	"github.com/martialanouman/personal-library/internal/middleware"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+type BookReadHandler struct {
+	store     store.BookReadStore
+	bookStore store.BookStore
+	logger    *log.Logger
+}
+
+func NewBookReadHandler(store store.BookReadStore, bookStore store.BookStore, logger *log.Logger) BookReadHandler {
+	return BookReadHandler{store: store, bookStore: bookStore, logger: logger}
+}
+
+type bookReadRequest struct {
+	StartedAt  *string  `json:"started_at,omitempty"`
+	FinishedAt *string  `json:"finished_at,omitempty"`
+	Rating     *float64 `json:"rating,omitempty"`
+	Review     *string  `json:"review,omitempty"`
+}
+
+func (req *bookReadRequest) validate() map[string]string {
+	errorMessages := make(map[string]string)
+
+	if req.StartedAt != nil {
+		if _, err := time.Parse(time.DateOnly, *req.StartedAt); err != nil {
+			errorMessages["started_at"] = "started_at must be in YYYY-MM-DD format"
+		}
+	}
+
+	if req.FinishedAt != nil {
+		if _, err := time.Parse(time.DateOnly, *req.FinishedAt); err != nil {
+			errorMessages["finished_at"] = "finished_at must be in YYYY-MM-DD format"
+		}
+	}
+
+	if req.Rating != nil && !isValidRating(*req.Rating) {
+		errorMessages["rating"] = ratingErrorMessage
+	}
+
+	if req.Review != nil && len(*req.Review) > maxReviewLength {
+		errorMessages["review"] = "review must be at most 100000 characters"
+	}
+
+	return errorMessages
+}
+
+func (req *bookReadRequest) toRead(read *store.BookRead) *store.BookRead {
+	if req.StartedAt != nil {
+		parsedDate, _ := time.Parse(time.DateOnly, *req.StartedAt)
+		read.StartedAt = &parsedDate
+	}
+
+	if req.FinishedAt != nil {
+		parsedDate, _ := time.Parse(time.DateOnly, *req.FinishedAt)
+		read.FinishedAt = &parsedDate
+	}
+
+	if req.Rating != nil {
+		read.Rating = req.Rating
+	}
+
+	if req.Review != nil {
+		read.Review = req.Review
+	}
+
+	return read
+}
+
+func validateRead(read *store.BookRead) map[string]string {
+	errorMessages := make(map[string]string)
+
+	if read.StartedAt != nil && read.FinishedAt != nil && read.FinishedAt.Before(*read.StartedAt) {
+		errorMessages["finished_at"] = "finished_at cannot be before started_at"
+	}
+
+	return errorMessages
+}
+
+func (h *BookReadHandler) getBookRead(w http.ResponseWriter, r *http.Request, book *store.Book) *store.BookRead {
+	readId := chi.URLParam(r, "readId")
+	if readId == "" {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid read id"})
+		return nil
+	}
+
+	read, err := h.store.GetReadById(readId)
+	if err != nil {
+		h.logger.Printf("ERROR: getting book read by id %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return nil
+	}
+
+	if read == nil || read.BookID != book.ID {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "read not found"})
+		return nil
+	}
+
+	return read
+}
+
+// writeReadWithBook answers with the reading cycle and the book, whose dates,
+// status and rating follow its latest cycle.
+func (h *BookReadHandler) writeReadWithBook(w http.ResponseWriter, status int, read *store.BookRead) {
+	book, err := h.bookStore.GetBookById(read.BookID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting book by id %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, status, helpers.Envelop{"read": read, "book": book})
+}
+
+func (h *BookReadHandler) HandleGetReads(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	reads, err := h.store.GetBookReads(book.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting book reads %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	if wantsHTML(r) {
+		for i := range reads {
+			reads[i].ReviewHTML = renderReview(reads[i].Review)
+		}
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"reads": reads})
+}
+
+func (h *BookReadHandler) HandleCreateRead(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	var req bookReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding book read request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	read := req.toRead(&store.BookRead{BookID: book.ID, UserID: user.ID})
+	if read.StartedAt == nil && read.FinishedAt == nil {
+		now := time.Now()
+		read.StartedAt = &now
+	}
+
+	if validationErrors := validateRead(read); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	if read.FinishedAt == nil {
+		reads, err := h.store.GetBookReads(book.ID)
+		if err != nil {
+			h.logger.Printf("ERROR: getting book reads %v", err)
+			helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+			return
+		}
+
+		for _, existing := range reads {
+			if existing.FinishedAt == nil {
+				helpers.WriteJson(w, http.StatusConflict, helpers.Envelop{"error": "this book is already being read"})
+				return
+			}
+		}
+	}
+
+	if err := h.store.CreateRead(read, revisionActor(r)); err != nil {
+		h.logger.Printf("ERROR: creating book read %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	h.writeReadWithBook(w, http.StatusCreated, read)
+}
+
+func (h *BookReadHandler) HandleUpdateRead(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	read := h.getBookRead(w, r, book)
+	if read == nil {
+		return
+	}
+
+	var req bookReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding book read request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	read = req.toRead(read)
+	if validationErrors := validateRead(read); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	if err := h.store.UpdateRead(read, revisionActor(r)); err != nil {
+		h.logger.Printf("ERROR: updating book read %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	h.writeReadWithBook(w, http.StatusOK, read)
+}
+
+func (h *BookReadHandler) HandleDeleteRead(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	read := h.getBookRead(w, r, book)
+	if read == nil {
+		return
+	}
+
+	if err := h.store.DeleteRead(read.ID, revisionActor(r)); err != nil {
+		h.logger.Printf("ERROR: deleting book read %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
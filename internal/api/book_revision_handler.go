@@ -0,0 +1,84 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/martialanouman/personal-library/internal/helpers"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+type BookRevisionHandler struct {
+	store     store.BookRevisionStore
+	bookStore store.BookStore
+	logger    *log.Logger
+}
+
+func NewBookRevisionHandler(store store.BookRevisionStore, bookStore store.BookStore, logger *log.Logger) BookRevisionHandler {
+	return BookRevisionHandler{store: store, bookStore: bookStore, logger: logger}
+}
+
+// HandleGetRevisions lists the changes made to the book, the latest first.
+func (h *BookRevisionHandler) HandleGetRevisions(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	revisions, err := h.store.GetBookRevisions(book.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting book revisions %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"revisions": revisions})
+}
+
+// HandleRevertRevision brings the book back to the state it was in before
+// the revision, undoing it and every later change.
+func (h *BookRevisionHandler) HandleRevertRevision(w http.ResponseWriter, r *http.Request) {
+	revision, err := strconv.Atoi(chi.URLParam(r, "rev"))
+	if err != nil || revision < 1 {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid revision"})
+		return
+	}
+
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	if !checkIfMatch(w, r, versionETag(book.Version)) {
+		return
+	}
+
+	reverted, err := h.store.RevertBook(book.ID, revision, book.Version, revisionActor(r))
+	if errors.Is(err, pgx.ErrNoRows) {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "revision not found"})
+		return
+	}
+
+	if errors.Is(err, store.ErrVersionConflict) {
+		writeVersionConflict(w, r)
+		return
+	}
+
+	if store.IsUniqueViolation(err) {
+		helpers.WriteJson(w, http.StatusConflict, helpers.Envelop{"error": "a book with this isbn is already in your library"})
+		return
+	}
+
+	if err != nil {
+		h.logger.Printf("ERROR: reverting book revision %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	w.Header().Set("ETag", versionETag(reverted.Version))
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"book": reverted})
+}
@@ -0,0 +1,491 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/martialanouman/personal-library/internal/helpers"
+	"github.com/martialanouman/personal-library/internal/isbn"
+	"github.com/martialanouman/personal-library/internal/middleware"
+	"github.com/martialanouman/personal-library/internal/services"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+const (
+	defaultCatalogLimit = 10
+	maxCatalogLimit     = 40
+
+	maxScanCandidates = 5
+	maxScanImages     = 10
+	maxScanBatchSize  = 30 << 20
+
+	catalogIsbnPrefix    = "isbn:"
+	catalogBigBookPrefix = "bigbook:"
+)
+
+// CatalogEntry is a book found in the external catalogs. Its id is derived
+// from the ISBN-13 of the book, or from its Big Book id when it has none, so
+// that it can be added later on without searching again.
+type CatalogEntry struct {
+	ID string `json:"id"`
+	services.BookMetadata
+	InLibrary  bool    `json:"in_library"`
+	BookID     *string `json:"book_id,omitempty"`
+	InWishlist bool    `json:"in_wishlist"`
+	WishID     *string `json:"wish_id,omitempty"`
+}
+
+// ScanResult is a barcode found in a photo with the catalog entries matching
+// it. Image is the index of the photo in a batch. Error tells why a barcode
+// has no entries, not being found in the catalogs aside.
+type ScanResult struct {
+	Image int `json:"image"`
+	services.ScannedBarcode
+	Entries []CatalogEntry `json:"entries"`
+	Error   string         `json:"error,omitempty"`
+}
+
+type CatalogHandler struct {
+	bookStore     store.BookStore
+	wishlistStore store.WishlistStore
+	seriesStore   store.SeriesStore
+	bigBook       *services.BigBookProvider
+	metadata      *services.MetadataChain
+	logger        *log.Logger
+}
+
+func NewCatalogHandler(bookStore store.BookStore, wishlistStore store.WishlistStore, seriesStore store.SeriesStore, bigBook *services.BigBookProvider, metadata *services.MetadataChain, logger *log.Logger) CatalogHandler {
+	return CatalogHandler{
+		bookStore:     bookStore,
+		wishlistStore: wishlistStore,
+		seriesStore:   seriesStore,
+		bigBook:       bigBook,
+		metadata:      metadata,
+		logger:        logger,
+	}
+}
+
+// catalogEntryId returns the id of the catalog entry of the book, or an empty
+// string when the book can be told apart neither by ISBN nor by Big Book id.
+func catalogEntryId(metadata *services.BookMetadata) string {
+	if isbn13 := metadata.CanonicalIsbn(); isbn13 != "" {
+		return catalogIsbnPrefix + isbn13
+	}
+
+	if metadata.BigBookID != "" {
+		return catalogBigBookPrefix + metadata.BigBookID
+	}
+
+	return ""
+}
+
+// writeMetadataError answers with the status matching an error of the
+// metadata providers.
+func writeMetadataError(w http.ResponseWriter, logger *log.Logger, err error, notFoundMessage string) {
+	status, message := metadataError(logger, err, notFoundMessage)
+	helpers.WriteJson(w, status, helpers.Envelop{"error": message})
+}
+
+// metadataError returns the status and the message matching an error of the
+// metadata providers, logging the unexpected ones.
+func metadataError(logger *log.Logger, err error, notFoundMessage string) (int, string) {
+	switch {
+	case errors.Is(err, services.ErrMetadataNotFound):
+		return http.StatusNotFound, notFoundMessage
+	case errors.Is(err, services.ErrNoMetadataProvider):
+		return http.StatusServiceUnavailable, "no book metadata provider is configured"
+	case errors.Is(err, services.ErrMetadataRateLimited):
+		return http.StatusServiceUnavailable, "book metadata providers are rate limited, try again later"
+	case errors.Is(err, services.ErrMetadataUnavailable):
+		return http.StatusServiceUnavailable, "book metadata providers are unavailable, try again later"
+	case errors.Is(err, services.ErrMetadataTimeout):
+		return http.StatusGatewayTimeout, "book metadata providers did not answer in time"
+	case errors.Is(err, services.ErrMetadataUpstream):
+		logger.Printf("ERROR: querying book metadata providers %v", err)
+		return http.StatusBadGateway, "book metadata providers failed"
+	default:
+		logger.Printf("ERROR: querying book metadata providers %v", err)
+		return http.StatusInternalServerError, "internal server error"
+	}
+}
+
+func (h *CatalogHandler) HandleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := services.CatalogQuery{
+		Query:  strings.TrimSpace(q.Get("q")),
+		Author: strings.TrimSpace(q.Get("author")),
+		Limit:  defaultCatalogLimit,
+	}
+
+	errorMessages := make(map[string]string)
+
+	if value := strings.TrimSpace(q.Get("isbn")); value != "" {
+		isbn13, err := isbn.Parse(value)
+		if err != nil {
+			errorMessages["isbn"] = "isbn must be a valid ISBN-10 or ISBN-13"
+		}
+		query.Isbn = isbn13
+	}
+
+	var message string
+	if query.Limit, message = limitParam(r, defaultCatalogLimit, maxCatalogLimit); message != "" {
+		errorMessages["limit"] = message
+	}
+
+	if query.Query == "" && query.Author == "" && query.Isbn == "" && errorMessages["isbn"] == "" {
+		errorMessages["q"] = "at least one of q, author or isbn is required"
+	}
+
+	if len(errorMessages) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": errorMessages})
+		return
+	}
+
+	hits, err := h.metadata.Search(r.Context(), query)
+	if err != nil && !errors.Is(err, services.ErrMetadataNotFound) {
+		writeMetadataError(w, h.logger, err, "no book found")
+		return
+	}
+
+	user := middleware.GetUser(r)
+	entries, err := h.toEntries(user.ID, hits)
+	if err != nil {
+		h.logger.Printf("ERROR: matching catalog entries %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"entries": entries})
+}
+
+// toEntries turns the hits that can be added into catalog entries, telling
+// which ones are already in the library or the wishlist of the user.
+func (h *CatalogHandler) toEntries(userId string, hits []services.BookMetadata) ([]CatalogEntry, error) {
+	entries := make([]CatalogEntry, 0, len(hits))
+	isbns := []string{}
+	bigBookIds := []int64{}
+
+	for _, hit := range hits {
+		id := catalogEntryId(&hit)
+		if id == "" {
+			continue
+		}
+
+		if isbn13 := hit.CanonicalIsbn(); isbn13 != "" {
+			isbns = append(isbns, isbn13)
+		}
+		if bigBookId, err := strconv.ParseInt(hit.BigBookID, 10, 64); err == nil {
+			bigBookIds = append(bigBookIds, bigBookId)
+		}
+
+		entries = append(entries, CatalogEntry{ID: id, BookMetadata: hit})
+	}
+
+	if len(entries) == 0 {
+		return entries, nil
+	}
+
+	bookIds, err := h.bookStore.GetBookIdsByIsbn(userId, isbns)
+	if err != nil {
+		return nil, err
+	}
+
+	wishes, err := h.wishlistStore.FindWishes(userId, isbns, bigBookIds)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+		isbn13 := entry.CanonicalIsbn()
+
+		if bookId, ok := bookIds[isbn13]; ok && isbn13 != "" {
+			entry.InLibrary = true
+			entry.BookID = &bookId
+		}
+
+		for _, wish := range wishes {
+			matchesIsbn := wish.Isbn != nil && isbn13 != "" && *wish.Isbn == isbn13
+			matchesBigBook := wish.BigBookID != nil && strconv.FormatInt(*wish.BigBookID, 10) == entry.BigBookID
+			if matchesIsbn || matchesBigBook {
+				entry.InWishlist = true
+				entry.WishID = &wish.ID
+				break
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// resolveEntry looks the book of the catalog entry given in the URL up. It
+// answers with an error and returns nil when the entry cannot be resolved.
+func (h *CatalogHandler) resolveEntry(w http.ResponseWriter, r *http.Request) *services.BookMetadata {
+	entryId := chi.URLParam(r, "entryId")
+
+	switch {
+	case strings.HasPrefix(entryId, catalogIsbnPrefix):
+		isbn13, err := isbn.Parse(strings.TrimPrefix(entryId, catalogIsbnPrefix))
+		if err != nil {
+			break
+		}
+
+		metadata, err := h.metadata.LookupISBN(r.Context(), isbn13)
+		if err != nil {
+			writeMetadataError(w, h.logger, err, "catalog entry not found")
+			return nil
+		}
+
+		// Providers may answer with another edition: keep the ISBN of the entry.
+		metadata.Isbn13 = isbn13
+		return metadata
+
+	case strings.HasPrefix(entryId, catalogBigBookPrefix):
+		bbId := strings.TrimPrefix(entryId, catalogBigBookPrefix)
+		if _, err := strconv.ParseInt(bbId, 10, 64); err != nil {
+			break
+		}
+
+		if h.bigBook == nil {
+			helpers.WriteJson(w, http.StatusServiceUnavailable, helpers.Envelop{"error": "big book api is not configured"})
+			return nil
+		}
+
+		bookInfo, err := h.bigBook.GetBookByBigBookId(r.Context(), bbId)
+		if err != nil {
+			writeMetadataError(w, h.logger, err, "catalog entry not found")
+			return nil
+		}
+
+		metadata := bookInfo.Metadata()
+		h.metadata.Complete(r.Context(), metadata)
+		return metadata
+	}
+
+	helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid catalog entry id"})
+	return nil
+}
+
+func (h *CatalogHandler) HandleAddToLibrary(w http.ResponseWriter, r *http.Request) {
+	metadata := h.resolveEntry(w, r)
+	if metadata == nil {
+		return
+	}
+
+	user := middleware.GetUser(r)
+	book, err := bookFromMetadata(h.seriesStore, user.ID, metadata)
+	if err != nil {
+		h.logger.Printf("ERROR: finding or creating series %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	if err := h.bookStore.CreateBook(&book); err != nil {
+		if store.IsUniqueViolation(err) {
+			helpers.WriteJson(w, http.StatusConflict, helpers.Envelop{"error": "a book with this isbn is already in your library"})
+			return
+		}
+
+		h.logger.Printf("ERROR: creating book %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusCreated, helpers.Envelop{"book": book, "sources": metadata.Sources})
+}
+
+func (h *CatalogHandler) HandleAddToWishlist(w http.ResponseWriter, r *http.Request) {
+	metadata := h.resolveEntry(w, r)
+	if metadata == nil {
+		return
+	}
+
+	user := middleware.GetUser(r)
+	isbn13 := metadata.CanonicalIsbn()
+
+	var bigBookIds []int64
+	bigBookId, err := strconv.ParseInt(metadata.BigBookID, 10, 64)
+	if err == nil {
+		bigBookIds = append(bigBookIds, bigBookId)
+	}
+
+	existing, err := h.wishlistStore.FindWishes(user.ID, []string{isbn13}, bigBookIds)
+	if err != nil {
+		h.logger.Printf("ERROR: finding wishes %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	if len(existing) > 0 {
+		helpers.WriteJson(w, http.StatusConflict, helpers.Envelop{"error": "this book is already in your wishlist", "wish": existing[0]})
+		return
+	}
+
+	author := strings.Join(metadata.Authors, ", ")
+	if author == "" {
+		author = unknownAuthor
+	}
+
+	wish := &store.Wish{
+		UserID:   user.ID,
+		Title:    metadata.Title,
+		Author:   &author,
+		Isbn:     optionalString(isbn13),
+		Priority: "normal",
+		CoverUrl: optionalString(metadata.CoverURL),
+	}
+
+	for _, field := range []string{"cover_url", "big_book_id"} {
+		if source, ok := metadata.Sources[field]; ok {
+			wish.MetadataSources = store.WithMetadataSource(wish.MetadataSources, source, field)
+		}
+	}
+
+	if len(bigBookIds) > 0 {
+		wish.BigBookID = &bigBookId
+	}
+
+	if err := h.wishlistStore.AddWish(wish); err != nil {
+		h.logger.Printf("ERROR: adding wish %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusCreated, helpers.Envelop{"wish": wish})
+}
+
+// HandleScan finds the ISBN barcodes in the photo sent as the file field of
+// a multipart form and returns the catalog entries matching them.
+func (h *CatalogHandler) HandleScan(w http.ResponseWriter, r *http.Request) {
+	// Leave room for the multipart envelope around the photo.
+	r.Body = http.MaxBytesReader(w, r.Body, services.MaxScanSize+1<<20)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "a photo is required in the file field"})
+		return
+	}
+	defer file.Close()
+
+	barcodes, err := scanPhoto(file)
+	if err != nil {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": map[string]string{"file": err.Error()}})
+		return
+	}
+
+	if len(barcodes) == 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": map[string]string{"file": "no EAN-13 barcode found in the photo"}})
+		return
+	}
+
+	results, err := h.scanResults(r, 0, barcodes)
+	if err != nil {
+		h.logger.Printf("ERROR: matching scanned barcodes %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"results": results})
+}
+
+// HandleScanBatch scans the photos sent as the files fields of a multipart
+// form. It returns one result per barcode found, and why the photos with no
+// result could not be read, by index.
+func (h *CatalogHandler) HandleScanBatch(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxScanBatchSize)
+	if err := r.ParseMultipartForm(maxScanBatchSize); err != nil {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": fmt.Sprintf("photos of at most %d MB in total are required in the files field", maxScanBatchSize>>20)})
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	headers := r.MultipartForm.File["files"]
+	if len(headers) == 0 || len(headers) > maxScanImages {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": map[string]string{"files": fmt.Sprintf("between 1 and %d photos are required", maxScanImages)}})
+		return
+	}
+
+	results := []ScanResult{}
+	unreadable := map[int]string{}
+	for i, header := range headers {
+		file, err := header.Open()
+		if err != nil {
+			h.logger.Printf("ERROR: opening uploaded photo %v", err)
+			helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+			return
+		}
+
+		barcodes, err := scanPhoto(file)
+		file.Close()
+		if err != nil {
+			unreadable[i] = err.Error()
+			continue
+		}
+
+		if len(barcodes) == 0 {
+			unreadable[i] = "no EAN-13 barcode found in the photo"
+			continue
+		}
+
+		imageResults, err := h.scanResults(r, i, barcodes)
+		if err != nil {
+			h.logger.Printf("ERROR: matching scanned barcodes %v", err)
+			helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+			return
+		}
+		results = append(results, imageResults...)
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"results": results, "unreadable": unreadable})
+}
+
+// scanPhoto returns the barcodes of the photo, or an error telling the user
+// why it cannot be read.
+func scanPhoto(file io.Reader) ([]services.ScannedBarcode, error) {
+	data, err := io.ReadAll(io.LimitReader(file, services.MaxScanSize+1))
+	if err != nil || len(data) > services.MaxScanSize {
+		return nil, fmt.Errorf("photo must be at most %d MB", services.MaxScanSize>>20)
+	}
+
+	barcodes, err := services.ScanBarcodes(data)
+	if err != nil {
+		return nil, errors.New("photo must be a JPEG, PNG or GIF image")
+	}
+
+	return barcodes, nil
+}
+
+// scanResults looks the ISBN of the barcodes up in the external catalogs.
+func (h *CatalogHandler) scanResults(r *http.Request, image int, barcodes []services.ScannedBarcode) ([]ScanResult, error) {
+	user := middleware.GetUser(r)
+	results := make([]ScanResult, 0, len(barcodes))
+
+	for _, barcode := range barcodes {
+		result := ScanResult{Image: image, ScannedBarcode: barcode, Entries: []CatalogEntry{}}
+		if barcode.Isbn == "" {
+			result.Error = "barcode is not an isbn"
+			results = append(results, result)
+			continue
+		}
+
+		hits, err := h.metadata.Search(r.Context(), services.CatalogQuery{Isbn: barcode.Isbn, Limit: maxScanCandidates})
+		if err != nil && !errors.Is(err, services.ErrMetadataNotFound) {
+			_, result.Error = metadataError(h.logger, err, "")
+		}
+
+		entries, err := h.toEntries(user.ID, hits)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Entries = entries
+		results = append(results, result)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,261 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/martialanouman/personal-library/internal/helpers"
+	"github.com/martialanouman/personal-library/internal/middleware"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+type CopyHandler struct {
+	store     store.CopyStore
+	bookStore store.BookStore
+	logger    *log.Logger
+}
+
+func NewCopyHandler(store store.CopyStore, bookStore store.BookStore, logger *log.Logger) CopyHandler {
+	return CopyHandler{store: store, bookStore: bookStore, logger: logger}
+}
+
+type copyRequest struct {
+	Format      *string  `json:"format,omitempty"`
+	Edition     *string  `json:"edition,omitempty"`
+	Isbn        *string  `json:"isbn,omitempty"`
+	PurchasedAt *string  `json:"purchased_at,omitempty"`
+	Price       *float64 `json:"price,omitempty"`
+	Currency    *string  `json:"currency,omitempty"`
+	Condition   *string  `json:"condition,omitempty"`
+	Location    *string  `json:"location,omitempty"`
+}
+
+func (req *copyRequest) validate(creating bool) map[string]string {
+	errorMessages := make(map[string]string)
+
+	if req.Format == nil {
+		if creating {
+			errorMessages["format"] = "format is required"
+		}
+	} else if !slices.Contains(store.CopyFormats, *req.Format) {
+		errorMessages["format"] = "format must be one of: " + strings.Join(store.CopyFormats, ", ")
+	}
+
+	if req.Edition != nil && len(*req.Edition) > 255 {
+		errorMessages["edition"] = "edition must be at most 255 characters"
+	}
+
+	normalizeIsbn(req.Isbn, errorMessages)
+
+	if req.PurchasedAt != nil {
+		if _, err := time.Parse(time.DateOnly, *req.PurchasedAt); err != nil {
+			errorMessages["purchased_at"] = "purchased_at must be in YYYY-MM-DD format"
+		}
+	}
+
+	if req.Price != nil && *req.Price < 0 {
+		errorMessages["price"] = "price cannot be negative"
+	}
+
+	if req.Currency != nil {
+		currency := strings.ToUpper(*req.Currency)
+		if !currencyPattern.MatchString(currency) {
+			errorMessages["currency"] = "currency must be a 3 letters ISO 4217 code"
+		}
+		req.Currency = &currency
+	}
+
+	if req.Condition != nil && !slices.Contains(store.CopyConditions, *req.Condition) {
+		errorMessages["condition"] = "condition must be one of: " + strings.Join(store.CopyConditions, ", ")
+	}
+
+	if req.Location != nil && len(*req.Location) > 255 {
+		errorMessages["location"] = "location must be at most 255 characters"
+	}
+
+	return errorMessages
+}
+
+func (req *copyRequest) toCopy(bookCopy *store.Copy) *store.Copy {
+	if req.Format != nil {
+		bookCopy.Format = *req.Format
+	}
+
+	if req.Edition != nil {
+		bookCopy.Edition = req.Edition
+	}
+
+	if req.Isbn != nil {
+		bookCopy.Isbn = req.Isbn
+	}
+
+	if req.PurchasedAt != nil {
+		bookCopy.PurchasedAt = parseOptionalDate(req.PurchasedAt)
+	}
+
+	if req.Price != nil {
+		bookCopy.Price = req.Price
+	}
+
+	if req.Currency != nil {
+		bookCopy.Currency = req.Currency
+	}
+
+	if req.Condition != nil {
+		bookCopy.Condition = req.Condition
+	}
+
+	if req.Location != nil {
+		bookCopy.Location = req.Location
+	}
+
+	return bookCopy
+}
+
+func validateCopy(bookCopy *store.Copy) map[string]string {
+	errorMessages := make(map[string]string)
+
+	if bookCopy.Price != nil && bookCopy.Currency == nil {
+		errorMessages["currency"] = "currency is required with a price"
+	}
+
+	return errorMessages
+}
+
+func (h *CopyHandler) getBookCopy(w http.ResponseWriter, r *http.Request, book *store.Book) *store.Copy {
+	copyId := chi.URLParam(r, "copyId")
+	if copyId == "" {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid copy id"})
+		return nil
+	}
+
+	bookCopy, err := h.store.GetCopyById(copyId)
+	if err != nil {
+		h.logger.Printf("ERROR: getting copy by id %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return nil
+	}
+
+	if bookCopy == nil || bookCopy.BookID != book.ID {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "copy not found"})
+		return nil
+	}
+
+	return bookCopy
+}
+
+func (h *CopyHandler) HandleGetCopies(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	copies, err := h.store.GetBookCopies(book.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting book copies %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"copies": copies})
+}
+
+func (h *CopyHandler) HandleCreateCopy(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	var req copyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding copy request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(true); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	bookCopy := req.toCopy(&store.Copy{BookID: book.ID, UserID: user.ID})
+	if validationErrors := validateCopy(bookCopy); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	if err := h.store.CreateCopy(bookCopy); err != nil {
+		h.logger.Printf("ERROR: creating copy %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusCreated, helpers.Envelop{"copy": bookCopy})
+}
+
+func (h *CopyHandler) HandleUpdateCopy(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	bookCopy := h.getBookCopy(w, r, book)
+	if bookCopy == nil {
+		return
+	}
+
+	var req copyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding copy request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(false); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	bookCopy = req.toCopy(bookCopy)
+	if validationErrors := validateCopy(bookCopy); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	if err := h.store.UpdateCopy(bookCopy); err != nil {
+		h.logger.Printf("ERROR: updating copy %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"copy": bookCopy})
+}
+
+func (h *CopyHandler) HandleDeleteCopy(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	bookCopy := h.getBookCopy(w, r, book)
+	if bookCopy == nil {
+		return
+	}
+
+	if err := h.store.DeleteCopy(bookCopy.ID); err != nil {
+		h.logger.Printf("ERROR: deleting copy %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/martialanouman/personal-library/internal/helpers"
+	"github.com/martialanouman/personal-library/internal/middleware"
+	"github.com/martialanouman/personal-library/internal/services"
+	"github.com/martialanouman/personal-library/internal/storage"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+type CoverHandler struct {
+	store     store.CoverStore
+	bookStore store.BookStore
+	covers    *services.CoverService
+	logger    *log.Logger
+}
+
+func NewCoverHandler(store store.CoverStore, bookStore store.BookStore, covers *services.CoverService, logger *log.Logger) CoverHandler {
+	return CoverHandler{store: store, bookStore: bookStore, covers: covers, logger: logger}
+}
+
+// HandleGetCover serves a cover at one of its sizes. Stored files never
+// change, so they are cached for good and revalidated by their ETag.
+func (h *CoverHandler) HandleGetCover(w http.ResponseWriter, r *http.Request) {
+	size := chi.URLParam(r, "size")
+	if !slices.Contains(services.CoverSizes, size) {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": fmt.Sprintf("size must be one of %s", strings.Join(services.CoverSizes, ", "))})
+		return
+	}
+
+	cover, err := h.store.GetCoverById(chi.URLParam(r, "id"))
+	if err != nil {
+		h.logger.Printf("ERROR: getting cover by id %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	if cover == nil {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "cover not found"})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	if cover.UserID != user.ID {
+		helpers.WriteJson(w, http.StatusForbidden, helpers.Envelop{"error": "you are not allowed to perform this action on this resource"})
+		return
+	}
+
+	etag := fmt.Sprintf(`"%s-%s"`, cover.Hash, size)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	file, contentType, err := h.covers.Open(r.Context(), cover, size)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			h.logger.Printf("ERROR: opening cover file %v", err)
+			helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+			return
+		}
+
+		h.logger.Printf("WARNING: file of cover %s missing at size %s", cover.ID, size)
+		w.Header().Del("ETag")
+		w.Header().Del("Cache-Control")
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "cover not found"})
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, file); err != nil {
+		h.logger.Printf("ERROR: writing cover %v", err)
+	}
+}
+
+// HandleUploadBookCover replaces the cover of the book with the image sent
+// as the file field of a multipart form. Uploaded covers are kept until
+// deleted, even when the cover_url of the book changes.
+func (h *CoverHandler) HandleUploadBookCover(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	// Leave room for the multipart envelope around the image.
+	r.Body = http.MaxBytesReader(w, r.Body, services.MaxCoverSize+1<<20)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "a cover image is required in the file field"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, services.MaxCoverSize+1))
+	if err != nil {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid cover image"})
+		return
+	}
+
+	cover, err := h.covers.Save(r.Context(), book.ID, book.UserId, nil, data)
+	if errors.Is(err, services.ErrInvalidCover) {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": map[string]string{
+			"file": fmt.Sprintf("file must be a JPEG, PNG or GIF image of at most %d MB", services.MaxCoverSize>>20),
+		}})
+		return
+	}
+
+	if err != nil {
+		h.logger.Printf("ERROR: saving cover %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusCreated, helpers.Envelop{"cover": cover})
+}
+
+// HandleDeleteBookCover deletes the stored cover of the book. A book with a
+// cover_url gets it downloaded again.
+func (h *CoverHandler) HandleDeleteBookCover(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	err := h.covers.Remove(r.Context(), book.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "cover not found"})
+		return
+	}
+
+	if err != nil {
+		h.logger.Printf("ERROR: deleting cover %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// etagMatches tells whether the If-None-Match header lists etag.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/martialanouman/personal-library/internal/helpers"
+	"github.com/martialanouman/personal-library/internal/middleware"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+type ExportHandler struct {
+	bookStore     store.BookStore
+	wishlistStore store.WishlistStore
+	shelfStore    store.ShelfStore
+	bookReadStore store.BookReadStore
+	quoteStore    store.QuoteStore
+	copyStore     store.CopyStore
+	logger        *log.Logger
+}
+
+type exportedShelf struct {
+	store.Shelf
+	BookIds []string `json:"book_ids"`
+}
+
+func NewExportHandler(bookStore store.BookStore, wishlistStore store.WishlistStore, shelfStore store.ShelfStore, bookReadStore store.BookReadStore, quoteStore store.QuoteStore, copyStore store.CopyStore, logger *log.Logger) ExportHandler {
+	return ExportHandler{
+		bookStore:     bookStore,
+		wishlistStore: wishlistStore,
+		shelfStore:    shelfStore,
+		bookReadStore: bookReadStore,
+		quoteStore:    quoteStore,
+		copyStore:     copyStore,
+		logger:        logger,
+	}
+}
+
+func (h *ExportHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	books, err := h.bookStore.GetUserBooks(user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: exporting books %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	wishes, err := h.wishlistStore.GetAllUserWishes(user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: exporting wishes %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	shelves, err := h.shelfStore.GetShelves(user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: exporting shelves %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	exportedShelves := make([]exportedShelf, 0, len(shelves))
+	for _, shelf := range shelves {
+		bookIds, err := h.shelfStore.GetShelfBookIds(shelf.ID)
+		if err != nil {
+			h.logger.Printf("ERROR: exporting shelf books %v", err)
+			helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+			return
+		}
+
+		exportedShelves = append(exportedShelves, exportedShelf{Shelf: shelf, BookIds: bookIds})
+	}
+
+	reads, err := h.bookReadStore.GetUserReads(user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: exporting book reads %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	quotes, err := h.quoteStore.GetUserQuotes(user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: exporting quotes %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	copies, err := h.copyStore.GetUserCopies(user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: exporting copies %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="library.json"`)
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{
+		"exported_at": time.Now().UTC(),
+		"books":       books,
+		"wishes":      wishes,
+		"shelves":     exportedShelves,
+		"reads":       reads,
+		"quotes":      quotes,
+		"copies":      copies,
+	})
+}
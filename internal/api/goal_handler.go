@@ -0,0 +1,344 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/martialanouman/personal-library/internal/helpers"
+	"github.com/martialanouman/personal-library/internal/middleware"
+	"github.com/martialanouman/personal-library/internal/services"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+type GoalHandler struct {
+	store  store.GoalStore
+	logger *log.Logger
+}
+
+func NewGoalHandler(store store.GoalStore, logger *log.Logger) GoalHandler {
+	return GoalHandler{store: store, logger: logger}
+}
+
+type createGoalRequest struct {
+	Metric      string `json:"metric"`
+	Period      string `json:"period"`
+	Year        int    `json:"year"`
+	PeriodIndex *int   `json:"period_index,omitempty"`
+	Target      int    `json:"target"`
+}
+
+func (req *createGoalRequest) validate() map[string]string {
+	errorMessages := make(map[string]string)
+
+	if !slices.Contains(store.GoalMetrics, req.Metric) {
+		errorMessages["metric"] = "metric must be one of: books, pages, wishlist_books"
+	}
+
+	if !slices.Contains(store.GoalPeriods, req.Period) {
+		errorMessages["period"] = "period must be one of: year, quarter, month"
+	}
+
+	if req.Year < 1900 || req.Year > 9999 {
+		errorMessages["year"] = "year must be a valid year"
+	}
+
+	switch req.Period {
+	case store.GoalPeriodMonth:
+		if req.PeriodIndex == nil || *req.PeriodIndex < 1 || *req.PeriodIndex > 12 {
+			errorMessages["period_index"] = "period_index must be the month, between 1 and 12"
+		}
+	case store.GoalPeriodQuarter:
+		if req.PeriodIndex == nil || *req.PeriodIndex < 1 || *req.PeriodIndex > 4 {
+			errorMessages["period_index"] = "period_index must be the quarter, between 1 and 4"
+		}
+	}
+
+	if req.Target < 1 {
+		errorMessages["target"] = "target must be greater than 0"
+	}
+
+	return errorMessages
+}
+
+type updateGoalRequest struct {
+	Target int `json:"target"`
+}
+
+func (req *updateGoalRequest) validate() map[string]string {
+	errorMessages := make(map[string]string)
+
+	if req.Target < 1 {
+		errorMessages["target"] = "target must be greater than 0"
+	}
+
+	return errorMessages
+}
+
+// goalProgress is a goal along with how far the user is from reaching it.
+type goalProgress struct {
+	store.Goal
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	Progress    int       `json:"progress"`
+	Percent     float64   `json:"percent"`
+	Expected    float64   `json:"expected"`
+	Pace        string    `json:"pace"`
+}
+
+// userLocation is the time zone goal periods are evaluated in for the user.
+func userLocation(user *store.User) *time.Location {
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// evaluateGoal computes the progress of the goal in the time zone of the user
+// and records its completion the first time its target is reached. A goal
+// whose progress fell below its target again is no longer completed.
+func (h *GoalHandler) evaluateGoal(goal store.Goal, loc *time.Location) (*goalProgress, error) {
+	start, end := services.GoalPeriodBounds(goal.Period, goal.Year, goal.PeriodIndex, loc)
+
+	progress, err := h.store.GetGoalProgress(&goal, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	if progress >= goal.Target && goal.CompletedAt == nil {
+		if _, err := h.store.RecordCompletion(&goal, progress); err != nil {
+			return nil, err
+		}
+	}
+
+	if progress < goal.Target && goal.CompletedAt != nil {
+		if err := h.store.ClearCompletion(&goal); err != nil {
+			return nil, err
+		}
+	}
+
+	pace, expected := services.GoalPace(progress, goal.Target, start, end, time.Now().In(loc))
+
+	return &goalProgress{
+		Goal:        goal,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Progress:    progress,
+		Percent:     min(100, float64(progress)*100/float64(goal.Target)),
+		Expected:    expected,
+		Pace:        pace,
+	}, nil
+}
+
+func (h *GoalHandler) getUserGoal(w http.ResponseWriter, r *http.Request) *store.Goal {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid goal id"})
+		return nil
+	}
+
+	goal, err := h.store.GetGoalById(id)
+	if err != nil {
+		h.logger.Printf("ERROR: getting goal by id %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return nil
+	}
+
+	if goal == nil {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "goal not found"})
+		return nil
+	}
+
+	user := middleware.GetUser(r)
+	if goal.UserID != user.ID {
+		helpers.WriteJson(w, http.StatusForbidden, helpers.Envelop{"error": "you are not allowed to perform this action on this resource"})
+		return nil
+	}
+
+	return goal
+}
+
+func (h *GoalHandler) writeGoal(w http.ResponseWriter, r *http.Request, status int, goal *store.Goal) {
+	progress, err := h.evaluateGoal(*goal, userLocation(middleware.GetUser(r)))
+	if err != nil {
+		h.logger.Printf("ERROR: evaluating goal %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, status, helpers.Envelop{"goal": progress})
+}
+
+func (h *GoalHandler) HandleCreateGoal(w http.ResponseWriter, r *http.Request) {
+	var req createGoalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding create goal request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	periodIndex := 1
+	if req.Period != store.GoalPeriodYear {
+		periodIndex = *req.PeriodIndex
+	}
+
+	user := middleware.GetUser(r)
+	goal := &store.Goal{
+		UserID:      user.ID,
+		Metric:      req.Metric,
+		Period:      req.Period,
+		Year:        req.Year,
+		PeriodIndex: periodIndex,
+		Target:      req.Target,
+	}
+
+	if err := h.store.CreateGoal(goal); err != nil {
+		if store.IsUniqueViolation(err) {
+			helpers.WriteJson(w, http.StatusConflict, helpers.Envelop{"error": "a goal already exists for this metric and period"})
+			return
+		}
+
+		h.logger.Printf("ERROR: creating goal %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	h.writeGoal(w, r, http.StatusCreated, goal)
+}
+
+// HandleGetGoals lists the goals of the user with their progress and pace.
+func (h *GoalHandler) HandleGetGoals(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	goals, err := h.store.GetGoals(user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting goals %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	loc := userLocation(user)
+	progresses := make([]goalProgress, 0, len(goals))
+	for _, goal := range goals {
+		progress, err := h.evaluateGoal(goal, loc)
+		if err != nil {
+			h.logger.Printf("ERROR: evaluating goal %v", err)
+			helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+			return
+		}
+
+		progresses = append(progresses, *progress)
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"goals": progresses, "timezone": loc.String()})
+}
+
+func (h *GoalHandler) HandleGetGoal(w http.ResponseWriter, r *http.Request) {
+	goal := h.getUserGoal(w, r)
+	if goal == nil {
+		return
+	}
+
+	h.writeGoal(w, r, http.StatusOK, goal)
+}
+
+func (h *GoalHandler) HandleUpdateGoal(w http.ResponseWriter, r *http.Request) {
+	goal := h.getUserGoal(w, r)
+	if goal == nil {
+		return
+	}
+
+	var req updateGoalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding update goal request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	goal.Target = req.Target
+	if err := h.store.UpdateGoal(goal); err != nil {
+		h.logger.Printf("ERROR: updating goal %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	h.writeGoal(w, r, http.StatusOK, goal)
+}
+
+func (h *GoalHandler) HandleDeleteGoal(w http.ResponseWriter, r *http.Request) {
+	goal := h.getUserGoal(w, r)
+	if goal == nil {
+		return
+	}
+
+	if err := h.store.DeleteGoal(goal.ID); err != nil {
+		h.logger.Printf("ERROR: deleting goal %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleGetGoalEvents returns the goal completions of the user, optionally
+// only those recorded after the RFC 3339 since query parameter. Goals are
+// evaluated first so that completions are recorded even if the client did not
+// list the goals in between.
+func (h *GoalHandler) HandleGetGoalEvents(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	var since *time.Time
+	if value := r.URL.Query().Get("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": map[string]string{"since": "since must be an RFC 3339 timestamp"}})
+			return
+		}
+
+		since = &parsed
+	}
+
+	goals, err := h.store.GetGoals(user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting goals %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	loc := userLocation(user)
+	for _, goal := range goals {
+		if goal.CompletedAt != nil {
+			continue
+		}
+
+		if _, err := h.evaluateGoal(goal, loc); err != nil {
+			h.logger.Printf("ERROR: evaluating goal %v", err)
+			helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+			return
+		}
+	}
+
+	events, err := h.store.GetGoalEvents(user.ID, since)
+	if err != nil {
+		h.logger.Printf("ERROR: getting goal events %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"events": events})
+}
@@ -0,0 +1,287 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/martialanouman/personal-library/internal/helpers"
+	"github.com/martialanouman/personal-library/internal/middleware"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+type LoanHandler struct {
+	store     store.LoanStore
+	bookStore store.BookStore
+	logger    *log.Logger
+}
+
+func NewLoanHandler(store store.LoanStore, bookStore store.BookStore, logger *log.Logger) LoanHandler {
+	return LoanHandler{store: store, bookStore: bookStore, logger: logger}
+}
+
+type createLoanRequest struct {
+	Direction           string  `json:"direction"`
+	CounterpartyName    string  `json:"counterparty_name"`
+	CounterpartyContact *string `json:"counterparty_contact,omitempty"`
+	LoanedAt            *string `json:"loaned_at,omitempty"`
+	DueAt               *string `json:"due_at,omitempty"`
+}
+
+func (req *createLoanRequest) validate() map[string]string {
+	errorMessages := make(map[string]string)
+
+	if !slices.Contains(store.LoanDirections, req.Direction) {
+		errorMessages["direction"] = fmt.Sprintf("direction must be one of %s", strings.Join(store.LoanDirections, ", "))
+	}
+
+	req.CounterpartyName = strings.TrimSpace(req.CounterpartyName)
+	if req.CounterpartyName == "" {
+		errorMessages["counterparty_name"] = "counterparty_name is required"
+	} else if len(req.CounterpartyName) > 255 {
+		errorMessages["counterparty_name"] = "counterparty_name must be at most 255 characters"
+	}
+
+	if req.CounterpartyContact != nil && len(*req.CounterpartyContact) > 255 {
+		errorMessages["counterparty_contact"] = "counterparty_contact must be at most 255 characters"
+	}
+
+	if req.LoanedAt != nil {
+		if _, err := time.Parse(time.DateOnly, *req.LoanedAt); err != nil {
+			errorMessages["loaned_at"] = "loaned_at must be in YYYY-MM-DD format"
+		}
+	}
+
+	if req.DueAt != nil {
+		if _, err := time.Parse(time.DateOnly, *req.DueAt); err != nil {
+			errorMessages["due_at"] = "due_at must be in YYYY-MM-DD format"
+		}
+	}
+
+	return errorMessages
+}
+
+type returnLoanRequest struct {
+	ReturnedAt *string `json:"returned_at,omitempty"`
+}
+
+func (req *returnLoanRequest) validate() map[string]string {
+	errorMessages := make(map[string]string)
+
+	if req.ReturnedAt != nil {
+		if _, err := time.Parse(time.DateOnly, *req.ReturnedAt); err != nil {
+			errorMessages["returned_at"] = "returned_at must be in YYYY-MM-DD format"
+		}
+	}
+
+	return errorMessages
+}
+
+// today returns the current date in the time zone of the user.
+func today(user *store.User) time.Time {
+	now := time.Now().In(userLocation(user))
+
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func (h *LoanHandler) getBookLoan(w http.ResponseWriter, r *http.Request, book *store.Book) *store.Loan {
+	loanId := chi.URLParam(r, "loanId")
+	if loanId == "" {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid loan id"})
+		return nil
+	}
+
+	loan, err := h.store.GetLoanById(loanId)
+	if err != nil {
+		h.logger.Printf("ERROR: getting loan by id %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return nil
+	}
+
+	if loan == nil || loan.BookID != book.ID {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "loan not found"})
+		return nil
+	}
+
+	return loan
+}
+
+func (h *LoanHandler) HandleGetBookLoans(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	loans, err := h.store.GetBookLoans(book.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting book loans %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"loans": loans})
+}
+
+func (h *LoanHandler) HandleCreateLoan(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	var req createLoanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding loan request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	loan := &store.Loan{
+		BookID:              book.ID,
+		BookTitle:           book.Title,
+		UserID:              user.ID,
+		Direction:           req.Direction,
+		CounterpartyName:    req.CounterpartyName,
+		CounterpartyContact: req.CounterpartyContact,
+		LoanedAt:            today(user),
+		DueAt:               parseOptionalDate(req.DueAt),
+	}
+
+	if loanedAt := parseOptionalDate(req.LoanedAt); loanedAt != nil {
+		loan.LoanedAt = *loanedAt
+	}
+
+	if loan.DueAt != nil && loan.DueAt.Before(loan.LoanedAt) {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": map[string]string{"due_at": "due_at cannot be before loaned_at"}})
+		return
+	}
+
+	if err := h.store.CreateLoan(loan); err != nil {
+		if store.IsUniqueViolation(err) {
+			helpers.WriteJson(w, http.StatusConflict, helpers.Envelop{"error": "this book is already out on loan"})
+			return
+		}
+
+		h.logger.Printf("ERROR: creating loan %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusCreated, helpers.Envelop{"loan": loan})
+}
+
+func (h *LoanHandler) HandleReturnLoan(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	loan := h.getBookLoan(w, r, book)
+	if loan == nil {
+		return
+	}
+
+	if loan.ReturnedAt != nil {
+		helpers.WriteJson(w, http.StatusConflict, helpers.Envelop{"error": "this loan is already returned"})
+		return
+	}
+
+	var req returnLoanRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.logger.Printf("ERROR: decoding return loan request %v", err)
+			helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+			return
+		}
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	returnedAt := parseOptionalDate(req.ReturnedAt)
+	if returnedAt == nil {
+		now := today(middleware.GetUser(r))
+		returnedAt = &now
+	}
+
+	if returnedAt.Before(loan.LoanedAt) {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": map[string]string{"returned_at": "returned_at cannot be before loaned_at"}})
+		return
+	}
+
+	loan.ReturnedAt = returnedAt
+	if err := h.store.ReturnLoan(loan); err != nil {
+		h.logger.Printf("ERROR: returning loan %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"loan": loan})
+}
+
+func (h *LoanHandler) HandleDeleteLoan(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	loan := h.getBookLoan(w, r, book)
+	if loan == nil {
+		return
+	}
+
+	if err := h.store.DeleteLoan(loan.ID); err != nil {
+		h.logger.Printf("ERROR: deleting loan %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleGetLoans lists the loans not returned yet, optionally filtered with the
+// direction= query parameter.
+func (h *LoanHandler) HandleGetLoans(w http.ResponseWriter, r *http.Request) {
+	var direction *string
+	if d := r.URL.Query().Get("direction"); d != "" {
+		if !slices.Contains(store.LoanDirections, d) {
+			helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": fmt.Sprintf("direction must be one of %s", strings.Join(store.LoanDirections, ", "))})
+			return
+		}
+		direction = &d
+	}
+
+	user := middleware.GetUser(r)
+	loans, err := h.store.GetOpenLoans(user.ID, direction)
+	if err != nil {
+		h.logger.Printf("ERROR: getting open loans %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"loans": loans})
+}
+
+func (h *LoanHandler) HandleGetOverdueLoans(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	loans, err := h.store.GetOverdueLoans(user.ID, today(user))
+	if err != nil {
+		h.logger.Printf("ERROR: getting overdue loans %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"loans": loans})
+}
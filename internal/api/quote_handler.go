@@ -0,0 +1,442 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/martialanouman/personal-library/internal/helpers"
+	"github.com/martialanouman/personal-library/internal/middleware"
+	"github.com/martialanouman/personal-library/internal/services"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+const maxClippingsSize = 10 << 20
+
+type QuoteHandler struct {
+	store     store.QuoteStore
+	bookStore store.BookStore
+	tagStore  store.TagStore
+	logger    *log.Logger
+}
+
+func NewQuoteHandler(store store.QuoteStore, bookStore store.BookStore, tagStore store.TagStore, logger *log.Logger) QuoteHandler {
+	return QuoteHandler{store: store, bookStore: bookStore, tagStore: tagStore, logger: logger}
+}
+
+type quoteRequest struct {
+	Text     *string `json:"text,omitempty"`
+	Page     *int    `json:"page,omitempty"`
+	Location *string `json:"location,omitempty"`
+	Note     *string `json:"note,omitempty"`
+}
+
+func (req *quoteRequest) validate(creating bool) map[string]string {
+	errorMessages := make(map[string]string)
+
+	if req.Text == nil {
+		if creating {
+			errorMessages["text"] = "text is required"
+		}
+	} else if strings.TrimSpace(*req.Text) == "" {
+		errorMessages["text"] = "text cannot be empty"
+	} else if len(*req.Text) > 10000 {
+		errorMessages["text"] = "text must be at most 10000 characters"
+	}
+
+	if req.Page != nil && *req.Page < 1 {
+		errorMessages["page"] = "page must be greater than 0"
+	}
+
+	if req.Location != nil && len(*req.Location) > 64 {
+		errorMessages["location"] = "location must be at most 64 characters"
+	}
+
+	return errorMessages
+}
+
+func (req *quoteRequest) toQuote(quote *store.Quote) *store.Quote {
+	if req.Text != nil {
+		quote.Text = strings.TrimSpace(*req.Text)
+	}
+
+	if req.Page != nil {
+		quote.Page = req.Page
+	}
+
+	if req.Location != nil {
+		quote.Location = req.Location
+	}
+
+	if req.Note != nil {
+		quote.Note = req.Note
+	}
+
+	return quote
+}
+
+func (h *QuoteHandler) getBookQuote(w http.ResponseWriter, r *http.Request, book *store.Book) *store.Quote {
+	quoteId := chi.URLParam(r, "quoteId")
+	if quoteId == "" {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid quote id"})
+		return nil
+	}
+
+	quote, err := h.store.GetQuoteById(quoteId)
+	if err != nil {
+		h.logger.Printf("ERROR: getting quote by id %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return nil
+	}
+
+	if quote == nil || quote.BookID != book.ID {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "quote not found"})
+		return nil
+	}
+
+	return quote
+}
+
+func (h *QuoteHandler) HandleGetBookQuotes(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	quotes, err := h.store.GetBookQuotes(book.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting book quotes %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"quotes": quotes})
+}
+
+func (h *QuoteHandler) HandleCreateQuote(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	var req quoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding quote request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(true); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	quote := req.toQuote(&store.Quote{BookID: book.ID, UserID: user.ID})
+
+	if err := h.store.CreateQuote(quote); err != nil {
+		if store.IsUniqueViolation(err) {
+			helpers.WriteJson(w, http.StatusConflict, helpers.Envelop{"error": "this quote is already saved for this book"})
+			return
+		}
+
+		h.logger.Printf("ERROR: creating quote %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusCreated, helpers.Envelop{"quote": quote})
+}
+
+func (h *QuoteHandler) HandleUpdateQuote(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	quote := h.getBookQuote(w, r, book)
+	if quote == nil {
+		return
+	}
+
+	var req quoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding quote request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(false); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	quote = req.toQuote(quote)
+	if err := h.store.UpdateQuote(quote); err != nil {
+		if store.IsUniqueViolation(err) {
+			helpers.WriteJson(w, http.StatusConflict, helpers.Envelop{"error": "this quote is already saved for this book"})
+			return
+		}
+
+		h.logger.Printf("ERROR: updating quote %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"quote": quote})
+}
+
+func (h *QuoteHandler) HandleDeleteQuote(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	quote := h.getBookQuote(w, r, book)
+	if quote == nil {
+		return
+	}
+
+	if err := h.store.DeleteQuote(quote.ID); err != nil {
+		h.logger.Printf("ERROR: deleting quote %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *QuoteHandler) HandleSearchQuotes(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	pagination := middleware.GetPagination(r)
+
+	q := r.URL.Query()
+	filters := store.QuoteFilters{Query: strings.TrimSpace(q.Get("q"))}
+	filters.Tags, filters.TagMode = tagFiltersFromQuery(q)
+
+	quotes, err := h.store.SearchQuotes(user.ID, filters, pagination.Page, pagination.Take)
+	if err != nil {
+		h.logger.Printf("ERROR: searching quotes %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	count, err := h.store.SearchQuotesCount(user.ID, filters)
+	if err != nil {
+		h.logger.Printf("ERROR: getting quotes count %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"quotes": quotes, "count": count, "page": pagination.Page, "take": pagination.Take})
+}
+
+// HandleGetQuoteOfTheDay answers with a quote of the library of the user that
+// stays the same until midnight in their time zone.
+func (h *QuoteHandler) HandleGetQuoteOfTheDay(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	day := time.Now().In(userLocation(user)).Format(time.DateOnly)
+
+	quote, err := h.store.GetQuoteOfTheDay(user.ID, day)
+	if err != nil {
+		h.logger.Printf("ERROR: getting quote of the day %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	if quote == nil {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "no quote saved yet"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"quote": quote, "day": day})
+}
+
+// normalizeTitle lowercases the title and keeps only its letters and digits,
+// so that Kindle titles can be compared with the ones of the library.
+func normalizeTitle(title string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ")
+}
+
+// matchBook finds the book of the library a Kindle title refers to. Kindle
+// titles often carry a subtitle or a series name, so a library title starting
+// the Kindle one matches too, the longest such title winning.
+func matchBook(books []store.Book, title string) *store.Book {
+	normalized := normalizeTitle(title)
+
+	var match *store.Book
+	matchLength := 0
+	for i := range books {
+		candidate := normalizeTitle(books[i].Title)
+		if candidate == "" {
+			continue
+		}
+
+		if candidate == normalized {
+			return &books[i]
+		}
+
+		if strings.HasPrefix(normalized, candidate+" ") && len(candidate) > matchLength {
+			match, matchLength = &books[i], len(candidate)
+		}
+	}
+
+	return match
+}
+
+// HandleImportKindleClippings imports the highlights of a Kindle "My
+// Clippings.txt" file sent as the file field of a multipart form. Highlights
+// of books missing from the library are reported as unmatched, the ones
+// already saved as duplicates and the entries that could not be read as
+// skipped.
+func (h *QuoteHandler) HandleImportKindleClippings(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxClippingsSize)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "a clippings file is required in the file field"})
+		return
+	}
+	defer file.Close()
+
+	highlights, skipped, err := services.ParseKindleClippings(file)
+	if err != nil {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid clippings file"})
+		return
+	}
+
+	if len(highlights) == 0 && skipped > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"error": "none of the clippings could be read, the language of the Kindle may not be supported"})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	books, err := h.bookStore.GetUserBooks(user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting user books %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	quotes := []store.Quote{}
+	unmatched := []string{}
+	for _, highlight := range highlights {
+		book := matchBook(books, highlight.Title)
+		if book == nil {
+			if !slices.Contains(unmatched, highlight.Title) {
+				unmatched = append(unmatched, highlight.Title)
+			}
+			continue
+		}
+
+		quotes = append(quotes, store.Quote{
+			BookID:   book.ID,
+			UserID:   user.ID,
+			Text:     highlight.Text,
+			Page:     highlight.Page,
+			Location: highlight.Location,
+			Note:     highlight.Note,
+		})
+	}
+
+	imported, err := h.store.ImportQuotes(quotes)
+	if err != nil {
+		h.logger.Printf("ERROR: importing quotes %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{
+		"imported":   imported,
+		"duplicates": len(quotes) - imported,
+		"unmatched":  unmatched,
+		"skipped":    skipped,
+	})
+}
+
+func (h *QuoteHandler) HandleGetQuoteTags(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	quote := h.getBookQuote(w, r, book)
+	if quote == nil {
+		return
+	}
+
+	tags, err := h.tagStore.GetQuoteTags(quote.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting quote tags %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"tags": tags})
+}
+
+func (h *QuoteHandler) HandleAddQuoteTags(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	quote := h.getBookQuote(w, r, book)
+	if quote == nil {
+		return
+	}
+
+	var req addTagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding add tags request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	tags, err := h.tagStore.AddQuoteTags(quote.ID, quote.UserID, req.Tags)
+	if err != nil {
+		h.logger.Printf("ERROR: adding quote tags %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"tags": tags})
+}
+
+func (h *QuoteHandler) HandleRemoveQuoteTag(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	quote := h.getBookQuote(w, r, book)
+	if quote == nil {
+		return
+	}
+
+	if err := h.tagStore.RemoveQuoteTag(quote.ID, chi.URLParam(r, "tagId")); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "tag not found on this quote"})
+			return
+		}
+
+		h.logger.Printf("ERROR: removing quote tag %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,304 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/martialanouman/personal-library/internal/helpers"
+	"github.com/martialanouman/personal-library/internal/middleware"
+	"github.com/martialanouman/personal-library/internal/services"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+type ReadingSessionHandler struct {
+	store     store.ReadingSessionStore
+	bookStore store.BookStore
+	logger    *log.Logger
+}
+
+func NewReadingSessionHandler(store store.ReadingSessionStore, bookStore store.BookStore, logger *log.Logger) ReadingSessionHandler {
+	return ReadingSessionHandler{store: store, bookStore: bookStore, logger: logger}
+}
+
+type readingSessionRequest struct {
+	StartedAt    *string  `json:"started_at,omitempty"`
+	EndedAt      *string  `json:"ended_at,omitempty"`
+	StartPage    *int     `json:"start_page,omitempty"`
+	EndPage      *int     `json:"end_page,omitempty"`
+	StartPercent *float64 `json:"start_percent,omitempty"`
+	EndPercent   *float64 `json:"end_percent,omitempty"`
+	Notes        *string  `json:"notes,omitempty"`
+}
+
+func (req *readingSessionRequest) validate() map[string]string {
+	errorMessages := make(map[string]string)
+
+	if req.StartedAt != nil {
+		if _, err := time.Parse(time.RFC3339, *req.StartedAt); err != nil {
+			errorMessages["started_at"] = "started_at must be an RFC 3339 timestamp"
+		}
+	}
+
+	if req.EndedAt != nil {
+		if _, err := time.Parse(time.RFC3339, *req.EndedAt); err != nil {
+			errorMessages["ended_at"] = "ended_at must be an RFC 3339 timestamp"
+		}
+	}
+
+	if req.StartPage != nil && *req.StartPage < 0 {
+		errorMessages["start_page"] = "start_page cannot be negative"
+	}
+
+	if req.EndPage != nil && *req.EndPage < 0 {
+		errorMessages["end_page"] = "end_page cannot be negative"
+	}
+
+	if req.StartPercent != nil && (*req.StartPercent < 0 || *req.StartPercent > 100) {
+		errorMessages["start_percent"] = "start_percent must be between 0 and 100"
+	}
+
+	if req.EndPercent != nil && (*req.EndPercent < 0 || *req.EndPercent > 100) {
+		errorMessages["end_percent"] = "end_percent must be between 0 and 100"
+	}
+
+	return errorMessages
+}
+
+func (req *readingSessionRequest) toSession(session *store.ReadingSession) *store.ReadingSession {
+	if req.StartedAt != nil {
+		session.StartedAt, _ = time.Parse(time.RFC3339, *req.StartedAt)
+	}
+
+	if req.EndedAt != nil {
+		endedAt, _ := time.Parse(time.RFC3339, *req.EndedAt)
+		session.EndedAt = &endedAt
+	}
+
+	if req.StartPage != nil {
+		session.StartPage = req.StartPage
+	}
+
+	if req.EndPage != nil {
+		session.EndPage = req.EndPage
+	}
+
+	if req.StartPercent != nil {
+		session.StartPercent = req.StartPercent
+	}
+
+	if req.EndPercent != nil {
+		session.EndPercent = req.EndPercent
+	}
+
+	if req.Notes != nil {
+		session.Notes = req.Notes
+	}
+
+	return session
+}
+
+// validateSession checks that the session, once the payload is applied, is
+// consistent with itself and with the book it belongs to.
+func validateSession(session *store.ReadingSession, book *store.Book) map[string]string {
+	errorMessages := make(map[string]string)
+
+	if session.EndedAt != nil && session.EndedAt.Before(session.StartedAt) {
+		errorMessages["ended_at"] = "ended_at cannot be before started_at"
+	}
+
+	if session.StartPage != nil && session.EndPage != nil && *session.EndPage < *session.StartPage {
+		errorMessages["end_page"] = "end_page cannot be before start_page"
+	}
+
+	if session.StartPercent != nil && session.EndPercent != nil && *session.EndPercent < *session.StartPercent {
+		errorMessages["end_percent"] = "end_percent cannot be lower than start_percent"
+	}
+
+	if book.PageCount != nil {
+		if session.StartPage != nil && *session.StartPage > *book.PageCount {
+			errorMessages["start_page"] = "start_page cannot be after the last page of the book"
+		}
+
+		if session.EndPage != nil && *session.EndPage > *book.PageCount {
+			errorMessages["end_page"] = "end_page cannot be after the last page of the book"
+		}
+	}
+
+	return errorMessages
+}
+
+func (h *ReadingSessionHandler) getBookSession(w http.ResponseWriter, r *http.Request, book *store.Book) *store.ReadingSession {
+	sessionId := chi.URLParam(r, "sessionId")
+	if sessionId == "" {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid session id"})
+		return nil
+	}
+
+	session, err := h.store.GetSessionById(sessionId)
+	if err != nil {
+		h.logger.Printf("ERROR: getting reading session by id %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return nil
+	}
+
+	if session == nil || session.BookID != book.ID {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "reading session not found"})
+		return nil
+	}
+
+	return session
+}
+
+// writeSessionWithBook answers with the session and the book it belongs to,
+// whose progress reflects the session. Reaching the end of a book that is not
+// marked as read yet suggests to do so.
+func (h *ReadingSessionHandler) writeSessionWithBook(w http.ResponseWriter, status int, session *store.ReadingSession) {
+	book, err := h.bookStore.GetBookById(session.BookID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting book by id %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	envelop := helpers.Envelop{"session": session, "book": book}
+	if book.Status != "read" && book.Progress != nil && *book.Progress >= 100 {
+		envelop["suggested_status"] = "read"
+	}
+
+	helpers.WriteJson(w, status, envelop)
+}
+
+func (h *ReadingSessionHandler) HandleCreateSession(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	var req readingSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding reading session request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	session := req.toSession(&store.ReadingSession{
+		BookID:    book.ID,
+		UserID:    user.ID,
+		StartedAt: time.Now(),
+	})
+
+	if validationErrors := validateSession(session, book); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	// Logging the first session of a book means the user started reading it.
+	var startedBook *store.Book
+	if book.Status == store.BookStatusToRead {
+		reading := store.BookStatusReading
+		change := services.BookStatusChange{Status: &reading, DateStarted: &session.StartedAt}
+		if validationErrors := services.ApplyBookStatusChange(book, change, time.Now()); len(validationErrors) > 0 {
+			helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+			return
+		}
+
+		startedBook = book
+	}
+
+	if err := h.store.CreateSession(session, startedBook, revisionActor(r)); err != nil {
+		if errors.Is(err, store.ErrVersionConflict) {
+			writeVersionConflict(w, r)
+			return
+		}
+
+		h.logger.Printf("ERROR: creating reading session %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	h.writeSessionWithBook(w, http.StatusCreated, session)
+}
+
+func (h *ReadingSessionHandler) HandleGetSessions(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	sessions, err := h.store.GetBookSessions(book.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting reading sessions %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"sessions": sessions, "progress": book.Progress})
+}
+
+func (h *ReadingSessionHandler) HandleUpdateSession(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	session := h.getBookSession(w, r, book)
+	if session == nil {
+		return
+	}
+
+	var req readingSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding reading session request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	session = req.toSession(session)
+	if validationErrors := validateSession(session, book); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	if err := h.store.UpdateSession(session); err != nil {
+		h.logger.Printf("ERROR: updating reading session %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	h.writeSessionWithBook(w, http.StatusOK, session)
+}
+
+func (h *ReadingSessionHandler) HandleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	session := h.getBookSession(w, r, book)
+	if session == nil {
+		return
+	}
+
+	if err := h.store.DeleteSession(session.ID); err != nil {
+		h.logger.Printf("ERROR: deleting reading session %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/martialanouman/personal-library/internal/helpers"
+	"github.com/martialanouman/personal-library/internal/middleware"
+	"github.com/martialanouman/personal-library/internal/services"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+const (
+	defaultRecommendationsLimit = 10
+	maxRecommendationsLimit     = 50
+)
+
+type RecommendationHandler struct {
+	bookStore     store.BookStore
+	wishlistStore store.WishlistStore
+	recommender   *services.Recommender
+	logger        *log.Logger
+}
+
+func NewRecommendationHandler(bookStore store.BookStore, wishlistStore store.WishlistStore, recommender *services.Recommender, logger *log.Logger) RecommendationHandler {
+	return RecommendationHandler{
+		bookStore:     bookStore,
+		wishlistStore: wishlistStore,
+		recommender:   recommender,
+		logger:        logger,
+	}
+}
+
+func (h *RecommendationHandler) HandleGetRecommendations(w http.ResponseWriter, r *http.Request) {
+	limit, message := limitParam(r, defaultRecommendationsLimit, maxRecommendationsLimit)
+	if message != "" {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": map[string]string{"limit": message}})
+		return
+	}
+
+	user := middleware.GetUser(r)
+
+	books, err := h.bookStore.GetUserBooks(user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting user books %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	wishes, err := h.wishlistStore.GetUserWishes(user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting user wishes %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	recommendations := h.recommender.Recommend(books, wishes, limit)
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"recommendations": recommendations})
+}
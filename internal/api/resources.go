@@ -0,0 +1,200 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/martialanouman/personal-library/internal/helpers"
+	"github.com/martialanouman/personal-library/internal/middleware"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+// nullable is a JSON field that tells an absent value from an explicit null:
+// Set is true whenever the field is present, and Value is nil for null.
+type nullable[T any] struct {
+	Set   bool
+	Value *T
+}
+
+func (n *nullable[T]) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	n.Value = nil
+	if string(data) == "null" {
+		return nil
+	}
+
+	return json.Unmarshal(data, &n.Value)
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{12}$`)
+
+// invalidIds returns the ids that are not UUIDs, which the database would
+// refuse to compare with its ids.
+func invalidIds(ids []string) []string {
+	invalid := []string{}
+	for _, id := range ids {
+		if !uuidPattern.MatchString(id) {
+			invalid = append(invalid, id)
+		}
+	}
+
+	return invalid
+}
+
+// limitParam reads the limit query parameter, fallback when it is missing. It
+// returns an error message when the limit is not a number between 1 and
+// maximum.
+func limitParam(r *http.Request, fallback, maximum int) (int, string) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return fallback, ""
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maximum {
+		return 0, fmt.Sprintf("limit must be between 1 and %d", maximum)
+	}
+
+	return limit, ""
+}
+
+// getUserBook loads the book identified by the id URL parameter and makes
+// sure it belongs to the current user. It writes the error response and
+// returns nil otherwise.
+func getUserBook(w http.ResponseWriter, r *http.Request, bookStore store.BookStore, logger *log.Logger) *store.Book {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid book id"})
+		return nil
+	}
+
+	book, err := bookStore.GetBookById(id)
+	if err != nil {
+		logger.Printf("ERROR: getting book by id %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return nil
+	}
+
+	if book == nil {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "book not found"})
+		return nil
+	}
+
+	user := middleware.GetUser(r)
+	if book.UserId != user.ID {
+		helpers.WriteJson(w, http.StatusForbidden, helpers.Envelop{"error": "you are not allowed to perform this action on this resource"})
+		return nil
+	}
+
+	return book
+}
+
+// revisionActor returns the user and token of the request, recorded with the
+// changes it makes.
+func revisionActor(r *http.Request) store.RevisionActor {
+	tokenId := middleware.GetToken(r).ID()
+	return store.RevisionActor{UserID: middleware.GetUser(r).ID, TokenID: &tokenId}
+}
+
+// versionETag is the ETag of a book or wish at version.
+func versionETag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// checkIfMatch makes sure the If-Match header, when sent, lists etag. It
+// writes a 412 response and returns false otherwise. Weak ETags never match.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		if candidate = strings.TrimSpace(candidate); candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	helpers.WriteJson(w, http.StatusPreconditionFailed, helpers.Envelop{"error": "the resource was changed since it was read"})
+	return false
+}
+
+// writeVersionConflict answers a save that lost the race with another one:
+// with a 412 when the client sent If-Match, with a 409 otherwise.
+func writeVersionConflict(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusConflict
+	if r.Header.Get("If-Match") != "" {
+		status = http.StatusPreconditionFailed
+	}
+
+	helpers.WriteJson(w, status, helpers.Envelop{"error": "the resource was changed since it was read"})
+}
+
+// getUserWish is the wishlist counterpart of getUserBook.
+func getUserWish(w http.ResponseWriter, r *http.Request, wishlistStore store.WishlistStore, logger *log.Logger) *store.Wish {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid wish id"})
+		return nil
+	}
+
+	wish, err := wishlistStore.GetWishById(id)
+	if err != nil {
+		logger.Printf("ERROR: getting wish %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return nil
+	}
+
+	if wish == nil {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "wish not found"})
+		return nil
+	}
+
+	user := middleware.GetUser(r)
+	if wish.UserID != user.ID {
+		helpers.WriteJson(w, http.StatusForbidden, helpers.Envelop{"error": "you are not allowed to perform this action on this resource"})
+		return nil
+	}
+
+	return wish
+}
+
+// checkUserSeries makes sure the series referenced by a payload, if any,
+// belongs to the current user. It writes the error response and returns false
+// otherwise.
+func checkUserSeries(w http.ResponseWriter, r *http.Request, seriesStore store.SeriesStore, logger *log.Logger, seriesId *string) bool {
+	user := middleware.GetUser(r)
+	ok, err := isUserSeries(seriesStore, user.ID, seriesId)
+	if err != nil {
+		logger.Printf("ERROR: getting series by id %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return false
+	}
+
+	if !ok {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": map[string]string{"series_id": "series not found"}})
+		return false
+	}
+
+	return true
+}
+
+// isUserSeries tells whether the series, when given, belongs to the user.
+func isUserSeries(seriesStore store.SeriesStore, userId string, seriesId *string) (bool, error) {
+	if seriesId == nil {
+		return true, nil
+	}
+
+	series, err := seriesStore.GetSeriesById(*seriesId)
+	if err != nil {
+		return false, err
+	}
+
+	return series != nil && series.UserID == userId, nil
+}
@@ -0,0 +1,332 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/martialanouman/personal-library/internal/helpers"
+	"github.com/martialanouman/personal-library/internal/middleware"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+type SeriesHandler struct {
+	store  store.SeriesStore
+	logger *log.Logger
+}
+
+func NewSeriesHandler(store store.SeriesStore, logger *log.Logger) SeriesHandler {
+	return SeriesHandler{store: store, logger: logger}
+}
+
+type createSeriesRequest struct {
+	Name         string `json:"name"`
+	TotalVolumes *int   `json:"total_volumes,omitempty"`
+}
+
+func (req *createSeriesRequest) validate() map[string]string {
+	errorMessages := make(map[string]string)
+
+	if req.Name == "" {
+		errorMessages["name"] = "name is required"
+	} else if len(req.Name) > 255 {
+		errorMessages["name"] = "name must be at most 255 characters"
+	}
+
+	if req.TotalVolumes != nil && *req.TotalVolumes < 1 {
+		errorMessages["total_volumes"] = "total_volumes must be greater than 0"
+	}
+
+	return errorMessages
+}
+
+type updateSeriesRequest struct {
+	Name         *string `json:"name,omitempty"`
+	TotalVolumes *int    `json:"total_volumes,omitempty"`
+}
+
+func (req *updateSeriesRequest) validate() map[string]string {
+	errorMessages := make(map[string]string)
+
+	if req.Name != nil {
+		if *req.Name == "" {
+			errorMessages["name"] = "name cannot be empty"
+		} else if len(*req.Name) > 255 {
+			errorMessages["name"] = "name must be at most 255 characters"
+		}
+	}
+
+	if req.TotalVolumes != nil && *req.TotalVolumes < 1 {
+		errorMessages["total_volumes"] = "total_volumes must be greater than 0"
+	}
+
+	return errorMessages
+}
+
+// seriesVolume groups what the user owns, read and wishes for at a given
+// position of a series.
+type seriesVolume struct {
+	Position float64      `json:"position"`
+	Owned    bool         `json:"owned"`
+	Read     bool         `json:"read"`
+	Wished   bool         `json:"wished"`
+	Books    []store.Book `json:"books,omitempty"`
+	Wishes   []store.Wish `json:"wishes,omitempty"`
+}
+
+type seriesOverview struct {
+	Volumes        []seriesVolume `json:"volumes"`
+	UnplacedBooks  []store.Book   `json:"unplaced_books,omitempty"`
+	UnplacedWishes []store.Wish   `json:"unplaced_wishes,omitempty"`
+}
+
+// buildSeriesOverview lays out the books and wishes of a series by position.
+// Known volumes the user neither owns nor wishes for are listed as well.
+func buildSeriesOverview(series *store.Series, books []store.Book, wishes []store.Wish) seriesOverview {
+	overview := seriesOverview{}
+	volumes := make(map[float64]*seriesVolume)
+
+	volumeAt := func(position float64) *seriesVolume {
+		volume, ok := volumes[position]
+		if !ok {
+			volume = &seriesVolume{Position: position}
+			volumes[position] = volume
+		}
+
+		return volume
+	}
+
+	if series.TotalVolumes != nil {
+		for i := 1; i <= *series.TotalVolumes; i++ {
+			volumeAt(float64(i))
+		}
+	}
+
+	for _, book := range books {
+		if book.SeriesPosition == nil {
+			overview.UnplacedBooks = append(overview.UnplacedBooks, book)
+			continue
+		}
+
+		volume := volumeAt(*book.SeriesPosition)
+		volume.Owned = true
+		volume.Read = volume.Read || book.Status == "read"
+		volume.Books = append(volume.Books, book)
+	}
+
+	for _, wish := range wishes {
+		if wish.SeriesPosition == nil {
+			overview.UnplacedWishes = append(overview.UnplacedWishes, wish)
+			continue
+		}
+
+		volume := volumeAt(*wish.SeriesPosition)
+		volume.Wished = true
+		volume.Wishes = append(volume.Wishes, wish)
+	}
+
+	overview.Volumes = make([]seriesVolume, 0, len(volumes))
+	for _, volume := range volumes {
+		overview.Volumes = append(overview.Volumes, *volume)
+	}
+
+	sort.Slice(overview.Volumes, func(i, j int) bool {
+		return overview.Volumes[i].Position < overview.Volumes[j].Position
+	})
+
+	return overview
+}
+
+func (h *SeriesHandler) getUserSeries(w http.ResponseWriter, r *http.Request) *store.Series {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid series id"})
+		return nil
+	}
+
+	series, err := h.store.GetSeriesById(id)
+	if err != nil {
+		h.logger.Printf("ERROR: getting series by id %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return nil
+	}
+
+	if series == nil {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "series not found"})
+		return nil
+	}
+
+	user := middleware.GetUser(r)
+	if series.UserID != user.ID {
+		helpers.WriteJson(w, http.StatusForbidden, helpers.Envelop{"error": "you are not allowed to perform this action on this resource"})
+		return nil
+	}
+
+	return series
+}
+
+func (h *SeriesHandler) getOverview(w http.ResponseWriter, series *store.Series) *seriesOverview {
+	books, err := h.store.GetSeriesBooks(series.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting series books %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return nil
+	}
+
+	wishes, err := h.store.GetSeriesWishes(series.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting series wishes %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return nil
+	}
+
+	overview := buildSeriesOverview(series, books, wishes)
+
+	return &overview
+}
+
+func (h *SeriesHandler) HandleCreateSeries(w http.ResponseWriter, r *http.Request) {
+	var req createSeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding create series request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	series := &store.Series{
+		UserID:       user.ID,
+		Name:         req.Name,
+		TotalVolumes: req.TotalVolumes,
+	}
+
+	if err := h.store.CreateSeries(series); err != nil {
+		if store.IsUniqueViolation(err) {
+			helpers.WriteJson(w, http.StatusConflict, helpers.Envelop{"error": "a series with this name already exists"})
+			return
+		}
+
+		h.logger.Printf("ERROR: creating series %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusCreated, helpers.Envelop{"series": series})
+}
+
+func (h *SeriesHandler) HandleGetUserSeries(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	series, err := h.store.GetUserSeries(user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting series %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"series": series})
+}
+
+func (h *SeriesHandler) HandleGetSeries(w http.ResponseWriter, r *http.Request) {
+	series := h.getUserSeries(w, r)
+	if series == nil {
+		return
+	}
+
+	overview := h.getOverview(w, series)
+	if overview == nil {
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{
+		"series":          series,
+		"volumes":         overview.Volumes,
+		"unplaced_books":  overview.UnplacedBooks,
+		"unplaced_wishes": overview.UnplacedWishes,
+	})
+}
+
+// HandleGetNextInSeries returns the first volume of the series the user has
+// not read yet, whether they own it, wish for it or neither.
+func (h *SeriesHandler) HandleGetNextInSeries(w http.ResponseWriter, r *http.Request) {
+	series := h.getUserSeries(w, r)
+	if series == nil {
+		return
+	}
+
+	overview := h.getOverview(w, series)
+	if overview == nil {
+		return
+	}
+
+	for _, volume := range overview.Volumes {
+		if !volume.Read {
+			helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"next": volume})
+			return
+		}
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"next": nil})
+}
+
+func (h *SeriesHandler) HandleUpdateSeries(w http.ResponseWriter, r *http.Request) {
+	series := h.getUserSeries(w, r)
+	if series == nil {
+		return
+	}
+
+	var req updateSeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding update series request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	if req.Name != nil {
+		series.Name = *req.Name
+	}
+
+	if req.TotalVolumes != nil {
+		series.TotalVolumes = req.TotalVolumes
+	}
+
+	if err := h.store.UpdateSeries(series); err != nil {
+		if store.IsUniqueViolation(err) {
+			helpers.WriteJson(w, http.StatusConflict, helpers.Envelop{"error": "a series with this name already exists"})
+			return
+		}
+
+		h.logger.Printf("ERROR: updating series %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"series": series})
+}
+
+func (h *SeriesHandler) HandleDeleteSeries(w http.ResponseWriter, r *http.Request) {
+	series := h.getUserSeries(w, r)
+	if series == nil {
+		return
+	}
+
+	if err := h.store.DeleteSeries(series.ID); err != nil {
+		h.logger.Printf("ERROR: deleting series %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
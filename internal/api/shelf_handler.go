@@ -0,0 +1,305 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/martialanouman/personal-library/internal/helpers"
+	"github.com/martialanouman/personal-library/internal/middleware"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+type ShelfHandler struct {
+	store  store.ShelfStore
+	logger *log.Logger
+}
+
+func NewShelfHandler(store store.ShelfStore, logger *log.Logger) ShelfHandler {
+	return ShelfHandler{store: store, logger: logger}
+}
+
+type createShelfRequest struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description,omitempty"`
+}
+
+func (req *createShelfRequest) validate() map[string]string {
+	errorMessages := make(map[string]string)
+
+	if req.Name == "" {
+		errorMessages["name"] = "name is required"
+	} else if len(req.Name) > 255 {
+		errorMessages["name"] = "name must be at most 255 characters"
+	}
+
+	return errorMessages
+}
+
+type updateShelfRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+func (req *updateShelfRequest) validate() map[string]string {
+	errorMessages := make(map[string]string)
+
+	if req.Name != nil {
+		if *req.Name == "" {
+			errorMessages["name"] = "name cannot be empty"
+		} else if len(*req.Name) > 255 {
+			errorMessages["name"] = "name must be at most 255 characters"
+		}
+	}
+
+	return errorMessages
+}
+
+type shelfBooksRequest struct {
+	BookIds []string `json:"book_ids"`
+}
+
+func (req *shelfBooksRequest) validate() map[string]string {
+	errorMessages := make(map[string]string)
+
+	if len(req.BookIds) == 0 {
+		errorMessages["book_ids"] = "book_ids must contain at least one book id"
+	} else if invalid := invalidIds(req.BookIds); len(invalid) > 0 {
+		errorMessages["book_ids"] = "invalid book ids: " + strings.Join(invalid, ", ")
+	}
+
+	return errorMessages
+}
+
+// getUserShelf loads the shelf from the URL and makes sure it belongs to the
+// current user. It writes the error response and returns nil otherwise.
+func (h *ShelfHandler) getUserShelf(w http.ResponseWriter, r *http.Request) *store.Shelf {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid shelf id"})
+		return nil
+	}
+
+	shelf, err := h.store.GetShelfById(id)
+	if err != nil {
+		h.logger.Printf("ERROR: getting shelf by id %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return nil
+	}
+
+	if shelf == nil {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "shelf not found"})
+		return nil
+	}
+
+	user := middleware.GetUser(r)
+	if shelf.UserID != user.ID {
+		helpers.WriteJson(w, http.StatusForbidden, helpers.Envelop{"error": "you are not allowed to perform this action on this resource"})
+		return nil
+	}
+
+	return shelf
+}
+
+func (h *ShelfHandler) HandleCreateShelf(w http.ResponseWriter, r *http.Request) {
+	var req createShelfRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding create shelf request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	shelf := &store.Shelf{
+		UserID:      user.ID,
+		Name:        req.Name,
+		Description: req.Description,
+	}
+
+	if err := h.store.CreateShelf(shelf); err != nil {
+		if store.IsUniqueViolation(err) {
+			helpers.WriteJson(w, http.StatusConflict, helpers.Envelop{"error": "a shelf with this name already exists"})
+			return
+		}
+
+		h.logger.Printf("ERROR: creating shelf %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusCreated, helpers.Envelop{"shelf": shelf})
+}
+
+func (h *ShelfHandler) HandleGetShelves(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	shelves, err := h.store.GetShelves(user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting shelves %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"shelves": shelves})
+}
+
+func (h *ShelfHandler) HandleGetShelf(w http.ResponseWriter, r *http.Request) {
+	shelf := h.getUserShelf(w, r)
+	if shelf == nil {
+		return
+	}
+
+	books, err := h.store.GetShelfBooks(shelf.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting shelf books %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"shelf": shelf, "books": books})
+}
+
+func (h *ShelfHandler) HandleUpdateShelf(w http.ResponseWriter, r *http.Request) {
+	shelf := h.getUserShelf(w, r)
+	if shelf == nil {
+		return
+	}
+
+	var req updateShelfRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding update shelf request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	if req.Name != nil {
+		shelf.Name = *req.Name
+	}
+
+	if req.Description != nil {
+		shelf.Description = req.Description
+	}
+
+	if err := h.store.UpdateShelf(shelf); err != nil {
+		if store.IsUniqueViolation(err) {
+			helpers.WriteJson(w, http.StatusConflict, helpers.Envelop{"error": "a shelf with this name already exists"})
+			return
+		}
+
+		h.logger.Printf("ERROR: updating shelf %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"shelf": shelf})
+}
+
+func (h *ShelfHandler) HandleDeleteShelf(w http.ResponseWriter, r *http.Request) {
+	shelf := h.getUserShelf(w, r)
+	if shelf == nil {
+		return
+	}
+
+	if err := h.store.DeleteShelf(shelf.ID); err != nil {
+		h.logger.Printf("ERROR: deleting shelf %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ShelfHandler) HandleAddBooks(w http.ResponseWriter, r *http.Request) {
+	shelf := h.getUserShelf(w, r)
+	if shelf == nil {
+		return
+	}
+
+	var req shelfBooksRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding shelf books request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	added, err := h.store.AddBooks(shelf.ID, shelf.UserID, req.BookIds)
+	if err != nil {
+		h.logger.Printf("ERROR: adding books to shelf %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"added": added})
+}
+
+func (h *ShelfHandler) HandleRemoveBooks(w http.ResponseWriter, r *http.Request) {
+	shelf := h.getUserShelf(w, r)
+	if shelf == nil {
+		return
+	}
+
+	var req shelfBooksRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding shelf books request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	removed, err := h.store.RemoveBooks(shelf.ID, req.BookIds)
+	if err != nil {
+		h.logger.Printf("ERROR: removing books from shelf %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"removed": removed})
+}
+
+func (h *ShelfHandler) HandleReorderBooks(w http.ResponseWriter, r *http.Request) {
+	shelf := h.getUserShelf(w, r)
+	if shelf == nil {
+		return
+	}
+
+	var req shelfBooksRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding shelf books request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	if err := h.store.ReorderBooks(shelf.ID, req.BookIds); err != nil {
+		h.logger.Printf("ERROR: reordering shelf books %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/martialanouman/personal-library/internal/helpers"
+	"github.com/martialanouman/personal-library/internal/middleware"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+type StatsHandler struct {
+	store  store.StatsStore
+	logger *log.Logger
+}
+
+func NewStatsHandler(store store.StatsStore, logger *log.Logger) StatsHandler {
+	return StatsHandler{store: store, logger: logger}
+}
+
+func (h *StatsHandler) HandleGetStats(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	stats, err := h.store.GetUserStats(user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting stats %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"stats": stats})
+}
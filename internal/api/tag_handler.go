@@ -0,0 +1,394 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/martialanouman/personal-library/internal/helpers"
+	"github.com/martialanouman/personal-library/internal/middleware"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+const (
+	defaultTagsLimit = 10
+	maxTagsLimit     = 100
+	maxTagLength     = 64
+)
+
+type TagHandler struct {
+	store         store.TagStore
+	bookStore     store.BookStore
+	wishlistStore store.WishlistStore
+	logger        *log.Logger
+}
+
+func NewTagHandler(store store.TagStore, bookStore store.BookStore, wishlistStore store.WishlistStore, logger *log.Logger) TagHandler {
+	return TagHandler{
+		store:         store,
+		bookStore:     bookStore,
+		wishlistStore: wishlistStore,
+		logger:        logger,
+	}
+}
+
+// normalizeTagName lowercases the name and collapses its whitespaces so that
+// "Science  Fiction" and "science fiction" end up being the same tag.
+func normalizeTagName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// tagFiltersFromQuery reads the repeated tag= parameters and the tag_mode=
+// parameter of a listing request.
+func tagFiltersFromQuery(q url.Values) ([]string, string) {
+	tags := []string{}
+	for _, value := range q["tag"] {
+		if name := normalizeTagName(value); name != "" && !slices.Contains(tags, name) {
+			tags = append(tags, name)
+		}
+	}
+
+	mode := store.TagModeAny
+	if q.Get("tag_mode") == store.TagModeAll {
+		mode = store.TagModeAll
+	}
+
+	return tags, mode
+}
+
+type addTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+func (req *addTagsRequest) validate() map[string]string {
+	errorMessages := make(map[string]string)
+
+	names := []string{}
+	for _, tag := range req.Tags {
+		name := normalizeTagName(tag)
+		if name == "" {
+			errorMessages["tags"] = "tags cannot be empty"
+			continue
+		}
+
+		if len(name) > maxTagLength {
+			errorMessages["tags"] = fmt.Sprintf("tags must be at most %d characters", maxTagLength)
+			continue
+		}
+
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	if len(req.Tags) == 0 {
+		errorMessages["tags"] = "tags must contain at least one tag"
+	}
+
+	req.Tags = names
+
+	return errorMessages
+}
+
+type renameTagRequest struct {
+	Name string `json:"name"`
+}
+
+func (req *renameTagRequest) validate() map[string]string {
+	errorMessages := make(map[string]string)
+
+	req.Name = normalizeTagName(req.Name)
+	if req.Name == "" {
+		errorMessages["name"] = "name is required"
+	} else if len(req.Name) > maxTagLength {
+		errorMessages["name"] = fmt.Sprintf("name must be at most %d characters", maxTagLength)
+	}
+
+	return errorMessages
+}
+
+type mergeTagsRequest struct {
+	SourceIds []string `json:"source_ids"`
+}
+
+func (req *mergeTagsRequest) validate(targetId string) map[string]string {
+	errorMessages := make(map[string]string)
+
+	if len(req.SourceIds) == 0 {
+		errorMessages["source_ids"] = "source_ids must contain at least one tag id"
+	}
+
+	if slices.Contains(req.SourceIds, targetId) {
+		errorMessages["source_ids"] = "a tag cannot be merged into itself"
+	}
+
+	return errorMessages
+}
+
+// getUserTag loads the tag with the given id and makes sure it belongs to the
+// current user. It writes the error response and returns nil otherwise.
+func (h *TagHandler) getUserTag(w http.ResponseWriter, r *http.Request, id string) *store.Tag {
+	tag, err := h.store.GetTagById(id)
+	if err != nil {
+		h.logger.Printf("ERROR: getting tag by id %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return nil
+	}
+
+	if tag == nil {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "tag not found"})
+		return nil
+	}
+
+	user := middleware.GetUser(r)
+	if tag.UserID != user.ID {
+		helpers.WriteJson(w, http.StatusForbidden, helpers.Envelop{"error": "you are not allowed to perform this action on this resource"})
+		return nil
+	}
+
+	return tag
+}
+
+func (h *TagHandler) HandleGetTags(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	prefix := normalizeTagName(q.Get("prefix"))
+
+	limit, message := limitParam(r, defaultTagsLimit, maxTagsLimit)
+	if message != "" {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": map[string]string{"limit": message}})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	tags, err := h.store.GetTags(user.ID, prefix, limit)
+	if err != nil {
+		h.logger.Printf("ERROR: getting tags %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"tags": tags})
+}
+
+func (h *TagHandler) HandleRenameTag(w http.ResponseWriter, r *http.Request) {
+	tag := h.getUserTag(w, r, chi.URLParam(r, "id"))
+	if tag == nil {
+		return
+	}
+
+	var req renameTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding rename tag request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	tag.Name = req.Name
+	if err := h.store.RenameTag(tag); err != nil {
+		if store.IsUniqueViolation(err) {
+			helpers.WriteJson(w, http.StatusConflict, helpers.Envelop{"error": "a tag with this name already exists, merge them instead"})
+			return
+		}
+
+		h.logger.Printf("ERROR: renaming tag %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"tag": tag})
+}
+
+func (h *TagHandler) HandleMergeTags(w http.ResponseWriter, r *http.Request) {
+	target := h.getUserTag(w, r, chi.URLParam(r, "id"))
+	if target == nil {
+		return
+	}
+
+	var req mergeTagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding merge tags request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(target.ID); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	for _, sourceId := range req.SourceIds {
+		if source := h.getUserTag(w, r, sourceId); source == nil {
+			return
+		}
+	}
+
+	if err := h.store.MergeTags(target.ID, req.SourceIds); err != nil {
+		h.logger.Printf("ERROR: merging tags %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	merged, err := h.store.GetTagById(target.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting tag by id %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"tag": merged})
+}
+
+func (h *TagHandler) HandleDeleteTag(w http.ResponseWriter, r *http.Request) {
+	tag := h.getUserTag(w, r, chi.URLParam(r, "id"))
+	if tag == nil {
+		return
+	}
+
+	if err := h.store.DeleteTag(tag.ID); err != nil {
+		h.logger.Printf("ERROR: deleting tag %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *TagHandler) HandleGetBookTags(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	tags, err := h.store.GetBookTags(book.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting book tags %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"tags": tags})
+}
+
+func (h *TagHandler) HandleAddBookTags(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	var req addTagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding add tags request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	tags, err := h.store.AddBookTags(book.ID, book.UserId, req.Tags)
+	if err != nil {
+		h.logger.Printf("ERROR: adding book tags %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"tags": tags})
+}
+
+func (h *TagHandler) HandleRemoveBookTag(w http.ResponseWriter, r *http.Request) {
+	book := getUserBook(w, r, h.bookStore, h.logger)
+	if book == nil {
+		return
+	}
+
+	if err := h.store.RemoveBookTag(book.ID, chi.URLParam(r, "tagId")); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "tag not found on this book"})
+			return
+		}
+
+		h.logger.Printf("ERROR: removing book tag %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *TagHandler) HandleGetWishTags(w http.ResponseWriter, r *http.Request) {
+	wish := getUserWish(w, r, h.wishlistStore, h.logger)
+	if wish == nil {
+		return
+	}
+
+	tags, err := h.store.GetWishTags(wish.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting wish tags %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"tags": tags})
+}
+
+func (h *TagHandler) HandleAddWishTags(w http.ResponseWriter, r *http.Request) {
+	wish := getUserWish(w, r, h.wishlistStore, h.logger)
+	if wish == nil {
+		return
+	}
+
+	var req addTagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding add tags request %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if validationErrors := req.validate(); len(validationErrors) > 0 {
+		helpers.WriteJson(w, http.StatusUnprocessableEntity, helpers.Envelop{"errors": validationErrors})
+		return
+	}
+
+	tags, err := h.store.AddWishTags(wish.ID, wish.UserID, req.Tags)
+	if err != nil {
+		h.logger.Printf("ERROR: adding wish tags %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"tags": tags})
+}
+
+func (h *TagHandler) HandleRemoveWishTag(w http.ResponseWriter, r *http.Request) {
+	wish := getUserWish(w, r, h.wishlistStore, h.logger)
+	if wish == nil {
+		return
+	}
+
+	if err := h.store.RemoveWishTag(wish.ID, chi.URLParam(r, "tagId")); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "tag not found on this wish"})
+			return
+		}
+
+		h.logger.Printf("ERROR: removing wish tag %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
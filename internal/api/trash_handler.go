@@ -0,0 +1,141 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/martialanouman/personal-library/internal/helpers"
+	"github.com/martialanouman/personal-library/internal/middleware"
+	"github.com/martialanouman/personal-library/internal/services"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+type TrashHandler struct {
+	store     store.TrashStore
+	covers    *services.CoverService
+	retention time.Duration
+	logger    *log.Logger
+}
+
+func NewTrashHandler(store store.TrashStore, covers *services.CoverService, retention time.Duration, logger *log.Logger) TrashHandler {
+	return TrashHandler{store: store, covers: covers, retention: retention, logger: logger}
+}
+
+// HandleGetTrash lists the deleted books and wishes of the user with the
+// time they will be purged at.
+func (h *TrashHandler) HandleGetTrash(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	items, err := h.store.GetTrash(user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting trash %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	for i := range items {
+		items[i].PurgeAt = items[i].DeletedAt.Add(h.retention)
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"items": items, "retention_days": int(h.retention / (24 * time.Hour))})
+}
+
+func (h *TrashHandler) HandleRestoreBook(w http.ResponseWriter, r *http.Request) {
+	h.restore(w, r, store.TrashItemBook)
+}
+
+func (h *TrashHandler) HandleRestoreWish(w http.ResponseWriter, r *http.Request) {
+	h.restore(w, r, store.TrashItemWish)
+}
+
+func (h *TrashHandler) HandlePurgeBook(w http.ResponseWriter, r *http.Request) {
+	h.purge(w, r, store.TrashItemBook)
+}
+
+func (h *TrashHandler) HandlePurgeWish(w http.ResponseWriter, r *http.Request) {
+	h.purge(w, r, store.TrashItemWish)
+}
+
+func (h *TrashHandler) restore(w http.ResponseWriter, r *http.Request, itemType string) {
+	item := h.getUserTrashItem(w, r, itemType)
+	if item == nil {
+		return
+	}
+
+	err := h.store.RestoreItem(itemType, item.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "item not found in the trash"})
+		return
+	}
+
+	if store.IsUniqueViolation(err) {
+		helpers.WriteJson(w, http.StatusConflict, helpers.Envelop{"error": "a book with this isbn is already in your library"})
+		return
+	}
+
+	if err != nil {
+		h.logger.Printf("ERROR: restoring %s %v", itemType, err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *TrashHandler) purge(w http.ResponseWriter, r *http.Request, itemType string) {
+	item := h.getUserTrashItem(w, r, itemType)
+	if item == nil {
+		return
+	}
+
+	hashes, err := h.store.PurgeItem(itemType, item.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "item not found in the trash"})
+		return
+	}
+
+	if err != nil {
+		h.logger.Printf("ERROR: purging %s %v", itemType, err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	h.covers.DeleteFiles(r.Context(), hashes)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getUserTrashItem returns the trashed item of the id URL parameter, or
+// writes the error response and returns nil when it is not in the trash or
+// belongs to another user.
+func (h *TrashHandler) getUserTrashItem(w http.ResponseWriter, r *http.Request, itemType string) *store.TrashItem {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid item id"})
+		return nil
+	}
+
+	item, err := h.store.GetTrashItem(itemType, id)
+	if err != nil {
+		h.logger.Printf("ERROR: getting trash item %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return nil
+	}
+
+	if item == nil {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "item not found in the trash"})
+		return nil
+	}
+
+	user := middleware.GetUser(r)
+	if item.UserID != user.ID {
+		helpers.WriteJson(w, http.StatusForbidden, helpers.Envelop{"error": "you are not allowed to perform this action on this resource"})
+		return nil
+	}
+
+	return item
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/martialanouman/personal-library/internal/helpers"
 	"github.com/martialanouman/personal-library/internal/middleware"
@@ -91,6 +92,25 @@ func (req *updatePasswordRequest) validate() error {
 	return nil
 }
 
+type updateProfileRequest struct {
+	Name     *string `json:"name,omitempty"`
+	Timezone *string `json:"timezone,omitempty"`
+}
+
+func (req *updateProfileRequest) validate() error {
+	if req.Name != nil && *req.Name == "" {
+		return errors.New("name cannot be empty")
+	}
+
+	if req.Timezone != nil {
+		if _, err := time.LoadLocation(*req.Timezone); err != nil || *req.Timezone == "" {
+			return errors.New("timezone must be a valid IANA time zone, like Europe/Paris")
+		}
+	}
+
+	return nil
+}
+
 func NewUserHandler(store store.UserStore, tokenStore store.TokenStore, logger *log.Logger) UserHandler {
 	return UserHandler{
 		store,
@@ -199,6 +219,39 @@ func (h *UserHandler) HandleMe(w http.ResponseWriter, r *http.Request) {
 	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"me": user})
 }
 
+func (h *UserHandler) HandleUpdateProfile(w http.ResponseWriter, r *http.Request) {
+	var req updateProfileRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Printf("ERROR: decoding payload %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": "invalid request payload"})
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		h.logger.Printf("ERROR: validating payload %v", err)
+		helpers.WriteJson(w, http.StatusBadRequest, helpers.Envelop{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	if req.Name != nil {
+		user.Name = *req.Name
+	}
+
+	if req.Timezone != nil {
+		user.Timezone = *req.Timezone
+	}
+
+	if err := h.store.UpdateUser(user); err != nil {
+		h.logger.Printf("ERROR: updating user %v", err)
+		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"me": user})
+}
+
 func (h *UserHandler) HandleUpdatePassword(w http.ResponseWriter, r *http.Request) {
 	var req updatePasswordRequest
 
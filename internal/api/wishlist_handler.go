@@ -2,32 +2,37 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"slices"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
 	"github.com/martialanouman/personal-library/internal/helpers"
 	"github.com/martialanouman/personal-library/internal/middleware"
 	"github.com/martialanouman/personal-library/internal/store"
 )
 
 type WishlistHandler struct {
-	store  store.WishlistStore
-	logger *log.Logger
+	store       store.WishlistStore
+	seriesStore store.SeriesStore
+	logger      *log.Logger
 }
 
-func NewWishlistHandler(store store.WishlistStore, logger *log.Logger) WishlistHandler {
-	return WishlistHandler{store: store, logger: logger}
+func NewWishlistHandler(store store.WishlistStore, seriesStore store.SeriesStore, logger *log.Logger) WishlistHandler {
+	return WishlistHandler{store: store, seriesStore: seriesStore, logger: logger}
 }
 
 type createWishRequest struct {
-	Title     string  `json:"title"`
-	Author    string  `json:"author"`
-	Isbn      *string `json:"isbn"`
-	BigBookID *int64  `json:"bb_id"`
-	Priority  *string `json:"priority,omitempty"`
-	Notes     *string `json:"notes,omitempty"`
+	Title          string   `json:"title"`
+	Author         string   `json:"author"`
+	Isbn           *string  `json:"isbn"`
+	BigBookID      *int64   `json:"bb_id"`
+	Priority       *string  `json:"priority,omitempty"`
+	Notes          *string  `json:"notes,omitempty"`
+	SeriesID       *string  `json:"series_id,omitempty"`
+	SeriesPosition *float64 `json:"series_position,omitempty"`
 }
 
 func (req *createWishRequest) validate() map[string]string {
@@ -41,9 +46,7 @@ func (req *createWishRequest) validate() map[string]string {
 		errorMessages["author"] = "author is required"
 	}
 
-	if req.Isbn != nil && len(*req.Isbn) < 13 {
-		errorMessages["isbn"] = "isbn must be 13 characters"
-	}
+	normalizeIsbn(req.Isbn, errorMessages)
 
 	if req.Priority == nil {
 		normal := "normal"
@@ -55,18 +58,28 @@ func (req *createWishRequest) validate() map[string]string {
 		errorMessages["priority"] = "priority must be one of: low, normal or high"
 	}
 
+	if req.SeriesPosition != nil {
+		if req.SeriesID == nil {
+			errorMessages["series_position"] = "series_position requires a series_id"
+		} else if *req.SeriesPosition <= 0 {
+			errorMessages["series_position"] = "series_position must be greater than 0"
+		}
+	}
+
 	return errorMessages
 }
 
 func (req *createWishRequest) toWish() *store.Wish {
 	return &store.Wish{
-		Title:     req.Title,
-		Author:    &req.Author,
-		Isbn:      req.Isbn,
-		BigBookID: req.BigBookID,
-		Priority:  *req.Priority,
-		Acquired:  false,
-		Notes:     req.Notes,
+		Title:          req.Title,
+		Author:         &req.Author,
+		Isbn:           req.Isbn,
+		BigBookID:      req.BigBookID,
+		Priority:       *req.Priority,
+		Acquired:       false,
+		Notes:          req.Notes,
+		SeriesID:       req.SeriesID,
+		SeriesPosition: req.SeriesPosition,
 	}
 }
 
@@ -84,6 +97,10 @@ func (h *WishlistHandler) HandleAddWish(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !checkUserSeries(w, r, h.seriesStore, h.logger, req.SeriesID) {
+		return
+	}
+
 	user := middleware.GetUser(r)
 	wish := req.toWish()
 	wish.UserID = user.ID
@@ -93,9 +110,28 @@ func (h *WishlistHandler) HandleAddWish(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	w.Header().Set("ETag", versionETag(wish.Version))
 	helpers.WriteJson(w, http.StatusCreated, helpers.Envelop{"wish": wish})
 }
 
+// HandleGetWish answers with a 304 when the If-None-Match header lists the
+// ETag of the current version of the wish.
+func (h *WishlistHandler) HandleGetWish(w http.ResponseWriter, r *http.Request) {
+	wish := getUserWish(w, r, h.store, h.logger)
+	if wish == nil {
+		return
+	}
+
+	etag := versionETag(wish.Version)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"wish": wish})
+}
+
 func (h *WishlistHandler) HandleDeleteWish(w http.ResponseWriter, r *http.Request) {
 	wishID := chi.URLParam(r, "id")
 	if wishID == "" {
@@ -111,6 +147,11 @@ func (h *WishlistHandler) HandleDeleteWish(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if wish == nil {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "wish not found"})
+		return
+	}
+
 	if user.ID != wish.UserID {
 		helpers.WriteJson(w, http.StatusForbidden, helpers.Envelop{"error": "you are not allowed to perform this action on this resource"})
 		return
@@ -140,12 +181,31 @@ func (h *WishlistHandler) HandleMarkAsAcquired(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	if wish == nil {
+		helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "wish not found"})
+		return
+	}
+
 	if user.ID != wish.UserID {
 		helpers.WriteJson(w, http.StatusForbidden, helpers.Envelop{"error": "you are not allowed to perform this action on this resource"})
 		return
 	}
 
-	if err := h.store.MarkAsAcquired(wishID); err != nil {
+	if !checkIfMatch(w, r, versionETag(wish.Version)) {
+		return
+	}
+
+	if err := h.store.MarkAsAcquired(wishID, wish.Version); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			helpers.WriteJson(w, http.StatusNotFound, helpers.Envelop{"error": "wish not found"})
+			return
+		}
+
+		if errors.Is(err, store.ErrVersionConflict) {
+			writeVersionConflict(w, r)
+			return
+		}
+
 		h.logger.Printf("ERROR: mark as acquired wish %v", err)
 		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
 		return
@@ -157,15 +217,17 @@ func (h *WishlistHandler) HandleMarkAsAcquired(w http.ResponseWriter, r *http.Re
 func (h *WishlistHandler) HandleGetWishes(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
 	pagination := middleware.GetPagination(r)
+	tags, tagMode := tagFiltersFromQuery(r.URL.Query())
+	filters := store.WishFilters{Tags: tags, TagMode: tagMode}
 
-	wishes, err := h.store.GetWishes(user.ID, pagination.Page, pagination.Take)
+	wishes, err := h.store.GetWishes(user.ID, filters, pagination.Page, pagination.Take)
 	if err != nil {
 		h.logger.Printf("ERROR: getting wishes %v", err)
 		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
 		return
 	}
 
-	count, err := h.store.GetWishesCount(user.ID)
+	count, err := h.store.GetWishesCount(user.ID, filters)
 	if err != nil {
 		h.logger.Printf("ERROR: getting wishes count %v", err)
 		helpers.WriteJson(w, http.StatusInternalServerError, helpers.Envelop{"error": "internal server error"})
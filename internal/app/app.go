@@ -10,18 +10,41 @@ import (
 	"github.com/martialanouman/personal-library/internal/helpers"
 	"github.com/martialanouman/personal-library/internal/middleware"
 	"github.com/martialanouman/personal-library/internal/services"
+	"github.com/martialanouman/personal-library/internal/storage"
 	"github.com/martialanouman/personal-library/internal/store"
 )
 
 type Application struct {
-	Db              *pgxpool.Pool
-	Logger          *log.Logger
-	AuthMiddleware  middleware.AuthMiddleware
-	UtilsMiddleware middleware.UtilsMiddleware
-	UserHandler     api.UserHandler
-	TokenHandler    api.TokenHandler
-	BookHandler     api.BookHandler
-	WishlistHandler api.WishlistHandler
+	Db                    *pgxpool.Pool
+	Logger                *log.Logger
+	AuthMiddleware        middleware.AuthMiddleware
+	UtilsMiddleware       middleware.UtilsMiddleware
+	UserHandler           api.UserHandler
+	TokenHandler          api.TokenHandler
+	BookHandler           api.BookHandler
+	WishlistHandler       api.WishlistHandler
+	RecommendationHandler api.RecommendationHandler
+	ShelfHandler          api.ShelfHandler
+	ExportHandler         api.ExportHandler
+	TagHandler            api.TagHandler
+	SeriesHandler         api.SeriesHandler
+	AuthorHandler         api.AuthorHandler
+	ReadingSessionHandler api.ReadingSessionHandler
+	BookReadHandler       api.BookReadHandler
+	BookRevisionHandler   api.BookRevisionHandler
+	StatsHandler          api.StatsHandler
+	GoalHandler           api.GoalHandler
+	QuoteHandler          api.QuoteHandler
+	LoanHandler           api.LoanHandler
+	CopyHandler           api.CopyHandler
+	CatalogHandler        api.CatalogHandler
+	CoverHandler          api.CoverHandler
+	TrashHandler          api.TrashHandler
+	LoanReminder          *services.LoanReminder
+	MetadataCache         *services.MetadataCache
+	EnrichmentWorker      *services.EnrichmentWorker
+	CoverFetcher          *services.CoverFetcher
+	TrashPurger           *services.TrashPurger
 }
 
 func NewApplication() (*Application, error) {
@@ -32,7 +55,19 @@ func NewApplication() (*Application, error) {
 		return nil, err
 	}
 
-	bookApi, err := services.NewBookAPIService(logger)
+	metadataCache := services.NewMetadataCache(store.NewPostgresMetadataCacheStore(db), logger)
+	providers := services.NewMetadataProviders(metadataCache, logger)
+	metadata := services.NewMetadataChain(providers, metadataCache, logger)
+	logger.Printf("book metadata providers: %v", metadata.Providers())
+
+	var bigBook *services.BigBookProvider
+	for _, provider := range providers {
+		if p, ok := provider.(*services.BigBookProvider); ok {
+			bigBook = p
+		}
+	}
+
+	fileStorage, err := storage.New()
 	if err != nil {
 		return nil, err
 	}
@@ -41,19 +76,60 @@ func NewApplication() (*Application, error) {
 	tokenStore := store.NewPostgresTokenStore(db)
 	bookStore := store.NewPostgresBookStore(db)
 	wishlistStore := store.NewPostgresWishlistStore(db)
+	shelfStore := store.NewPostgresShelfStore(db)
+	tagStore := store.NewPostgresTagStore(db)
+	seriesStore := store.NewPostgresSeriesStore(db)
+	authorStore := store.NewPostgresAuthorStore(db)
+	readingSessionStore := store.NewPostgresReadingSessionStore(db)
+	bookReadStore := store.NewPostgresBookReadStore(db)
+	statsStore := store.NewPostgresStatsStore(db)
+	goalStore := store.NewPostgresGoalStore(db)
+	quoteStore := store.NewPostgresQuoteStore(db)
+	loanStore := store.NewPostgresLoanStore(db)
+	copyStore := store.NewPostgresCopyStore(db)
+	coverStore := store.NewPostgresCoverStore(db)
+	covers := services.NewCoverService(coverStore, fileStorage, logger)
+	trashStore := store.NewPostgresTrashStore(db)
+	trashRetention := services.TrashRetention(logger)
 
 	return &Application{
-		Logger:          logger,
-		Db:              db,
-		AuthMiddleware:  middleware.NewAuthMiddleware(userStore, tokenStore, logger),
-		UtilsMiddleware: middleware.NewUtilsMiddleware(),
-		UserHandler:     api.NewUserHandler(userStore, tokenStore, logger),
-		TokenHandler:    api.NewTokenHandler(tokenStore, logger),
-		BookHandler:     api.NewBookHandler(bookStore, bookApi, logger),
-		WishlistHandler: api.NewWishlistHandler(wishlistStore, logger),
+		Logger:                logger,
+		Db:                    db,
+		AuthMiddleware:        middleware.NewAuthMiddleware(userStore, tokenStore, logger),
+		UtilsMiddleware:       middleware.NewUtilsMiddleware(),
+		UserHandler:           api.NewUserHandler(userStore, tokenStore, logger),
+		TokenHandler:          api.NewTokenHandler(tokenStore, logger),
+		BookHandler:           api.NewBookHandler(bookStore, seriesStore, shelfStore, bigBook, metadata, logger),
+		WishlistHandler:       api.NewWishlistHandler(wishlistStore, seriesStore, logger),
+		RecommendationHandler: api.NewRecommendationHandler(bookStore, wishlistStore, services.NewRecommender(), logger),
+		ShelfHandler:          api.NewShelfHandler(shelfStore, logger),
+		ExportHandler:         api.NewExportHandler(bookStore, wishlistStore, shelfStore, bookReadStore, quoteStore, copyStore, logger),
+		TagHandler:            api.NewTagHandler(tagStore, bookStore, wishlistStore, logger),
+		SeriesHandler:         api.NewSeriesHandler(seriesStore, logger),
+		AuthorHandler:         api.NewAuthorHandler(authorStore, logger),
+		ReadingSessionHandler: api.NewReadingSessionHandler(readingSessionStore, bookStore, logger),
+		BookReadHandler:       api.NewBookReadHandler(bookReadStore, bookStore, logger),
+		BookRevisionHandler:   api.NewBookRevisionHandler(store.NewPostgresBookRevisionStore(db), bookStore, logger),
+		StatsHandler:          api.NewStatsHandler(statsStore, logger),
+		GoalHandler:           api.NewGoalHandler(goalStore, logger),
+		QuoteHandler:          api.NewQuoteHandler(quoteStore, bookStore, tagStore, logger),
+		LoanHandler:           api.NewLoanHandler(loanStore, bookStore, logger),
+		CopyHandler:           api.NewCopyHandler(copyStore, bookStore, logger),
+		CatalogHandler:        api.NewCatalogHandler(bookStore, wishlistStore, seriesStore, bigBook, metadata, logger),
+		CoverHandler:          api.NewCoverHandler(coverStore, bookStore, covers, logger),
+		TrashHandler:          api.NewTrashHandler(trashStore, covers, trashRetention, logger),
+		LoanReminder:          services.NewLoanReminder(loanStore, services.NewLogNotifier(logger), logger),
+		MetadataCache:         metadataCache,
+		EnrichmentWorker:      services.NewEnrichmentWorker(store.NewPostgresEnrichmentStore(db), metadata, bigBook, logger),
+		CoverFetcher:          services.NewCoverFetcher(coverStore, covers, logger),
+		TrashPurger:           services.NewTrashPurger(trashStore, covers, trashRetention, logger),
 	}, nil
 }
 
 func (a *Application) Health(w http.ResponseWriter, r *http.Request) {
 	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"status": "up"})
 }
+
+func (a *Application) MetadataCacheStats(w http.ResponseWriter, r *http.Request) {
+	helpers.WriteJson(w, http.StatusOK, helpers.Envelop{"metadata_cache": a.MetadataCache.Stats()})
+}
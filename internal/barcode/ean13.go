@@ -0,0 +1,325 @@
+// Package barcode finds EAN-13 barcodes, which carry the ISBN-13 printed on
+// books, in photos.
+package barcode
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	// ean13Runs is the number of bars and spaces of an EAN-13 barcode: a start
+	// guard of 3, 6 digits of 4, a center guard of 5, 6 digits of 4 and an end
+	// guard of 3.
+	ean13Runs    = 59
+	ean13Modules = 95
+
+	// scanLines is the number of rows, and of columns, scanned per image.
+	scanLines = 80
+	minReads  = 2
+	// maxDigitDistance is how far, in modules, the widths of a digit may be
+	// from its pattern.
+	maxDigitDistance = 1.4
+)
+
+// digitPatterns are the widths in modules of the digits in their L code,
+// starting with a space on the left half and with a bar on the right half
+// where they are known as the R code. The G code is the L code reversed.
+var digitPatterns = [10][4]float64{
+	{3, 2, 1, 1},
+	{2, 2, 2, 1},
+	{2, 1, 2, 2},
+	{1, 4, 1, 1},
+	{1, 1, 3, 2},
+	{1, 2, 3, 1},
+	{1, 1, 1, 4},
+	{1, 3, 1, 2},
+	{1, 2, 1, 3},
+	{3, 1, 1, 2},
+}
+
+// firstDigitParities gives the first digit, which is not drawn, from the
+// codes of the left half digits: bit 5-i is set when digit i uses the G code.
+var firstDigitParities = map[int]byte{
+	0b000000: '0',
+	0b001011: '1',
+	0b001101: '2',
+	0b001110: '3',
+	0b010011: '4',
+	0b011001: '5',
+	0b011100: '6',
+	0b010101: '7',
+	0b010110: '8',
+	0b011010: '9',
+}
+
+// Decode returns the EAN-13 codes found in the image, in the order they were
+// found. Barcodes may be upside down or turned by a quarter. A code must be
+// read on at least two lines, which weeds out the misreads passing the
+// checksum by chance.
+func Decode(img image.Image) []string {
+	gray := luminance(img)
+	bounds := gray.Bounds()
+
+	found := []string{}
+	reads := make(map[string]int)
+	add := func(code string) {
+		if code == "" {
+			return
+		}
+
+		reads[code]++
+		if reads[code] == minReads {
+			found = append(found, code)
+		}
+	}
+
+	line := make([]float64, max(bounds.Dx(), bounds.Dy()))
+
+	rowStep := max(1, bounds.Dy()/scanLines)
+	for y := bounds.Min.Y + rowStep/2; y < bounds.Max.Y; y += rowStep {
+		row := line[:bounds.Dx()]
+		for x := range row {
+			row[x] = averageAround(gray, bounds.Min.X+x, y, false)
+		}
+		add(decodeLine(row))
+	}
+
+	columnStep := max(1, bounds.Dx()/scanLines)
+	for x := bounds.Min.X + columnStep/2; x < bounds.Max.X; x += columnStep {
+		column := line[:bounds.Dy()]
+		for y := range column {
+			column[y] = averageAround(gray, x, bounds.Min.Y+y, true)
+		}
+		add(decodeLine(column))
+	}
+
+	return found
+}
+
+// luminance converts the image to grayscale once, rather than once per line
+// scanned across it.
+func luminance(img image.Image) *image.Gray {
+	switch img := img.(type) {
+	case *image.Gray:
+		return img
+	case *image.YCbCr:
+		// The Y channel of JPEG photos already is their luminance.
+		return &image.Gray{Pix: img.Y, Stride: img.YStride, Rect: img.Rect}
+	}
+
+	bounds := img.Bounds()
+	gray := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			gray.Set(x, y, img.At(x, y))
+		}
+	}
+
+	return gray
+}
+
+// averageAround averages the pixel with its neighbours across the scan line,
+// which smooths out the noise of photos.
+func averageAround(gray *image.Gray, x, y int, vertical bool) float64 {
+	bounds := gray.Bounds()
+	sum, count := 0.0, 0.0
+	for d := -1; d <= 1; d++ {
+		px, py := x, y+d
+		if vertical {
+			px, py = x+d, y
+		}
+
+		if (image.Point{px, py}).In(bounds) {
+			sum += float64(gray.GrayAt(px, py).Y)
+			count++
+		}
+	}
+
+	return sum / count
+}
+
+// decodeLine looks for a barcode along the line, read in both directions.
+func decodeLine(line []float64) string {
+	runs, firstDark := binarize(line)
+	if code := decodeRuns(runs, firstDark); code != "" {
+		return code
+	}
+
+	reversed := make([]int, len(runs))
+	for i, run := range runs {
+		reversed[len(runs)-1-i] = run
+	}
+	lastDark := firstDark == (len(runs)%2 == 1)
+
+	return decodeRuns(reversed, lastDark)
+}
+
+// binarize splits the line into runs of alternating dark and light pixels,
+// comparing each pixel with the average of its surroundings so that uneven
+// lighting does not matter. It returns the run lengths and whether the first
+// run is dark.
+func binarize(line []float64) ([]int, bool) {
+	if len(line) == 0 {
+		return nil, false
+	}
+
+	window := max(8, len(line)/16)
+	sums := make([]float64, len(line)+1)
+	for i, value := range line {
+		sums[i+1] = sums[i] + value
+	}
+
+	dark := func(i int) bool {
+		from, to := max(0, i-window/2), min(len(line), i+window/2+1)
+		mean := (sums[to] - sums[from]) / float64(to-from)
+		// The margin keeps flat areas from turning into noise.
+		return line[i] < mean-4
+	}
+
+	runs := []int{}
+	firstDark := dark(0)
+	current, length := firstDark, 0
+	for i := range line {
+		if d := dark(i); d != current {
+			runs = append(runs, length)
+			current, length = d, 0
+		}
+		length++
+	}
+	runs = append(runs, length)
+
+	return runs, firstDark
+}
+
+// decodeRuns tries every dark run as the start of a barcode.
+func decodeRuns(runs []int, firstDark bool) string {
+	start := 0
+	if !firstDark {
+		start = 1
+	}
+
+	for i := start; i+ean13Runs <= len(runs); i += 2 {
+		if code := decodeAt(runs, i); code != "" {
+			return code
+		}
+	}
+
+	return ""
+}
+
+// decodeAt decodes the barcode whose start guard begins at runs[i], and
+// returns an empty string when there is none or its checksum is wrong.
+func decodeAt(runs []int, i int) string {
+	total := 0
+	for _, run := range runs[i : i+ean13Runs] {
+		total += run
+	}
+	module := float64(total) / ean13Modules
+
+	// Barcodes need a quiet zone before them.
+	if i > 0 && float64(runs[i-1]) < 3*module {
+		return ""
+	}
+
+	for _, guard := range [][2]int{{0, 3}, {27, 32}, {56, 59}} {
+		for _, run := range runs[i+guard[0] : i+guard[1]] {
+			if width := float64(run) / module; width < 0.4 || width > 1.8 {
+				return ""
+			}
+		}
+	}
+
+	digits := make([]byte, 13)
+	parities := 0
+	for d := range 6 {
+		digit, g := decodeDigit(runs[i+3+4*d:i+7+4*d], true)
+		if digit < 0 {
+			return ""
+		}
+		digits[d+1] = byte('0' + digit)
+		if g {
+			parities |= 1 << (5 - d)
+		}
+	}
+
+	first, ok := firstDigitParities[parities]
+	if !ok {
+		return ""
+	}
+	digits[0] = first
+
+	for d := range 6 {
+		digit, _ := decodeDigit(runs[i+32+4*d:i+36+4*d], false)
+		if digit < 0 {
+			return ""
+		}
+		digits[d+7] = byte('0' + digit)
+	}
+
+	if !validChecksum(digits) {
+		return ""
+	}
+
+	return string(digits)
+}
+
+// decodeDigit returns the digit closest to the widths of its 4 runs, and
+// whether it uses the G code, or -1 when none is close enough. Only digits
+// of the left half may use the G code.
+func decodeDigit(runs []int, left bool) (int, bool) {
+	sum := 0
+	for _, run := range runs {
+		sum += run
+	}
+
+	widths := [4]float64{}
+	for j, run := range runs {
+		widths[j] = float64(run) * 7 / float64(sum)
+	}
+
+	best, bestG, bestDistance := -1, false, math.Inf(1)
+	for digit, pattern := range digitPatterns {
+		if distance := patternDistance(widths, pattern, false); distance < bestDistance {
+			best, bestG, bestDistance = digit, false, distance
+		}
+
+		if left {
+			if distance := patternDistance(widths, pattern, true); distance < bestDistance {
+				best, bestG, bestDistance = digit, true, distance
+			}
+		}
+	}
+
+	if bestDistance > maxDigitDistance {
+		return -1, false
+	}
+
+	return best, bestG
+}
+
+func patternDistance(widths, pattern [4]float64, reversed bool) float64 {
+	distance := 0.0
+	for j := range widths {
+		expected := pattern[j]
+		if reversed {
+			expected = pattern[3-j]
+		}
+		distance += math.Abs(widths[j] - expected)
+	}
+
+	return distance
+}
+
+func validChecksum(digits []byte) bool {
+	sum := 0
+	for i, digit := range digits[:12] {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(digit-'0') * weight
+	}
+
+	return byte('0'+(10-sum%10)%10) == digits[12]
+}
@@ -0,0 +1,119 @@
+package barcode
+
+import (
+	"image"
+	"image/color"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// modules draws the EAN-13 code as its 95 modules, true for a bar. The check
+// digit is drawn as given, right or wrong.
+func modules(t *testing.T, code string) []bool {
+	t.Helper()
+
+	if len(code) != 13 {
+		t.Fatalf("code %q is not 13 digits long", code)
+	}
+
+	var parities int
+	for bits, digit := range firstDigitParities {
+		if digit == code[0] {
+			parities = bits
+		}
+	}
+
+	drawn := []bool{true, false, true}
+	draw := func(widths [4]float64, bar bool) {
+		for _, width := range widths {
+			for range int(width) {
+				drawn = append(drawn, bar)
+			}
+			bar = !bar
+		}
+	}
+
+	for i := 1; i <= 6; i++ {
+		widths := digitPatterns[code[i]-'0']
+		if parities&(1<<(6-i)) != 0 {
+			widths = [4]float64{widths[3], widths[2], widths[1], widths[0]}
+		}
+		draw(widths, false)
+	}
+
+	drawn = append(drawn, false, true, false, true, false)
+	for i := 7; i <= 12; i++ {
+		draw(digitPatterns[code[i]-'0'], true)
+	}
+
+	return append(drawn, true, false, true)
+}
+
+// render draws the code scale pixels per module with a quiet zone around
+// it, adding up to noise levels of random noise to every pixel.
+func render(t *testing.T, code string, scale float64, noise int) *image.Gray {
+	t.Helper()
+
+	drawn := modules(t, code)
+	quiet := 10 * scale
+	width := int(float64(len(drawn))*scale + 2*quiet)
+	height := 80
+
+	random := rand.New(rand.NewPCG(1, 2))
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for x := range width {
+		module := int((float64(x) - quiet) / scale)
+		level := 230
+		if float64(x) >= quiet && module < len(drawn) && drawn[module] {
+			level = 30
+		}
+
+		for y := range height {
+			value := level
+			if noise > 0 {
+				value += random.IntN(2*noise+1) - noise
+			}
+			img.SetGray(x, y, color.Gray{Y: uint8(min(max(value, 0), 255))})
+		}
+	}
+
+	return img
+}
+
+// quarterTurn turns the image by a quarter.
+func quarterTurn(img *image.Gray) *image.Gray {
+	bounds := img.Bounds()
+	turned := image.NewGray(image.Rect(0, 0, bounds.Dy(), bounds.Dx()))
+	for x := range bounds.Dx() {
+		for y := range bounds.Dy() {
+			turned.SetGray(bounds.Dy()-1-y, x, img.GrayAt(x, y))
+		}
+	}
+
+	return turned
+}
+
+func TestDecode(t *testing.T) {
+	const isbn = "9780306406157"
+
+	tests := []struct {
+		name string
+		img  image.Image
+		want []string
+	}{
+		{"sharp", render(t, isbn, 2, 0), []string{isbn}},
+		{"scaled and noisy", render(t, isbn, 3.4, 60), []string{isbn}},
+		{"quarter turn", quarterTurn(render(t, isbn, 2, 20)), []string{isbn}},
+		{"wrong check digit", render(t, "9780306406158", 2, 0), []string{}},
+		{"blank", image.NewGray(image.Rect(0, 0, 300, 80)), []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Decode(tt.img); !slices.Equal(got, tt.want) {
+				t.Errorf("Decode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Package isbn validates International Standard Book Numbers and converts
+// them between their 10 and 13 digits forms.
+package isbn
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalid = errors.New("invalid isbn")
+
+// bookland is the prefix of the ISBN-13 converted from ISBN-10. Only these
+// ISBN-13 have an ISBN-10 counterpart.
+const bookland = "978"
+
+// Normalize removes the hyphens and spaces of the ISBN and uppercases its
+// check character.
+func Normalize(isbn string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(isbn)))
+}
+
+// IsValid10 tells whether the normalized ISBN is a valid ISBN-10. Its last
+// character may be X, standing for 10.
+func IsValid10(isbn string) bool {
+	if len(isbn) != 10 || !isDigits(isbn[:9]) {
+		return false
+	}
+
+	last := isbn[9]
+	if last != 'X' && (last < '0' || last > '9') {
+		return false
+	}
+
+	return checkDigit10(isbn[:9]) == last
+}
+
+// IsValid13 tells whether the normalized ISBN is a valid ISBN-13.
+func IsValid13(isbn string) bool {
+	if len(isbn) != 13 || !isDigits(isbn) {
+		return false
+	}
+
+	return checkDigit13(isbn[:12]) == isbn[12]
+}
+
+// To13 converts a valid ISBN-10 to its ISBN-13 form.
+func To13(isbn string) (string, error) {
+	isbn = Normalize(isbn)
+	if !IsValid10(isbn) {
+		return "", ErrInvalid
+	}
+
+	body := bookland + isbn[:9]
+
+	return body + string(checkDigit13(body)), nil
+}
+
+// To10 converts a valid ISBN-13 starting with 978 to its ISBN-10 form.
+func To10(isbn string) (string, error) {
+	isbn = Normalize(isbn)
+	if !IsValid13(isbn) || !strings.HasPrefix(isbn, bookland) {
+		return "", ErrInvalid
+	}
+
+	body := isbn[3:12]
+
+	return body + string(checkDigit10(body)), nil
+}
+
+// Parse normalizes and validates an ISBN-10 or ISBN-13 and returns its
+// ISBN-13 form, which is the one stored.
+func Parse(isbn string) (string, error) {
+	isbn = Normalize(isbn)
+
+	switch {
+	case IsValid13(isbn):
+		return isbn, nil
+	case IsValid10(isbn):
+		return To13(isbn)
+	default:
+		return "", ErrInvalid
+	}
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkDigit10 computes the check character of the first 9 digits of an
+// ISBN-10: weights go from 10 down to 2 and the sum must be a multiple of 11.
+func checkDigit10(body string) byte {
+	sum := 0
+	for i := range 9 {
+		sum += int(body[i]-'0') * (10 - i)
+	}
+
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+
+	return strconv.Itoa(check)[0]
+}
+
+// checkDigit13 computes the check digit of the first 12 digits of an
+// ISBN-13: weights alternate between 1 and 3 and the sum must be a multiple
+// of 10.
+func checkDigit13(body string) byte {
+	sum := 0
+	for i := range 12 {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(body[i]-'0') * weight
+	}
+
+	return byte('0' + (10-sum%10)%10)
+}
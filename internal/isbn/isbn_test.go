@@ -0,0 +1,72 @@
+package isbn
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		isbn string
+		want string
+		err  error
+	}{
+		{"9780441172719", "9780441172719", nil},
+		{"978-0-441-17271-9", "9780441172719", nil},
+		{" 978 0 441 17271 9 ", "9780441172719", nil},
+		{"0441172717", "9780441172719", nil},
+		{"0-441-17271-7", "9780441172719", nil},
+		// X stands for 10 in the check character of an ISBN-10.
+		{"080442957X", "9780804429573", nil},
+		{"080442957x", "9780804429573", nil},
+		{"9791032305690", "9791032305690", nil},
+		{"9780441172718", "", ErrInvalid},
+		{"0441172718", "", ErrInvalid},
+		{"04411727X7", "", ErrInvalid},
+		{"X441172717", "", ErrInvalid},
+		{"044117271", "", ErrInvalid},
+		{"", "", ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.isbn)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("Parse(%q) = %q, %v, want %q, %v", tt.isbn, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestTo10(t *testing.T) {
+	tests := []struct {
+		isbn string
+		want string
+		err  error
+	}{
+		{"9780441172719", "0441172717", nil},
+		{"9780804429573", "080442957X", nil},
+		// Only ISBN-13 starting with 978 have an ISBN-10.
+		{"9791032305690", "", ErrInvalid},
+		{"9780441172718", "", ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		got, err := To10(tt.isbn)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("To10(%q) = %q, %v, want %q, %v", tt.isbn, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestTo13RoundTrip(t *testing.T) {
+	for _, isbn10 := range []string{"0441172717", "080442957X", "0306406152", "2070360024"} {
+		isbn13, err := To13(isbn10)
+		if err != nil {
+			t.Fatalf("To13(%q) error = %v", isbn10, err)
+		}
+
+		back, err := To10(isbn13)
+		if err != nil || back != isbn10 {
+			t.Errorf("To10(To13(%q)) = %q, %v", isbn10, back, err)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+// Package markdown renders the subset of Markdown used in reviews to HTML.
+//
+// Sources are HTML-escaped before being formatted, so the output only holds
+// the tags produced here and raw HTML in a review is shown as text. Links are
+// kept only for the http, https and mailto schemes.
+//
+// Besides headings, paragraphs, lists, quotes, code and emphasis, reviews can
+// hide spoilers, either as a block:
+//
+//	:::spoiler Ending
+//	The butler did it.
+//	:::
+//
+// or inline, between double pipes: ||the butler did it||.
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	headingLine    = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	unorderedItem  = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	orderedItem    = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	thematicBreak  = regexp.MustCompile(`^\s*([-*_])(\s*([-*_])){2,}\s*$`)
+	spoilerOpening = regexp.MustCompile(`^:::\s*spoiler\b\s*(.*)$`)
+
+	codeSpan     = regexp.MustCompile("`([^`]+)`")
+	link         = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	placeholder  = regexp.MustCompile("\x00(\\d+)\x00")
+	inlineSpoil  = regexp.MustCompile(`\|\|(.+?)\|\|`)
+	strongStars  = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	strongUnders = regexp.MustCompile(`\b__(.+?)__\b`)
+	emStars      = regexp.MustCompile(`\*([^*\s][^*]*?)\*`)
+	emUnders     = regexp.MustCompile(`\b_([^_\s][^_]*?)_\b`)
+)
+
+var allowedSchemes = []string{"http://", "https://", "mailto:"}
+
+// ToHTML renders the Markdown source to sanitized HTML.
+func ToHTML(source string) string {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	source = strings.ReplaceAll(source, "\x00", "")
+
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(source, "\n"))
+
+	return b.String()
+}
+
+func renderBlocks(b *strings.Builder, lines []string) {
+	var paragraph []string
+
+	flush := func() {
+		if len(paragraph) > 0 {
+			fmt.Fprintf(b, "<p>%s</p>\n", renderInline(strings.Join(paragraph, "\n")))
+			paragraph = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			flush()
+
+		case spoilerOpening.MatchString(trimmed):
+			flush()
+			title := strings.TrimSpace(spoilerOpening.FindStringSubmatch(trimmed)[1])
+			if title == "" {
+				title = "Spoiler"
+			}
+
+			end := closingFence(lines, i+1)
+			b.WriteString(`<details class="spoiler"><summary>` + renderInline(title) + "</summary>\n")
+			renderBlocks(b, lines[i+1:end])
+			b.WriteString("</details>\n")
+			i = end
+
+		case strings.HasPrefix(trimmed, "```"):
+			flush()
+			end := i + 1
+			for end < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[end]), "```") {
+				end++
+			}
+
+			code := strings.Join(lines[i+1:min(end, len(lines))], "\n")
+			b.WriteString("<pre><code>" + html.EscapeString(code) + "</code></pre>\n")
+			i = end
+
+		case headingLine.MatchString(trimmed):
+			flush()
+			match := headingLine.FindStringSubmatch(trimmed)
+			level := len(match[1])
+			fmt.Fprintf(b, "<h%d>%s</h%d>\n", level, renderInline(match[2]), level)
+
+		case thematicBreak.MatchString(trimmed):
+			flush()
+			b.WriteString("<hr>\n")
+
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				content := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(content, " "))
+			}
+			i--
+
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted)
+			b.WriteString("</blockquote>\n")
+
+		case unorderedItem.MatchString(line), orderedItem.MatchString(line):
+			flush()
+			item, tag := unorderedItem, "ul"
+			if !unorderedItem.MatchString(line) {
+				item, tag = orderedItem, "ol"
+			}
+
+			b.WriteString("<" + tag + ">\n")
+			for ; i < len(lines) && item.MatchString(lines[i]); i++ {
+				b.WriteString("<li>" + renderInline(item.FindStringSubmatch(lines[i])[1]) + "</li>\n")
+			}
+			i--
+			b.WriteString("</" + tag + ">\n")
+
+		default:
+			paragraph = append(paragraph, trimmed)
+		}
+	}
+
+	flush()
+}
+
+// closingFence returns the index of the ::: line closing the spoiler opened
+// just before start, taking nested spoilers into account. An unclosed
+// spoiler runs to the end of the source.
+func closingFence(lines []string, start int) int {
+	depth := 1
+	for i := start; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		switch {
+		case spoilerOpening.MatchString(trimmed):
+			depth++
+		case trimmed == ":::":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return len(lines)
+}
+
+// renderInline escapes the text and formats its code spans, links, inline
+// spoilers and emphasis. Code spans and link targets are set aside while
+// formatting so that their content is left as is.
+func renderInline(text string) string {
+	escaped := html.EscapeString(text)
+
+	var (
+		reserved []string
+		b        strings.Builder
+	)
+
+	reserve := func(fragment string) string {
+		reserved = append(reserved, fragment)
+		return fmt.Sprintf("\x00%d\x00", len(reserved)-1)
+	}
+
+	last := 0
+	for _, match := range codeSpan.FindAllStringSubmatchIndex(escaped, -1) {
+		b.WriteString(escaped[last:match[0]])
+		b.WriteString(reserve("<code>" + escaped[match[2]:match[3]] + "</code>"))
+		last = match[1]
+	}
+	b.WriteString(escaped[last:])
+
+	formatted := link.ReplaceAllStringFunc(b.String(), func(match string) string {
+		parts := link.FindStringSubmatch(match)
+		label, target := formatEmphasis(parts[1]), parts[2]
+		if !isAllowedTarget(target) {
+			return label
+		}
+
+		return reserve(`<a href="`+target+`" rel="nofollow noopener">`) + label + reserve("</a>")
+	})
+
+	formatted = formatEmphasis(formatted)
+
+	return placeholder.ReplaceAllStringFunc(formatted, func(match string) string {
+		var index int
+		fmt.Sscanf(strings.Trim(match, "\x00"), "%d", &index)
+		return reserved[index]
+	})
+}
+
+func formatEmphasis(text string) string {
+	text = inlineSpoil.ReplaceAllString(text, `<span class="spoiler">$1</span>`)
+	text = strongStars.ReplaceAllString(text, "<strong>$1</strong>")
+	text = strongUnders.ReplaceAllString(text, "<strong>$1</strong>")
+	text = emStars.ReplaceAllString(text, "<em>$1</em>")
+	text = emUnders.ReplaceAllString(text, "<em>$1</em>")
+
+	return text
+}
+
+// isAllowedTarget tells whether the escaped link target uses a safe scheme.
+func isAllowedTarget(target string) bool {
+	lower := strings.ToLower(html.UnescapeString(target))
+	for _, scheme := range allowedSchemes {
+		if strings.HasPrefix(lower, scheme) {
+			return true
+		}
+	}
+
+	return false
+}
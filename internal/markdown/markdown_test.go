@@ -0,0 +1,100 @@
+package markdown
+
+import "testing"
+
+func TestToHTML(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "paragraphs",
+			source: "First line\nsame paragraph\n\nSecond paragraph",
+			want:   "<p>First line\nsame paragraph</p>\n<p>Second paragraph</p>\n",
+		},
+		{
+			name:   "headings",
+			source: "# Title\n### Part ###",
+			want:   "<h1>Title</h1>\n<h3>Part</h3>\n",
+		},
+		{
+			name:   "emphasis",
+			source: "**bold**, __bold__, *italic* and _italic_",
+			want:   "<p><strong>bold</strong>, <strong>bold</strong>, <em>italic</em> and <em>italic</em></p>\n",
+		},
+		{
+			name:   "lists",
+			source: "- one\n- two\n\n1. first\n2) second",
+			want:   "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n<ol>\n<li>first</li>\n<li>second</li>\n</ol>\n",
+		},
+		{
+			name:   "quote",
+			source: "> quoted\n> *text*",
+			want:   "<blockquote>\n<p>quoted\n<em>text</em></p>\n</blockquote>\n",
+		},
+		{
+			name:   "thematic break",
+			source: "above\n\n* * *\n\nbelow",
+			want:   "<p>above</p>\n<hr>\n<p>below</p>\n",
+		},
+		{
+			name:   "code block",
+			source: "```\n**not bold** <b>\n```",
+			want:   "<pre><code>**not bold** &lt;b&gt;</code></pre>\n",
+		},
+		{
+			name:   "code span",
+			source: "use `**stars**` as is",
+			want:   "<p>use <code>**stars**</code> as is</p>\n",
+		},
+		{
+			name:   "link",
+			source: "[the *site*](https://example.com/a_b_c)",
+			want:   "<p><a href=\"https://example.com/a_b_c\" rel=\"nofollow noopener\">the <em>site</em></a></p>\n",
+		},
+		{
+			name:   "unsafe link",
+			source: "[click](javascript:alert%281%29)",
+			want:   "<p>click</p>\n",
+		},
+		{
+			name:   "spoiler block",
+			source: ":::spoiler Ending\nThe butler did it.\n:::",
+			want:   "<details class=\"spoiler\"><summary>Ending</summary>\n<p>The butler did it.</p>\n</details>\n",
+		},
+		{
+			name:   "untitled spoiler block",
+			source: ":::spoiler\nHidden\n:::\nShown",
+			want:   "<details class=\"spoiler\"><summary>Spoiler</summary>\n<p>Hidden</p>\n</details>\n<p>Shown</p>\n",
+		},
+		{
+			name:   "inline spoiler",
+			source: "In the end ||everyone dies||.",
+			want:   "<p>In the end <span class=\"spoiler\">everyone dies</span>.</p>\n",
+		},
+		{
+			name:   "raw html",
+			source: "<script>alert(\"x\")</script>\n<img src=x onerror=alert(1)>",
+			want:   "<p>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;\n&lt;img src=x onerror=alert(1)&gt;</p>\n",
+		},
+		{
+			name:   "raw html in a heading and a spoiler title",
+			source: "# <b>Title</b>\n:::spoiler <i>x</i>\n:::",
+			want:   "<h1>&lt;b&gt;Title&lt;/b&gt;</h1>\n<details class=\"spoiler\"><summary>&lt;i&gt;x&lt;/i&gt;</summary>\n</details>\n",
+		},
+		{
+			name:   "html in a link target",
+			source: "[x](https://example.com/\"onmouseover=\"alert(1))",
+			want:   "<p><a href=\"https://example.com/&#34;onmouseover=&#34;alert(1\" rel=\"nofollow noopener\">x</a>)</p>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToHTML(tt.source); got != tt.want {
+				t.Errorf("ToHTML(%q) =\n%q\nwant\n%q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
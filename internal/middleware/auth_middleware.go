@@ -46,6 +46,15 @@ func SetToken(r *http.Request, token *store.Token) *http.Request {
 	return r.WithContext(ctx)
 }
 
+func GetToken(r *http.Request) *store.Token {
+	token, ok := r.Context().Value(TokenContextKey).(*store.Token)
+	if !ok {
+		panic("could not get token from request context")
+	}
+
+	return token
+}
+
 func GetScope(r *http.Request) []string {
 	token, ok := r.Context().Value(TokenContextKey).(*store.Token)
 	if !ok {
@@ -0,0 +1,250 @@
+// Package outbound wraps the HTTP clients used to call external services.
+//
+// A Client waits for a token of its rate limiter before each attempt, retries
+// idempotent requests failing with a network error or a 429, 502, 503 or 504
+// answer after a jittered exponential backoff, or after the delay asked by a
+// Retry-After header, and stops calling the service for a while once it keeps
+// failing.
+package outbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the service while its circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// Policy tells how a Client calls a service. Zero values disable the
+// matching feature: no retries, no rate limit or no circuit breaker.
+type Policy struct {
+	// Timeout bounds each attempt.
+	Timeout time.Duration
+	// MaxRetries is the number of attempts made after the first one.
+	MaxRetries int
+	// BaseDelay is the backoff before the first retry, doubled afterwards up
+	// to MaxDelay. Retry-After delays longer than MaxDelay are not waited for.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// RatePerSecond is the number of requests allowed per second on average,
+	// with bursts of up to Burst requests.
+	RatePerSecond float64
+	Burst         int
+	// FailureThreshold is the number of consecutive failures opening the
+	// circuit breaker, which lets a single request through after OpenFor.
+	FailureThreshold int
+	OpenFor          time.Duration
+}
+
+// DefaultPolicy suits external APIs answering within a few seconds.
+func DefaultPolicy() Policy {
+	return Policy{
+		Timeout:          10 * time.Second,
+		MaxRetries:       2,
+		BaseDelay:        200 * time.Millisecond,
+		MaxDelay:         5 * time.Second,
+		RatePerSecond:    5,
+		Burst:            5,
+		FailureThreshold: 5,
+		OpenFor:          30 * time.Second,
+	}
+}
+
+type Client struct {
+	name    string
+	client  *http.Client
+	policy  Policy
+	limiter *tokenBucket
+	breaker *breaker
+}
+
+// New wraps client to call the service called name, which is only used in
+// error messages.
+func New(name string, client *http.Client, policy Policy) *Client {
+	c := &Client{name: name, client: client, policy: policy}
+
+	if policy.RatePerSecond > 0 {
+		c.limiter = newTokenBucket(policy.RatePerSecond, max(policy.Burst, 1))
+	}
+
+	if policy.FailureThreshold > 0 {
+		c.breaker = &breaker{threshold: policy.FailureThreshold, openFor: policy.OpenFor}
+	}
+
+	return c
+}
+
+func (c *Client) Name() string {
+	return c.name
+}
+
+// State returns the state of the circuit breaker: closed, open or half-open.
+func (c *Client) State() string {
+	if c.breaker == nil {
+		return stateClosed
+	}
+
+	return c.breaker.current()
+}
+
+// Do sends the request like http.Client.Do. Requests whose answer is still
+// retryable once the retries are exhausted return that answer.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	attempts := 1
+	if isIdempotent(req) {
+		attempts += c.policy.MaxRetries
+	}
+
+	for attempt := 1; ; attempt++ {
+		if c.limiter != nil {
+			if err := c.limiter.wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		var trial bool
+		if c.breaker != nil {
+			var allowed bool
+			if allowed, trial = c.breaker.allow(); !allowed {
+				return nil, fmt.Errorf("%s: %w", c.name, ErrCircuitOpen)
+			}
+		}
+
+		r, err := c.send(req, attempt)
+		if err != nil && ctx.Err() != nil {
+			// The caller went away: this says nothing about the service.
+			if trial {
+				c.breaker.release()
+			}
+			return nil, err
+		}
+
+		if c.breaker != nil {
+			c.breaker.record(trial, err == nil && r.StatusCode < http.StatusInternalServerError)
+		}
+
+		if attempt >= attempts || (err == nil && !isRetryable(r.StatusCode)) {
+			return r, err
+		}
+
+		delay := c.backoff(attempt)
+		if r != nil {
+			if retryAfter, ok := parseRetryAfter(r.Header.Get("Retry-After")); ok {
+				if retryAfter > c.policy.MaxDelay {
+					return r, nil
+				}
+				delay = retryAfter
+			}
+
+			// Drain the body so that the connection can be reused.
+			io.Copy(io.Discard, io.LimitReader(r.Body, 64<<10))
+			r.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) send(req *http.Request, attempt int) (*http.Response, error) {
+	if attempt > 1 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+
+	if c.policy.Timeout <= 0 {
+		return c.client.Do(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), c.policy.Timeout)
+	r, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	r.Body = &cancelOnClose{ReadCloser: r.Body, cancel: cancel}
+	return r, nil
+}
+
+// backoff returns a random delay up to BaseDelay doubled at each attempt,
+// capped at MaxDelay.
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.policy.BaseDelay << (attempt - 1)
+	if delay <= 0 || (c.policy.MaxDelay > 0 && delay > c.policy.MaxDelay) {
+		delay = c.policy.MaxDelay
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// isIdempotent tells whether the request can be sent again, which requires
+// an idempotent method and a body that can be read again.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	}
+
+	return false
+}
+
+func isRetryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header, given either in seconds or as
+// an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+
+	return 0, false
+}
+
+// cancelOnClose releases the timeout of an attempt once its body is read.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,204 @@
+package outbound
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// statusServer answers each request with the next status of statuses, then
+// repeats the last one, and counts the requests it received.
+func statusServer(t *testing.T, header http.Header, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(calls.Add(1))
+		for key, values := range header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(statuses[min(call, len(statuses))-1])
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &calls
+}
+
+func testPolicy() Policy {
+	return Policy{
+		Timeout:    time.Second,
+		MaxRetries: 2,
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   10 * time.Millisecond,
+	}
+}
+
+func get(t *testing.T, client *Client, url string) (*http.Response, error) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := client.Do(req)
+	if err == nil {
+		t.Cleanup(func() { r.Body.Close() })
+	}
+
+	return r, err
+}
+
+func TestClientRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		status   int
+		calls    int32
+	}{
+		{"too many requests", []int{http.StatusTooManyRequests, http.StatusOK}, http.StatusOK, 2},
+		{"service unavailable", []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK}, http.StatusOK, 3},
+		{"retries exhausted", []int{http.StatusServiceUnavailable}, http.StatusServiceUnavailable, 3},
+		{"not retryable", []int{http.StatusNotFound, http.StatusOK}, http.StatusNotFound, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, calls := statusServer(t, nil, tt.statuses...)
+			client := New("test", server.Client(), testPolicy())
+
+			r, err := get(t, client, server.URL)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+
+			if r.StatusCode != tt.status {
+				t.Errorf("StatusCode = %d, want %d", r.StatusCode, tt.status)
+			}
+
+			if got := calls.Load(); got != tt.calls {
+				t.Errorf("calls = %d, want %d", got, tt.calls)
+			}
+		})
+	}
+}
+
+func TestClientDoesNotRetryPost(t *testing.T) {
+	server, calls := statusServer(t, nil, http.StatusServiceUnavailable, http.StatusOK)
+	client := New("test", server.Client(), testPolicy())
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Errorf("StatusCode = %d after %d calls, want %d after 1 call", r.StatusCode, calls.Load(), http.StatusServiceUnavailable)
+	}
+}
+
+func TestClientRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		status     int
+		calls      int32
+	}{
+		{"within the maximum delay", "0", http.StatusOK, 2},
+		{"beyond the maximum delay", "60", http.StatusTooManyRequests, 1},
+		{"date beyond the maximum delay", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), http.StatusTooManyRequests, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{"Retry-After": []string{tt.retryAfter}}
+			server, calls := statusServer(t, header, http.StatusTooManyRequests, http.StatusOK)
+			client := New("test", server.Client(), testPolicy())
+
+			start := time.Now()
+			r, err := get(t, client, server.URL)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("Do() took %v, want the long Retry-After not to be waited for", elapsed)
+			}
+
+			if r.StatusCode != tt.status {
+				t.Errorf("StatusCode = %d, want %d", r.StatusCode, tt.status)
+			}
+
+			if got := calls.Load(); got != tt.calls {
+				t.Errorf("calls = %d, want %d", got, tt.calls)
+			}
+		})
+	}
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	policy := testPolicy()
+	policy.MaxRetries = 0
+	policy.FailureThreshold = 2
+	policy.OpenFor = 50 * time.Millisecond
+	client := New("test", server.Client(), policy)
+
+	for range policy.FailureThreshold {
+		if _, err := get(t, client, server.URL); err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+	}
+
+	if state := client.State(); state != stateOpen {
+		t.Fatalf("State() = %q, want %q", state, stateOpen)
+	}
+
+	if _, err := get(t, client, server.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Do() error = %v, want %v", err, ErrCircuitOpen)
+	}
+
+	if got := calls.Load(); got != int32(policy.FailureThreshold) {
+		t.Errorf("calls = %d, want the open breaker not to call the service", got)
+	}
+
+	time.Sleep(policy.OpenFor)
+	if state := client.State(); state != stateHalfOpen {
+		t.Fatalf("State() = %q, want %q", state, stateHalfOpen)
+	}
+
+	failing.Store(false)
+	r, err := get(t, client, server.URL)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	if r.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %d, want %d", r.StatusCode, http.StatusOK)
+	}
+
+	if state := client.State(); state != stateClosed {
+		t.Errorf("State() = %q, want %q", state, stateClosed)
+	}
+}
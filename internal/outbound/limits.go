@@ -0,0 +1,135 @@
+package outbound
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	stateClosed   = "closed"
+	stateOpen     = "open"
+	stateHalfOpen = "half-open"
+)
+
+// tokenBucket holds up to burst tokens, refilled at rate tokens per second.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// wait takes a token, sleeping until one is available or ctx is done.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		delay := b.reserve()
+		if delay == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve takes a token and returns 0, or returns how long to wait for the
+// next one.
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// breaker opens after threshold consecutive failures. Once open for openFor,
+// it lets a single trial request through and closes again if it succeeds.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	openFor   time.Duration
+	failures  int
+	openedAt  time.Time
+	trying    bool
+}
+
+// allow tells whether a request can be sent, and whether it is the trial
+// request of a half-open breaker.
+func (b *breaker) allow() (allowed, trial bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true, false
+	}
+
+	if b.trying || time.Since(b.openedAt) < b.openFor {
+		return false, false
+	}
+
+	b.trying = true
+	return true, true
+}
+
+// record counts the outcome of a request admitted by allow. Only the trial
+// request ends the trial: requests sent before the breaker opened may finish
+// while it is half-open.
+func (b *breaker) record(trial, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if trial {
+		b.trying = false
+	}
+
+	if success {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold && (trial || b.failures == b.threshold) {
+		b.openedAt = time.Now()
+	}
+}
+
+// release ends a trial request without recording its outcome.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trying = false
+}
+
+func (b *breaker) current() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.failures < b.threshold:
+		return stateClosed
+	case b.trying || time.Since(b.openedAt) >= b.openFor:
+		return stateHalfOpen
+	default:
+		return stateOpen
+	}
+}
@@ -0,0 +1,46 @@
+package outbound
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketBurst(t *testing.T) {
+	bucket := newTokenBucket(1, 2)
+
+	for i := range 2 {
+		if delay := bucket.reserve(); delay != 0 {
+			t.Fatalf("reserve() #%d = %v, want 0 within the burst", i+1, delay)
+		}
+	}
+
+	if delay := bucket.reserve(); delay <= 0 || delay > time.Second {
+		t.Errorf("reserve() = %v, want a wait of up to 1s", delay)
+	}
+}
+
+func TestBreakerSingleTrial(t *testing.T) {
+	b := &breaker{threshold: 1}
+
+	// A request sent while the breaker is closed...
+	if allowed, trial := b.allow(); !allowed || trial {
+		t.Fatalf("allow() = %v, %v, want a regular request", allowed, trial)
+	}
+
+	// ...is still in flight when another one opens the breaker and the trial
+	// request starts.
+	b.record(false, false)
+	if allowed, trial := b.allow(); !allowed || !trial {
+		t.Fatalf("allow() = %v, %v, want the trial request", allowed, trial)
+	}
+
+	b.record(false, false)
+	if allowed, _ := b.allow(); allowed {
+		t.Fatal("allow() let a second trial request through")
+	}
+
+	b.record(true, true)
+	if state := b.current(); state != stateClosed {
+		t.Errorf("current() = %q, want %q", state, stateClosed)
+	}
+}
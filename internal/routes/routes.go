@@ -11,6 +11,7 @@ func SetupRoutes(app *app.Application) *chi.Mux {
 
 	r.Route("/api", func(r chi.Router) {
 		r.Get("/health", app.Health)
+		r.With(app.AuthMiddleware.Authenticate).Get("/metrics/metadata-cache", app.AuthMiddleware.RequireScope(app.MetadataCacheStats, []string{store.ScopeBooks}))
 
 		r.Route("/auth", func(r chi.Router) {
 			r.Post("/register", app.UserHandler.HandleRegisterUser)
@@ -20,6 +21,7 @@ func SetupRoutes(app *app.Application) *chi.Mux {
 				r.Use(app.AuthMiddleware.Authenticate)
 
 				r.Get("/me", app.AuthMiddleware.RequireScope(app.UserHandler.HandleMe, []string{store.ScopeAuth}))
+				r.Put("/me", app.AuthMiddleware.RequireScope(app.UserHandler.HandleUpdateProfile, []string{store.ScopeAuth}))
 				r.Put("/password", app.AuthMiddleware.RequireScope(app.UserHandler.HandleUpdatePassword, []string{store.ScopeAuth}))
 				r.Delete("/logout", app.AuthMiddleware.RequireUser(app.TokenHandler.HandleLogout))
 			})
@@ -30,20 +32,153 @@ func SetupRoutes(app *app.Application) *chi.Mux {
 
 			r.With(app.UtilsMiddleware.GetPagination).Get("/", app.AuthMiddleware.RequireScope(app.BookHandler.HandleGetBooks, []string{store.ScopeBooks}))
 			r.Post("/", app.AuthMiddleware.RequireScope(app.BookHandler.HandlerCreateBook, []string{store.ScopeBooks}))
-			r.Post("/import/{bbId}", app.AuthMiddleware.RequireScope(app.BookHandler.HandleAddBookByISBN, []string{store.ScopeBooks}))
+			r.Post("/batch", app.AuthMiddleware.RequireScope(app.BookHandler.HandleBatchBooks, []string{store.ScopeBooks}))
+			r.Post("/bulk-update", app.AuthMiddleware.RequireScope(app.BookHandler.HandleBulkUpdateBooks, []string{store.ScopeBooks}))
+			r.Get("/recommendations", app.AuthMiddleware.RequireScope(app.RecommendationHandler.HandleGetRecommendations, []string{store.ScopeBooks, store.ScopeWishlist}))
+			r.Get("/stats", app.AuthMiddleware.RequireScope(app.StatsHandler.HandleGetStats, []string{store.ScopeBooks, store.ScopeWishlist}))
+			r.Get("/export", app.AuthMiddleware.RequireScope(app.ExportHandler.HandleExport, []string{store.ScopeBooks, store.ScopeWishlist}))
+			r.Post("/scan", app.AuthMiddleware.RequireScope(app.CatalogHandler.HandleScan, []string{store.ScopeBooks}))
+			r.Post("/scan/batch", app.AuthMiddleware.RequireScope(app.CatalogHandler.HandleScanBatch, []string{store.ScopeBooks}))
+			r.Post("/import/isbn/{isbn}", app.AuthMiddleware.RequireScope(app.BookHandler.HandleAddBookByISBN, []string{store.ScopeBooks}))
+			r.Post("/import/{bbId}", app.AuthMiddleware.RequireScope(app.BookHandler.HandleAddBookByBigBookId, []string{store.ScopeBooks}))
 			r.Get("/{id}", app.AuthMiddleware.RequireScope(app.BookHandler.HandleGetBookById, []string{store.ScopeBooks}))
 			r.Put("/{id}", app.AuthMiddleware.RequireScope(app.BookHandler.HandleUpdateBook, []string{store.ScopeBooks}))
 			r.Delete("/{id}", app.AuthMiddleware.RequireScope(app.BookHandler.HandleDeleteBook, []string{store.ScopeBooks}))
+			r.Post("/{id}/cover", app.AuthMiddleware.RequireScope(app.CoverHandler.HandleUploadBookCover, []string{store.ScopeBooks}))
+			r.Delete("/{id}/cover", app.AuthMiddleware.RequireScope(app.CoverHandler.HandleDeleteBookCover, []string{store.ScopeBooks}))
+			r.Get("/{id}/history", app.AuthMiddleware.RequireScope(app.BookHandler.HandleGetBookHistory, []string{store.ScopeBooks}))
+			r.Get("/{id}/revisions", app.AuthMiddleware.RequireScope(app.BookRevisionHandler.HandleGetRevisions, []string{store.ScopeBooks}))
+			r.Post("/{id}/revisions/{rev}/revert", app.AuthMiddleware.RequireScope(app.BookRevisionHandler.HandleRevertRevision, []string{store.ScopeBooks}))
+			r.Get("/{id}/tags", app.AuthMiddleware.RequireScope(app.TagHandler.HandleGetBookTags, []string{store.ScopeBooks}))
+			r.Post("/{id}/tags", app.AuthMiddleware.RequireScope(app.TagHandler.HandleAddBookTags, []string{store.ScopeBooks}))
+			r.Delete("/{id}/tags/{tagId}", app.AuthMiddleware.RequireScope(app.TagHandler.HandleRemoveBookTag, []string{store.ScopeBooks}))
+			r.Get("/{id}/sessions", app.AuthMiddleware.RequireScope(app.ReadingSessionHandler.HandleGetSessions, []string{store.ScopeBooks}))
+			r.Post("/{id}/sessions", app.AuthMiddleware.RequireScope(app.ReadingSessionHandler.HandleCreateSession, []string{store.ScopeBooks}))
+			r.Put("/{id}/sessions/{sessionId}", app.AuthMiddleware.RequireScope(app.ReadingSessionHandler.HandleUpdateSession, []string{store.ScopeBooks}))
+			r.Delete("/{id}/sessions/{sessionId}", app.AuthMiddleware.RequireScope(app.ReadingSessionHandler.HandleDeleteSession, []string{store.ScopeBooks}))
+			r.Get("/{id}/reads", app.AuthMiddleware.RequireScope(app.BookReadHandler.HandleGetReads, []string{store.ScopeBooks}))
+			r.Post("/{id}/reads", app.AuthMiddleware.RequireScope(app.BookReadHandler.HandleCreateRead, []string{store.ScopeBooks}))
+			r.Put("/{id}/reads/{readId}", app.AuthMiddleware.RequireScope(app.BookReadHandler.HandleUpdateRead, []string{store.ScopeBooks}))
+			r.Delete("/{id}/reads/{readId}", app.AuthMiddleware.RequireScope(app.BookReadHandler.HandleDeleteRead, []string{store.ScopeBooks}))
+			r.Get("/{id}/quotes", app.AuthMiddleware.RequireScope(app.QuoteHandler.HandleGetBookQuotes, []string{store.ScopeBooks}))
+			r.Post("/{id}/quotes", app.AuthMiddleware.RequireScope(app.QuoteHandler.HandleCreateQuote, []string{store.ScopeBooks}))
+			r.Put("/{id}/quotes/{quoteId}", app.AuthMiddleware.RequireScope(app.QuoteHandler.HandleUpdateQuote, []string{store.ScopeBooks}))
+			r.Delete("/{id}/quotes/{quoteId}", app.AuthMiddleware.RequireScope(app.QuoteHandler.HandleDeleteQuote, []string{store.ScopeBooks}))
+			r.Get("/{id}/quotes/{quoteId}/tags", app.AuthMiddleware.RequireScope(app.QuoteHandler.HandleGetQuoteTags, []string{store.ScopeBooks}))
+			r.Post("/{id}/quotes/{quoteId}/tags", app.AuthMiddleware.RequireScope(app.QuoteHandler.HandleAddQuoteTags, []string{store.ScopeBooks}))
+			r.Delete("/{id}/quotes/{quoteId}/tags/{tagId}", app.AuthMiddleware.RequireScope(app.QuoteHandler.HandleRemoveQuoteTag, []string{store.ScopeBooks}))
+			r.Get("/{id}/loans", app.AuthMiddleware.RequireScope(app.LoanHandler.HandleGetBookLoans, []string{store.ScopeBooks}))
+			r.Post("/{id}/loans", app.AuthMiddleware.RequireScope(app.LoanHandler.HandleCreateLoan, []string{store.ScopeBooks}))
+			r.Put("/{id}/loans/{loanId}/return", app.AuthMiddleware.RequireScope(app.LoanHandler.HandleReturnLoan, []string{store.ScopeBooks}))
+			r.Delete("/{id}/loans/{loanId}", app.AuthMiddleware.RequireScope(app.LoanHandler.HandleDeleteLoan, []string{store.ScopeBooks}))
+			r.Get("/{id}/copies", app.AuthMiddleware.RequireScope(app.CopyHandler.HandleGetCopies, []string{store.ScopeBooks}))
+			r.Post("/{id}/copies", app.AuthMiddleware.RequireScope(app.CopyHandler.HandleCreateCopy, []string{store.ScopeBooks}))
+			r.Put("/{id}/copies/{copyId}", app.AuthMiddleware.RequireScope(app.CopyHandler.HandleUpdateCopy, []string{store.ScopeBooks}))
+			r.Delete("/{id}/copies/{copyId}", app.AuthMiddleware.RequireScope(app.CopyHandler.HandleDeleteCopy, []string{store.ScopeBooks}))
+		})
+
+		r.Route("/covers", func(r chi.Router) {
+			r.Use(app.AuthMiddleware.Authenticate)
+
+			r.Get("/{id}/{size}", app.AuthMiddleware.RequireScope(app.CoverHandler.HandleGetCover, []string{store.ScopeBooks}))
+		})
+
+		r.Route("/shelves", func(r chi.Router) {
+			r.Use(app.AuthMiddleware.Authenticate)
+
+			r.Get("/", app.AuthMiddleware.RequireScope(app.ShelfHandler.HandleGetShelves, []string{store.ScopeBooks}))
+			r.Post("/", app.AuthMiddleware.RequireScope(app.ShelfHandler.HandleCreateShelf, []string{store.ScopeBooks}))
+			r.Get("/{id}", app.AuthMiddleware.RequireScope(app.ShelfHandler.HandleGetShelf, []string{store.ScopeBooks}))
+			r.Put("/{id}", app.AuthMiddleware.RequireScope(app.ShelfHandler.HandleUpdateShelf, []string{store.ScopeBooks}))
+			r.Delete("/{id}", app.AuthMiddleware.RequireScope(app.ShelfHandler.HandleDeleteShelf, []string{store.ScopeBooks}))
+			r.Post("/{id}/books", app.AuthMiddleware.RequireScope(app.ShelfHandler.HandleAddBooks, []string{store.ScopeBooks}))
+			r.Delete("/{id}/books", app.AuthMiddleware.RequireScope(app.ShelfHandler.HandleRemoveBooks, []string{store.ScopeBooks}))
+			r.Put("/{id}/books/order", app.AuthMiddleware.RequireScope(app.ShelfHandler.HandleReorderBooks, []string{store.ScopeBooks}))
+		})
+
+		r.Route("/authors", func(r chi.Router) {
+			r.Use(app.AuthMiddleware.Authenticate)
+
+			r.Get("/", app.AuthMiddleware.RequireScope(app.AuthorHandler.HandleGetAuthors, []string{store.ScopeBooks}))
+			r.Get("/{id}", app.AuthMiddleware.RequireScope(app.AuthorHandler.HandleGetAuthor, []string{store.ScopeBooks}))
+		})
+
+		r.Route("/series", func(r chi.Router) {
+			r.Use(app.AuthMiddleware.Authenticate)
+
+			r.Get("/", app.AuthMiddleware.RequireScope(app.SeriesHandler.HandleGetUserSeries, []string{store.ScopeBooks}))
+			r.Post("/", app.AuthMiddleware.RequireScope(app.SeriesHandler.HandleCreateSeries, []string{store.ScopeBooks}))
+			r.Get("/{id}", app.AuthMiddleware.RequireScope(app.SeriesHandler.HandleGetSeries, []string{store.ScopeBooks}))
+			r.Get("/{id}/next", app.AuthMiddleware.RequireScope(app.SeriesHandler.HandleGetNextInSeries, []string{store.ScopeBooks}))
+			r.Put("/{id}", app.AuthMiddleware.RequireScope(app.SeriesHandler.HandleUpdateSeries, []string{store.ScopeBooks}))
+			r.Delete("/{id}", app.AuthMiddleware.RequireScope(app.SeriesHandler.HandleDeleteSeries, []string{store.ScopeBooks}))
+		})
+
+		r.Route("/goals", func(r chi.Router) {
+			r.Use(app.AuthMiddleware.Authenticate)
+
+			r.Get("/", app.AuthMiddleware.RequireScope(app.GoalHandler.HandleGetGoals, []string{store.ScopeBooks}))
+			r.Post("/", app.AuthMiddleware.RequireScope(app.GoalHandler.HandleCreateGoal, []string{store.ScopeBooks}))
+			r.Get("/events", app.AuthMiddleware.RequireScope(app.GoalHandler.HandleGetGoalEvents, []string{store.ScopeBooks}))
+			r.Get("/{id}", app.AuthMiddleware.RequireScope(app.GoalHandler.HandleGetGoal, []string{store.ScopeBooks}))
+			r.Put("/{id}", app.AuthMiddleware.RequireScope(app.GoalHandler.HandleUpdateGoal, []string{store.ScopeBooks}))
+			r.Delete("/{id}", app.AuthMiddleware.RequireScope(app.GoalHandler.HandleDeleteGoal, []string{store.ScopeBooks}))
+		})
+
+		r.Route("/quotes", func(r chi.Router) {
+			r.Use(app.AuthMiddleware.Authenticate)
+
+			r.With(app.UtilsMiddleware.GetPagination).Get("/", app.AuthMiddleware.RequireScope(app.QuoteHandler.HandleSearchQuotes, []string{store.ScopeBooks}))
+			r.Get("/daily", app.AuthMiddleware.RequireScope(app.QuoteHandler.HandleGetQuoteOfTheDay, []string{store.ScopeBooks}))
+			r.Post("/import/kindle", app.AuthMiddleware.RequireScope(app.QuoteHandler.HandleImportKindleClippings, []string{store.ScopeBooks}))
+		})
+
+		r.Route("/loans", func(r chi.Router) {
+			r.Use(app.AuthMiddleware.Authenticate)
+
+			r.Get("/", app.AuthMiddleware.RequireScope(app.LoanHandler.HandleGetLoans, []string{store.ScopeBooks}))
+			r.Get("/overdue", app.AuthMiddleware.RequireScope(app.LoanHandler.HandleGetOverdueLoans, []string{store.ScopeBooks}))
+		})
+
+		r.Route("/catalog", func(r chi.Router) {
+			r.Use(app.AuthMiddleware.Authenticate)
+
+			r.Get("/search", app.AuthMiddleware.RequireScope(app.CatalogHandler.HandleSearch, []string{store.ScopeBooks}))
+			r.Post("/{entryId}/library", app.AuthMiddleware.RequireScope(app.CatalogHandler.HandleAddToLibrary, []string{store.ScopeBooks}))
+			r.Post("/{entryId}/wishlist", app.AuthMiddleware.RequireScope(app.CatalogHandler.HandleAddToWishlist, []string{store.ScopeWishlist}))
+		})
+
+		r.Route("/tags", func(r chi.Router) {
+			r.Use(app.AuthMiddleware.Authenticate)
+
+			r.Get("/", app.AuthMiddleware.RequireScope(app.TagHandler.HandleGetTags, []string{store.ScopeBooks}))
+			r.Put("/{id}", app.AuthMiddleware.RequireScope(app.TagHandler.HandleRenameTag, []string{store.ScopeBooks}))
+			r.Delete("/{id}", app.AuthMiddleware.RequireScope(app.TagHandler.HandleDeleteTag, []string{store.ScopeBooks}))
+			r.Post("/{id}/merge", app.AuthMiddleware.RequireScope(app.TagHandler.HandleMergeTags, []string{store.ScopeBooks}))
 		})
 
 		r.Route("/wishes", func(r chi.Router) {
 			r.Use(app.AuthMiddleware.Authenticate)
 
 			r.Post("/", app.AuthMiddleware.RequireScope(app.WishlistHandler.HandleAddWish, []string{"wishlist"}))
+			r.Get("/{id}", app.AuthMiddleware.RequireScope(app.WishlistHandler.HandleGetWish, []string{store.ScopeWishlist}))
 			r.Delete("/{id}", app.AuthMiddleware.RequireScope(app.WishlistHandler.HandleDeleteWish, []string{"wishlist"}))
 			r.Put("/{id}/acquire", app.AuthMiddleware.RequireScope(app.WishlistHandler.HandleMarkAsAcquired, []string{"wishlist"}))
+			r.Get("/{id}/tags", app.AuthMiddleware.RequireScope(app.TagHandler.HandleGetWishTags, []string{store.ScopeWishlist}))
+			r.Post("/{id}/tags", app.AuthMiddleware.RequireScope(app.TagHandler.HandleAddWishTags, []string{store.ScopeWishlist}))
+			r.Delete("/{id}/tags/{tagId}", app.AuthMiddleware.RequireScope(app.TagHandler.HandleRemoveWishTag, []string{store.ScopeWishlist}))
 			r.With(app.UtilsMiddleware.GetPagination).Get("/", app.AuthMiddleware.RequireScope(app.WishlistHandler.HandleGetWishes, []string{store.ScopeWishlist}))
 		})
+
+		r.Route("/trash", func(r chi.Router) {
+			r.Use(app.AuthMiddleware.Authenticate)
+
+			r.Get("/", app.AuthMiddleware.RequireScope(app.TrashHandler.HandleGetTrash, []string{store.ScopeBooks, store.ScopeWishlist}))
+			r.Post("/books/{id}/restore", app.AuthMiddleware.RequireScope(app.TrashHandler.HandleRestoreBook, []string{store.ScopeBooks}))
+			r.Delete("/books/{id}", app.AuthMiddleware.RequireScope(app.TrashHandler.HandlePurgeBook, []string{store.ScopeBooks}))
+			r.Post("/wishes/{id}/restore", app.AuthMiddleware.RequireScope(app.TrashHandler.HandleRestoreWish, []string{store.ScopeWishlist}))
+			r.Delete("/wishes/{id}", app.AuthMiddleware.RequireScope(app.TrashHandler.HandlePurgeWish, []string{store.ScopeWishlist}))
+		})
 	})
 
 	return r
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+// bookTransitions lists the statuses a book can move to from each status. A
+// finished book is read again rather than put back on the reading list, so
+// that its reading history stays consistent.
+var bookTransitions = map[string][]string{
+	store.BookStatusToRead:  {store.BookStatusReading, store.BookStatusRead},
+	store.BookStatusReading: {store.BookStatusToRead, store.BookStatusRead},
+	store.BookStatusRead:    {store.BookStatusReading},
+}
+
+// BookStatusChange is the status and reading dates a payload asks for. Nil
+// fields are left unchanged.
+type BookStatusChange struct {
+	Status       *string
+	DateStarted  *time.Time
+	DateFinished *time.Time
+}
+
+func (c BookStatusChange) isEmpty() bool {
+	return c.Status == nil && c.DateStarted == nil && c.DateFinished == nil
+}
+
+// ApplyBookStatusChange moves the book to the requested status and dates. A
+// new book has an empty status and can start in any status. Starting a book
+// stamps date_started and finishing it stamps date_finished, unless the
+// payload sets them. It returns field-level errors, and leaves the book
+// untouched, when the move is not allowed or the dates do not fit the status.
+func ApplyBookStatusChange(book *store.Book, change BookStatusChange, now time.Time) map[string]string {
+	errorMessages := make(map[string]string)
+	if book.Status != "" && change.isEmpty() {
+		return errorMessages
+	}
+
+	from, to := book.Status, book.Status
+	if change.Status != nil {
+		to = *change.Status
+	}
+
+	if from != "" && to != from && !slices.Contains(bookTransitions[from], to) {
+		errorMessages["status"] = fmt.Sprintf("a book cannot go from %s to %s", from, to)
+		return errorMessages
+	}
+
+	started, finished := book.DateStarted, book.DateFinished
+	if to != from {
+		switch to {
+		case store.BookStatusToRead:
+			started, finished = nil, nil
+		case store.BookStatusReading:
+			// Reading a finished book again starts a new reading cycle.
+			if from == store.BookStatusRead {
+				started = nil
+			}
+			finished = nil
+		}
+	}
+
+	if change.DateStarted != nil {
+		started = change.DateStarted
+	}
+
+	if change.DateFinished != nil {
+		finished = change.DateFinished
+	}
+
+	switch to {
+	case store.BookStatusToRead:
+		if started != nil {
+			errorMessages["date_started"] = "a book to read cannot have a date_started"
+		}
+
+		if finished != nil {
+			errorMessages["date_finished"] = "a book to read cannot have a date_finished"
+		}
+	case store.BookStatusReading:
+		if started == nil {
+			started = &now
+		}
+
+		if finished != nil {
+			errorMessages["date_finished"] = "a book being read cannot have a date_finished"
+		}
+	case store.BookStatusRead:
+		if finished == nil {
+			finished = &now
+		}
+	}
+
+	// Dates are days: a book can be started and finished on the same day,
+	// whatever the time of day each of them was stamped at.
+	if started != nil && finished != nil && dayOf(*finished).Before(dayOf(*started)) {
+		errorMessages["date_finished"] = "date_finished cannot be before date_started"
+	}
+
+	if len(errorMessages) > 0 {
+		return errorMessages
+	}
+
+	book.Status = to
+	book.DateStarted = started
+	book.DateFinished = finished
+
+	return errorMessages
+}
+
+// dayOf returns the calendar day of t, in the location of t.
+func dayOf(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
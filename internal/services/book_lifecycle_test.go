@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+func TestApplyBookStatusChangeDates(t *testing.T) {
+	now := time.Date(2024, 5, 3, 9, 30, 0, 0, time.UTC)
+	evening := time.Date(2024, 5, 3, 18, 0, 0, 0, time.UTC)
+	day := func(year int, month time.Month, day int) *time.Time {
+		date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		return &date
+	}
+
+	tests := []struct {
+		name     string
+		started  *time.Time
+		finished *time.Time
+		valid    bool
+	}{
+		{"finished the day it was started, stamped later", &now, day(2024, 5, 3), true},
+		{"finished after it was started", day(2024, 5, 1), day(2024, 5, 3), true},
+		{"finished before it was started", day(2024, 5, 3), day(2024, 5, 2), false},
+		{"finished now, started later the same day", &evening, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := &store.Book{Status: store.BookStatusToRead}
+			read := store.BookStatusRead
+
+			errors := ApplyBookStatusChange(book, BookStatusChange{Status: &read, DateStarted: tt.started, DateFinished: tt.finished}, now)
+			if valid := len(errors) == 0; valid != tt.valid {
+				t.Fatalf("ApplyBookStatusChange() errors = %v, want valid = %v", errors, tt.valid)
+			}
+
+			if tt.valid && book.Status != store.BookStatusRead {
+				t.Errorf("Status = %q, want %q", book.Status, store.BookStatusRead)
+			}
+		})
+	}
+}
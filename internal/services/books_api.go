@@ -2,20 +2,23 @@ package services
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"net/url"
 	"os"
-	"time"
+	"strconv"
+	"strings"
+
+	"github.com/martialanouman/personal-library/internal/outbound"
 )
 
-type BookAPIClient struct {
-	client  http.Client
+// BigBookProvider looks books up in Big Book API.
+type BigBookProvider struct {
+	client  *outbound.Client
 	baseURL string
 	token   string
+	cache   *MetadataCache
 	logger  *log.Logger
 }
 
@@ -33,35 +36,33 @@ type Identifiers struct {
 	Isbn13 string `json:"isbn_13"`
 }
 
+type APISeries struct {
+	Name         string  `json:"name"`
+	Position     float64 `json:"number"`
+	TotalVolumes int     `json:"total_volumes"`
+}
+
 type APIBook struct {
-	ID          int         `json:"id"`
-	Title       string      `json:"title"`
-	Image       string      `json:"image"`
-	Authors     []Author    `json:"authors"`
-	Rating      Rating      `json:"rating"`
-	Identifiers Identifiers `json:"identifiers"`
-	Description string      `json:"description"`
+	ID            int         `json:"id"`
+	Title         string      `json:"title"`
+	Image         string      `json:"image"`
+	Authors       []Author    `json:"authors"`
+	Rating        Rating      `json:"rating"`
+	Identifiers   Identifiers `json:"identifiers"`
+	Description   string      `json:"description"`
+	NumberOfPages int         `json:"number_of_pages"`
+	Series        *APISeries  `json:"series,omitempty"`
 }
 
-func NewBookAPIService(logger *log.Logger) (*BookAPIClient, error) {
-	client := http.Client{
-		Timeout: 60 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 10,
-			IdleConnTimeout:     90 * time.Second,
-			DisableCompression:  false,
-			DisableKeepAlives:   false,
-		},
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if len(via) >= 10 {
-				return errors.New("stopped after 10 redirects")
-			}
-
-			return nil
-		},
-	}
+type bigBookSearchResponse struct {
+	Books [][]struct {
+		ID    int    `json:"id"`
+		Title string `json:"title"`
+		Image string `json:"image"`
+	} `json:"books"`
+}
 
+func NewBigBookProvider(client *outbound.Client, cache *MetadataCache, logger *log.Logger) (*BigBookProvider, error) {
 	token := os.Getenv("BIG_BOOK_API_TOKEN")
 	if token == "" {
 		return nil, errors.New("BIG_BOOK_API_TOKEN environment variable is not set")
@@ -71,15 +72,20 @@ func NewBookAPIService(logger *log.Logger) (*BookAPIClient, error) {
 		baseURL = "https://api.bigbookapi.com"
 	}
 
-	return &BookAPIClient{
+	return &BigBookProvider{
 		client:  client,
 		baseURL: baseURL,
 		token:   token,
+		cache:   cache,
 		logger:  logger,
 	}, nil
 }
 
-func (b *BookAPIClient) GetBookByBigBookId(ctx context.Context, bbId string) (*APIBook, error) {
+func (b *BigBookProvider) Name() string {
+	return ProviderBigBook
+}
+
+func (b *BigBookProvider) GetBookByBigBookId(ctx context.Context, bbId string) (*APIBook, error) {
 	if bbId == "" {
 		return nil, errors.New("bbId cannot be empty")
 	}
@@ -89,43 +95,104 @@ func (b *BookAPIClient) GetBookByBigBookId(ctx context.Context, bbId string) (*A
 		return nil, fmt.Errorf("failed to join URL path: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	var book APIBook
+	err = b.cache.Fetch(ctx, ProviderBigBook, bbId, &book, func(ctx context.Context) (any, error) {
+		var book APIBook
+		if err := getJSON(ctx, b.client, u, map[string]string{"x-api-key": b.token}, &book); err != nil {
+			return nil, err
+		}
+		return &book, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		b.logger.Printf("big book request for %s failed: %v", bbId, err)
+		return nil, err
 	}
 
-	req.Header.Set("x-api-key", b.token)
-	req.Header.Set("Accept", "application/json")
+	return &book, nil
+}
 
-	r, err := b.client.Do(req)
+// LookupISBN searches the ISBN, then fetches the first book found.
+func (b *BigBookProvider) LookupISBN(ctx context.Context, isbn string) (*BookMetadata, error) {
+	u, err := url.JoinPath(b.baseURL, "search-books")
 	if err != nil {
-		b.logger.Printf("request failed with error: %v", err)
+		return nil, fmt.Errorf("failed to join URL path: %w", err)
+	}
+
+	u += "?" + url.Values{"query": {isbn}, "number": {"1"}}.Encode()
+
+	var search bigBookSearchResponse
+	if err := getJSON(ctx, b.client, u, map[string]string{"x-api-key": b.token}, &search); err != nil {
 		return nil, err
 	}
 
-	defer r.Body.Close()
+	if len(search.Books) == 0 || len(search.Books[0]) == 0 {
+		return nil, ErrMetadataNotFound
+	}
 
-	if r.StatusCode != http.StatusOK {
-		b.logger.Printf("request failed with status code: %d", r.StatusCode)
-		switch r.StatusCode {
-		case http.StatusNotFound:
-			return nil, fmt.Errorf("book with ID %s not found", bbId)
-		case http.StatusUnauthorized:
-			return nil, errors.New("unauthorized: invalid API token")
-		case http.StatusTooManyRequests:
-			return nil, errors.New("rate limit exceeded: too many requests")
-		default:
-			return nil, fmt.Errorf("request failed with status code: %d", r.StatusCode)
-		}
+	book, err := b.GetBookByBigBookId(ctx, strconv.Itoa(search.Books[0][0].ID))
+	if err != nil {
+		return nil, err
 	}
 
-	var book APIBook
+	return book.Metadata(), nil
+}
 
-	err = json.NewDecoder(r.Body).Decode(&book)
+// Search only knows the title and cover of the books it finds.
+func (b *BigBookProvider) Search(ctx context.Context, query CatalogQuery) ([]BookMetadata, error) {
+	u, err := url.JoinPath(b.baseURL, "search-books")
 	if err != nil {
-		b.logger.Printf("failed to decode response body: %v", err)
+		return nil, fmt.Errorf("failed to join URL path: %w", err)
+	}
+
+	params := url.Values{"query": {strings.TrimSpace(query.Query + " " + query.Isbn)}, "number": {strconv.Itoa(query.Limit)}}
+	if query.Author != "" {
+		params.Set("authors", query.Author)
+	}
+	u += "?" + params.Encode()
+
+	var search bigBookSearchResponse
+	if err := getJSON(ctx, b.client, u, map[string]string{"x-api-key": b.token}, &search); err != nil {
 		return nil, err
 	}
 
-	return &book, nil
+	hits := []BookMetadata{}
+	for _, group := range search.Books {
+		for _, book := range group {
+			hits = append(hits, BookMetadata{Title: book.Title, CoverURL: book.Image, BigBookID: strconv.Itoa(book.ID)})
+		}
+	}
+
+	return hits, nil
+}
+
+// Metadata converts the book to provider independent metadata. Big Book API
+// rates books between 0 and 1.
+func (book *APIBook) Metadata() *BookMetadata {
+	metadata := &BookMetadata{
+		Title:       book.Title,
+		Isbn10:      book.Identifiers.Isbn10,
+		Isbn13:      book.Identifiers.Isbn13,
+		Description: book.Description,
+		CoverURL:    book.Image,
+		PageCount:   book.NumberOfPages,
+		Rating:      book.Rating.Average * 5,
+	}
+
+	if book.ID > 0 {
+		metadata.BigBookID = strconv.Itoa(book.ID)
+	}
+
+	for _, author := range book.Authors {
+		metadata.Authors = append(metadata.Authors, author.Name)
+	}
+
+	if book.Series != nil && book.Series.Name != "" {
+		metadata.Series = &MetadataSeries{
+			Name:         book.Series.Name,
+			Position:     book.Series.Position,
+			TotalVolumes: book.Series.TotalVolumes,
+		}
+	}
+
+	return metadata
 }
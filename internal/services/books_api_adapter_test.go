@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestBigBookLookupISBN(t *testing.T) {
+	server := newFixtureServer(t, map[string]http.HandlerFunc{
+		"/search-books": fixture(t, "bigbook_search.json"),
+		"/1834217":      fixture(t, "bigbook_book.json"),
+	})
+	provider := newTestBigBookProvider(server, newTestMetadataCache())
+
+	metadata, err := provider.LookupISBN(context.Background(), "9780441172719")
+	if err != nil {
+		t.Fatalf("LookupISBN() error = %v", err)
+	}
+
+	if metadata.Title != "Dune" {
+		t.Errorf("Title = %q, want %q", metadata.Title, "Dune")
+	}
+
+	if len(metadata.Authors) != 1 || metadata.Authors[0] != "Frank Herbert" {
+		t.Errorf("Authors = %v, want [Frank Herbert]", metadata.Authors)
+	}
+
+	if metadata.Isbn13 != "9780441172719" || metadata.Isbn10 != "0441172717" {
+		t.Errorf("ISBNs = %q, %q", metadata.Isbn13, metadata.Isbn10)
+	}
+
+	// Big Book rates between 0 and 1.
+	if metadata.Rating != 0.86*5 {
+		t.Errorf("Rating = %v, want %v", metadata.Rating, 0.86*5)
+	}
+
+	if metadata.Series == nil || metadata.Series.Name != "Dune" || metadata.Series.Position != 1 || metadata.Series.TotalVolumes != 6 {
+		t.Errorf("Series = %+v", metadata.Series)
+	}
+
+	if metadata.BigBookID != "1834217" {
+		t.Errorf("BigBookID = %q, want %q", metadata.BigBookID, "1834217")
+	}
+
+	// The book is cached by its id.
+	if _, err := provider.GetBookByBigBookId(context.Background(), "1834217"); err != nil {
+		t.Fatalf("GetBookByBigBookId() error = %v", err)
+	}
+
+	if server.Calls("/1834217") != 1 {
+		t.Errorf("book was fetched %d times, want 1", server.Calls("/1834217"))
+	}
+}
+
+func TestBigBookLookupISBNNotFound(t *testing.T) {
+	server := newFixtureServer(t, map[string]http.HandlerFunc{
+		"/search-books": fixture(t, "bigbook_search_empty.json"),
+	})
+	provider := newTestBigBookProvider(server, newTestMetadataCache())
+
+	_, err := provider.LookupISBN(context.Background(), "9780000000002")
+	if !errors.Is(err, ErrMetadataNotFound) {
+		t.Fatalf("LookupISBN() error = %v, want %v", err, ErrMetadataNotFound)
+	}
+}
+
+func TestBigBookLookupISBNRateLimited(t *testing.T) {
+	server := newFixtureServer(t, map[string]http.HandlerFunc{
+		"/search-books": status(http.StatusPaymentRequired),
+	})
+	provider := newTestBigBookProvider(server, newTestMetadataCache())
+
+	_, err := provider.LookupISBN(context.Background(), "9780441172719")
+	if !errors.Is(err, ErrMetadataRateLimited) {
+		t.Fatalf("LookupISBN() error = %v, want %v", err, ErrMetadataRateLimited)
+	}
+}
+
+func TestBigBookSearch(t *testing.T) {
+	server := newFixtureServer(t, map[string]http.HandlerFunc{
+		"/search-books": fixture(t, "bigbook_search.json"),
+	})
+	provider := newTestBigBookProvider(server, newTestMetadataCache())
+
+	hits, err := provider.Search(context.Background(), CatalogQuery{Query: "dune", Limit: 1})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+
+	if len(hits) != 1 || hits[0].Title != "Dune" || hits[0].BigBookID != "1834217" {
+		t.Errorf("Search() = %+v", hits)
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+const (
+	coverFetchInterval   = time.Minute
+	coverFetchBatchSize  = 20
+	coverFetchRetryAfter = 24 * time.Hour
+)
+
+// CoverFetcher downloads the cover_url of the books into the cover storage,
+// so that covers are served by the application instead of third-party hosts.
+// Failed downloads are tried again a day later.
+type CoverFetcher struct {
+	store  store.CoverStore
+	covers *CoverService
+	logger *log.Logger
+}
+
+func NewCoverFetcher(store store.CoverStore, covers *CoverService, logger *log.Logger) *CoverFetcher {
+	return &CoverFetcher{store: store, covers: covers, logger: logger}
+}
+
+// Run downloads the pending covers every minute until ctx is done.
+func (f *CoverFetcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(coverFetchInterval)
+	defer ticker.Stop()
+
+	for {
+		f.fetch(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (f *CoverFetcher) fetch(ctx context.Context) {
+	pending, err := f.store.GetCoversToFetch(time.Now().Add(-coverFetchRetryAfter), coverFetchBatchSize)
+	if err != nil {
+		f.logger.Printf("ERROR: getting covers to fetch %v", err)
+		return
+	}
+
+	for _, book := range pending {
+		if ctx.Err() != nil {
+			return
+		}
+
+		data, err := f.covers.Download(ctx, book.CoverUrl)
+		if err == nil {
+			_, err = f.covers.Save(ctx, book.BookID, book.UserID, &book.CoverUrl, data)
+		}
+
+		switch {
+		case err == nil, ctx.Err() != nil:
+		case errors.Is(err, pgx.ErrNoRows):
+			// The book changed during the download and is picked up again.
+		case errors.Is(err, ErrCoverUnavailable), errors.Is(err, ErrInvalidCover):
+			f.logger.Printf("WARNING: fetching cover of book %s: %v", book.BookID, err)
+			f.markFetched(book.BookID)
+		default:
+			f.logger.Printf("ERROR: fetching cover of book %s %v", book.BookID, err)
+			f.markFetched(book.BookID)
+		}
+	}
+}
+
+func (f *CoverFetcher) markFetched(bookId string) {
+	if err := f.store.MarkCoverFetched(bookId); err != nil {
+		f.logger.Printf("ERROR: marking cover fetched %v", err)
+	}
+}
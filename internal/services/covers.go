@@ -0,0 +1,332 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/martialanouman/personal-library/internal/outbound"
+	"github.com/martialanouman/personal-library/internal/storage"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+const (
+	CoverSizeSmall    = "small"
+	CoverSizeMedium   = "medium"
+	CoverSizeLarge    = "large"
+	CoverSizeOriginal = "original"
+
+	// MaxCoverSize is the largest cover image accepted, in bytes.
+	MaxCoverSize = 5 << 20
+
+	maxImagePixels        = 16_000_000
+	coverThumbnailQuality = 85
+)
+
+// coverWidths are the widths of the thumbnails. Covers narrower than a size
+// are not enlarged.
+var coverWidths = map[string]int{
+	CoverSizeSmall:  100,
+	CoverSizeMedium: 300,
+	CoverSizeLarge:  600,
+}
+
+var CoverSizes = []string{CoverSizeSmall, CoverSizeMedium, CoverSizeLarge, CoverSizeOriginal}
+
+var coverContentTypes = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+}
+
+var (
+	// ErrInvalidCover is returned for files that are not a JPEG, PNG or GIF
+	// image, or that are too large.
+	ErrInvalidCover = errors.New("invalid cover image")
+	// ErrCoverUnavailable is returned when a cover cannot be downloaded.
+	ErrCoverUnavailable = errors.New("cover unavailable")
+)
+
+// CoverService downloads covers and stores them with their thumbnails.
+type CoverService struct {
+	store   store.CoverStore
+	storage storage.Storage
+	client  *outbound.Client
+	logger  *log.Logger
+}
+
+func NewCoverService(coverStore store.CoverStore, storage storage.Storage, logger *log.Logger) *CoverService {
+	policy := outbound.DefaultPolicy()
+	// Covers come from many hosts: one failing must not stop the others.
+	policy.RatePerSecond = 0
+	policy.FailureThreshold = 0
+
+	return &CoverService{
+		store:   coverStore,
+		storage: storage,
+		client:  outbound.New("covers", newCoverHTTPClient(), policy),
+		logger:  logger,
+	}
+}
+
+// newCoverHTTPClient returns a client that only connects to public
+// addresses, so that cover URLs cannot reach the internal network.
+func newCoverHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+				return fmt.Errorf("connection to %s is not allowed", host)
+			}
+
+			return nil
+		},
+	}
+
+	client := newHTTPClient()
+	client.Transport.(*http.Transport).DialContext = dialer.DialContext
+
+	return client
+}
+
+// Download fetches the image at u.
+func (s *CoverService) Download(ctx context.Context, u string) ([]byte, error) {
+	parsed, err := url.Parse(u)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("%w: invalid URL %q", ErrCoverUnavailable, u)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "image/jpeg, image/png, image/gif")
+
+	r, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCoverUnavailable, err)
+	}
+
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status code %d", ErrCoverUnavailable, r.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, MaxCoverSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCoverUnavailable, err)
+	}
+
+	if len(data) > MaxCoverSize {
+		return nil, fmt.Errorf("%w: larger than %d bytes", ErrInvalidCover, MaxCoverSize)
+	}
+
+	return data, nil
+}
+
+// Save stores the image with its thumbnails and makes it the cover of the
+// book. sourceUrl is the URL the image was downloaded from, nil for uploads.
+// The stored files are deleted again when the cover cannot be set, unless
+// another cover uses them.
+func (s *CoverService) Save(ctx context.Context, bookId, userId string, sourceUrl *string, data []byte) (_ *store.Cover, err error) {
+	if len(data) > MaxCoverSize {
+		return nil, fmt.Errorf("%w: larger than %d bytes", ErrInvalidCover, MaxCoverSize)
+	}
+
+	img, format, err := decodeImage(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCover, err)
+	}
+
+	contentType, ok := coverContentTypes[format]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported format %s", ErrInvalidCover, format)
+	}
+
+	sum := sha256.Sum256(data)
+	cover := &store.Cover{
+		BookID:      bookId,
+		UserID:      userId,
+		SourceUrl:   sourceUrl,
+		Hash:        hex.EncodeToString(sum[:]),
+		ContentType: contentType,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+	}
+
+	hash := cover.Hash
+	defer func() {
+		if err != nil {
+			s.discardFiles(ctx, hash)
+		}
+	}()
+
+	if err := s.storage.Put(ctx, coverKey(cover.Hash, CoverSizeOriginal), bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to store cover: %w", err)
+	}
+
+	flat := flatten(img)
+	for size, width := range coverWidths {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, thumbnail(flat, width), &jpeg.Options{Quality: coverThumbnailQuality}); err != nil {
+			return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+		}
+
+		if err := s.storage.Put(ctx, coverKey(cover.Hash, size), &buf); err != nil {
+			return nil, fmt.Errorf("failed to store thumbnail: %w", err)
+		}
+	}
+
+	orphans, err := s.store.SetBookCover(cover)
+	if err != nil {
+		return nil, err
+	}
+
+	s.DeleteFiles(ctx, orphans)
+
+	return cover, nil
+}
+
+// Remove deletes the cover of the book and the files no other book uses.
+func (s *CoverService) Remove(ctx context.Context, bookId string) error {
+	orphans, err := s.store.RemoveBookCover(bookId)
+	if err != nil {
+		return err
+	}
+
+	s.DeleteFiles(ctx, orphans)
+
+	return nil
+}
+
+// Open returns the file of the cover at size with its content type.
+func (s *CoverService) Open(ctx context.Context, cover *store.Cover, size string) (io.ReadCloser, string, error) {
+	contentType := "image/jpeg"
+	if size == CoverSizeOriginal {
+		contentType = cover.ContentType
+	}
+
+	file, err := s.storage.Get(ctx, coverKey(cover.Hash, size))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return file, contentType, nil
+}
+
+// DeleteFiles deletes the files of the covers with the given hashes, logging
+// the failures.
+func (s *CoverService) DeleteFiles(ctx context.Context, hashes []string) {
+	for _, hash := range hashes {
+		for _, size := range CoverSizes {
+			if err := s.storage.Delete(ctx, coverKey(hash, size)); err != nil {
+				s.logger.Printf("ERROR: deleting cover file %v", err)
+			}
+		}
+	}
+}
+
+// discardFiles deletes the files stored under hash unless a cover uses them.
+func (s *CoverService) discardFiles(ctx context.Context, hash string) {
+	used, err := s.store.IsCoverHashUsed(hash)
+	if err != nil {
+		s.logger.Printf("ERROR: checking cover files use %v", err)
+		return
+	}
+
+	if !used {
+		s.DeleteFiles(ctx, []string{hash})
+	}
+}
+
+func coverKey(hash, size string) string {
+	return fmt.Sprintf("covers/%s/%s/%s", hash[:2], hash, size)
+}
+
+// decodeImage decodes a JPEG, PNG or GIF image, refusing the ones whose
+// dimensions would take too much memory once decoded.
+func decodeImage(data []byte) (image.Image, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxImagePixels {
+		return nil, "", fmt.Errorf("unsupported dimensions %dx%d", config.Width, config.Height)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return img, format, nil
+}
+
+// flatten draws the image on white since JPEG thumbnails have no alpha
+// channel.
+func flatten(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), src, bounds.Min, draw.Over)
+
+	return flat
+}
+
+// thumbnail scales the image down to width, keeping its aspect ratio, by
+// averaging the source pixels covered by each destination pixel.
+func thumbnail(src *image.RGBA, width int) image.Image {
+	srcWidth, srcHeight := src.Bounds().Dx(), src.Bounds().Dy()
+	if srcWidth <= width {
+		return src
+	}
+	height := max(1, srcHeight*width/srcWidth)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		y0, y1 := y*srcHeight/height, max((y+1)*srcHeight/height, y*srcHeight/height+1)
+		for x := range width {
+			x0, x1 := x*srcWidth/width, max((x+1)*srcWidth/width, x*srcWidth/width+1)
+
+			var r, g, b, count uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					i := src.PixOffset(sx, sy)
+					r += uint32(src.Pix[i])
+					g += uint32(src.Pix[i+1])
+					b += uint32(src.Pix[i+2])
+					count++
+				}
+			}
+
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / count), uint8(g / count), uint8(b / count), 0xff})
+		}
+	}
+
+	return dst
+}
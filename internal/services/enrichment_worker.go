@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+const (
+	enrichmentPollInterval = 5 * time.Second
+	enrichmentScanInterval = time.Minute
+	enrichmentBatchSize    = 10
+	enrichmentScanSize     = 100
+	enrichmentMaxAttempts  = 5
+	enrichmentRetryDelay   = time.Minute
+	enrichmentMaxDelay     = 6 * time.Hour
+
+	metadataCachePurgeInterval = time.Hour
+
+	defaultMetadataRefreshAfter = 30 * 24 * time.Hour
+)
+
+// EnrichmentWorker fills the missing cover, description, page count and
+// identifiers of books and wishes from the metadata providers, and refreshes
+// the fields they filled once stale. Fields set by the user are never
+// overwritten. Lookups go through the providers' outbound clients, which
+// enforce their rate limits.
+type EnrichmentWorker struct {
+	store        store.EnrichmentStore
+	metadata     *MetadataChain
+	bigBook      *BigBookProvider
+	refreshAfter time.Duration
+	logger       *log.Logger
+}
+
+// NewEnrichmentWorker reads how long fields filled by providers are kept
+// before being refreshed from the METADATA_REFRESH_AFTER duration. bigBook
+// may be nil.
+func NewEnrichmentWorker(store store.EnrichmentStore, metadata *MetadataChain, bigBook *BigBookProvider, logger *log.Logger) *EnrichmentWorker {
+	return &EnrichmentWorker{
+		store:        store,
+		metadata:     metadata,
+		bigBook:      bigBook,
+		refreshAfter: durationFromEnv("METADATA_REFRESH_AFTER", defaultMetadataRefreshAfter, logger),
+		logger:       logger,
+	}
+}
+
+// Run queues the books and wishes to enrich every minute and runs the queued
+// jobs until ctx is done. A job interrupted by the shutdown is queued again.
+// The metadata cache is purged of its expired answers every hour.
+func (w *EnrichmentWorker) Run(ctx context.Context) {
+	if count, err := w.store.ResetRunningEnrichmentJobs(); err != nil {
+		w.logger.Printf("ERROR: resetting running enrichment jobs %v", err)
+	} else if count > 0 {
+		w.logger.Printf("requeued %d interrupted enrichment jobs", count)
+	}
+
+	poll := time.NewTicker(enrichmentPollInterval)
+	defer poll.Stop()
+
+	var lastScan, lastPurge time.Time
+	for {
+		if time.Since(lastScan) >= enrichmentScanInterval {
+			w.schedule()
+			lastScan = time.Now()
+		}
+
+		if time.Since(lastPurge) >= metadataCachePurgeInterval {
+			w.metadata.cache.PurgeExpired()
+			lastPurge = time.Now()
+		}
+
+		w.runJobs(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+		}
+	}
+}
+
+func (w *EnrichmentWorker) schedule() {
+	count, err := w.store.ScheduleEnrichments(time.Now().Add(-w.refreshAfter), enrichmentScanSize)
+	if err != nil {
+		w.logger.Printf("ERROR: scheduling enrichment jobs %v", err)
+		return
+	}
+
+	if count > 0 {
+		w.logger.Printf("scheduled %d enrichment jobs", count)
+	}
+}
+
+func (w *EnrichmentWorker) runJobs(ctx context.Context) {
+	for ctx.Err() == nil {
+		jobs, err := w.store.ClaimEnrichmentJobs(enrichmentBatchSize)
+		if err != nil {
+			w.logger.Printf("ERROR: claiming enrichment jobs %v", err)
+			return
+		}
+
+		if len(jobs) == 0 {
+			return
+		}
+
+		for i := range jobs {
+			if ctx.Err() != nil {
+				// Give the claimed jobs back to run them after the restart.
+				w.retry(&jobs[i], "interrupted by shutdown", time.Now())
+				continue
+			}
+
+			w.runJob(ctx, &jobs[i])
+		}
+	}
+}
+
+func (w *EnrichmentWorker) runJob(ctx context.Context, job *store.EnrichmentJob) {
+	target, err := w.store.GetEnrichmentTarget(job.TargetType, job.TargetID)
+	if err != nil {
+		w.logger.Printf("ERROR: getting enrichment target %v", err)
+		w.retry(job, err.Error(), w.nextRun(job))
+		return
+	}
+
+	if target == nil {
+		w.complete(job)
+		return
+	}
+
+	metadata, err := w.lookup(ctx, target)
+	switch {
+	case ctx.Err() != nil:
+		w.retry(job, "interrupted by shutdown", time.Now())
+		return
+	case errors.Is(err, ErrMetadataNotFound):
+		w.complete(job)
+		return
+	case errors.Is(err, ErrNoMetadataProvider):
+		w.fail(job, err.Error())
+		return
+	case err != nil:
+		if job.Attempts >= enrichmentMaxAttempts {
+			w.fail(job, err.Error())
+			return
+		}
+		w.retry(job, err.Error(), w.nextRun(job))
+		return
+	}
+
+	if err := w.store.ApplyEnrichment(job.TargetType, job.TargetID, enrichedFields(job.TargetType, metadata)); err != nil {
+		w.logger.Printf("ERROR: applying enrichment to %s %s %v", job.TargetType, job.TargetID, err)
+		w.fail(job, err.Error())
+		return
+	}
+
+	w.complete(job)
+}
+
+// lookup asks the providers about the target by ISBN, or by Big Book id when
+// it has none.
+func (w *EnrichmentWorker) lookup(ctx context.Context, target *store.EnrichmentTarget) (*BookMetadata, error) {
+	if target.Isbn != nil {
+		return w.metadata.LookupISBN(ctx, *target.Isbn)
+	}
+
+	if target.BigBookID == nil || w.bigBook == nil {
+		return nil, ErrMetadataNotFound
+	}
+
+	book, err := w.bigBook.GetBookByBigBookId(ctx, strconv.FormatInt(*target.BigBookID, 10))
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &BookMetadata{}
+	metadata.merge(book.Metadata(), ProviderBigBook)
+	w.metadata.Complete(ctx, metadata)
+
+	return metadata, nil
+}
+
+// enrichedFields returns the values known by the providers for the columns
+// of the target.
+func enrichedFields(targetType string, metadata *BookMetadata) []store.EnrichedField {
+	var fields []store.EnrichedField
+	add := func(column string, value any, source string) {
+		fields = append(fields, store.EnrichedField{Column: column, Value: value, Source: metadata.Sources[source]})
+	}
+
+	if metadata.CoverURL != "" {
+		add("cover_url", metadata.CoverURL, "cover_url")
+	}
+
+	if bigBookId, err := strconv.ParseInt(metadata.BigBookID, 10, 64); err == nil {
+		add("big_book_id", bigBookId, "big_book_id")
+	}
+
+	switch targetType {
+	case store.EnrichmentTargetBook:
+		if metadata.Description != "" {
+			add("description", metadata.Description, "description")
+		}
+		if metadata.PageCount > 0 {
+			add("page_count", metadata.PageCount, "page_count")
+		}
+	case store.EnrichmentTargetWish:
+		if len(metadata.Authors) > 0 {
+			add("author", strings.Join(metadata.Authors, ", "), "authors")
+		}
+		if isbn13 := metadata.CanonicalIsbn(); isbn13 != "" {
+			source := "isbn_13"
+			if metadata.Isbn13 == "" {
+				source = "isbn_10"
+			}
+			add("isbn", isbn13, source)
+		}
+	}
+
+	return fields
+}
+
+// nextRun backs off exponentially with the attempts of the job, with some
+// jitter so that failed jobs do not all run again at once.
+func (w *EnrichmentWorker) nextRun(job *store.EnrichmentJob) time.Time {
+	delay := min(enrichmentRetryDelay<<(max(job.Attempts, 1)-1), enrichmentMaxDelay)
+	return time.Now().Add(delay/2 + rand.N(delay/2+1))
+}
+
+func (w *EnrichmentWorker) complete(job *store.EnrichmentJob) {
+	if err := w.store.CompleteEnrichmentJob(job); err != nil {
+		w.logger.Printf("ERROR: completing enrichment job %v", err)
+	}
+}
+
+func (w *EnrichmentWorker) retry(job *store.EnrichmentJob, lastError string, runAt time.Time) {
+	if err := w.store.RetryEnrichmentJob(job, lastError, runAt); err != nil {
+		w.logger.Printf("ERROR: retrying enrichment job %v", err)
+	}
+}
+
+func (w *EnrichmentWorker) fail(job *store.EnrichmentJob, lastError string) {
+	w.logger.Printf("WARNING: enrichment of %s %s failed: %s", job.TargetType, job.TargetID, lastError)
+	if err := w.store.FailEnrichmentJob(job, lastError); err != nil {
+		w.logger.Printf("ERROR: failing enrichment job %v", err)
+	}
+}
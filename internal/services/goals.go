@@ -0,0 +1,58 @@
+package services
+
+import (
+	"time"
+
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+const (
+	PaceNotStarted = "not_started"
+	PaceAhead      = "ahead"
+	PaceBehind     = "behind"
+	PaceCompleted  = "completed"
+)
+
+// GoalPeriodBounds returns the start (inclusive) and end (exclusive) of a goal
+// period in the given location, so that a book finished late on December 31st
+// in Tokyo counts for the year it was finished there. index is the month
+// (1-12) or quarter (1-4) and is ignored for yearly goals.
+func GoalPeriodBounds(period string, year, index int, loc *time.Location) (time.Time, time.Time) {
+	switch period {
+	case store.GoalPeriodMonth:
+		start := time.Date(year, time.Month(index), 1, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 1, 0)
+	case store.GoalPeriodQuarter:
+		start := time.Date(year, time.Month(3*(index-1)+1), 1, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 3, 0)
+	default:
+		start := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+		return start, start.AddDate(1, 0, 0)
+	}
+}
+
+// GoalPace tells whether the progress made so far is ahead or behind a steady
+// pace towards the target, along with the progress that pace expects by now.
+func GoalPace(progress, target int, start, end, now time.Time) (string, float64) {
+	elapsed := 1.0
+	if now.Before(end) {
+		elapsed = float64(now.Sub(start)) / float64(end.Sub(start))
+	}
+
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	expected := float64(target) * elapsed
+
+	switch {
+	case progress >= target:
+		return PaceCompleted, expected
+	case now.Before(start):
+		return PaceNotStarted, expected
+	case float64(progress) >= expected:
+		return PaceAhead, expected
+	default:
+		return PaceBehind, expected
+	}
+}
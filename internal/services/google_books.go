@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/martialanouman/personal-library/internal/outbound"
+)
+
+// GoogleBooksProvider looks books up in Google Books. The API key is
+// optional but raises the quota.
+type GoogleBooksProvider struct {
+	client  *outbound.Client
+	baseURL string
+	apiKey  string
+	logger  *log.Logger
+}
+
+type googleVolume struct {
+	VolumeInfo struct {
+		Title               string   `json:"title"`
+		Subtitle            string   `json:"subtitle"`
+		Authors             []string `json:"authors"`
+		Publisher           string   `json:"publisher"`
+		PublishedDate       string   `json:"publishedDate"`
+		Description         string   `json:"description"`
+		PageCount           int      `json:"pageCount"`
+		AverageRating       float64  `json:"averageRating"`
+		IndustryIdentifiers []struct {
+			Type       string `json:"type"`
+			Identifier string `json:"identifier"`
+		} `json:"industryIdentifiers"`
+		ImageLinks struct {
+			Thumbnail string `json:"thumbnail"`
+		} `json:"imageLinks"`
+	} `json:"volumeInfo"`
+}
+
+type googleBooksResponse struct {
+	TotalItems int            `json:"totalItems"`
+	Items      []googleVolume `json:"items"`
+}
+
+func NewGoogleBooksProvider(client *outbound.Client, logger *log.Logger) *GoogleBooksProvider {
+	baseURL := os.Getenv("GOOGLE_BOOKS_API_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://www.googleapis.com/books/v1"
+	}
+
+	return &GoogleBooksProvider{
+		client:  client,
+		baseURL: baseURL,
+		apiKey:  os.Getenv("GOOGLE_BOOKS_API_KEY"),
+		logger:  logger,
+	}
+}
+
+func (g *GoogleBooksProvider) Name() string {
+	return ProviderGoogleBooks
+}
+
+func (g *GoogleBooksProvider) LookupISBN(ctx context.Context, isbn string) (*BookMetadata, error) {
+	volumes, err := g.volumes(ctx, "isbn:"+isbn, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(volumes) == 0 {
+		return nil, ErrMetadataNotFound
+	}
+
+	return volumes[0].metadata(), nil
+}
+
+func (g *GoogleBooksProvider) Search(ctx context.Context, query CatalogQuery) ([]BookMetadata, error) {
+	terms := []string{}
+	if query.Query != "" {
+		terms = append(terms, query.Query)
+	}
+	if query.Author != "" {
+		terms = append(terms, "inauthor:"+query.Author)
+	}
+	if query.Isbn != "" {
+		terms = append(terms, "isbn:"+query.Isbn)
+	}
+
+	volumes, err := g.volumes(ctx, strings.Join(terms, " "), query.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]BookMetadata, 0, len(volumes))
+	for _, volume := range volumes {
+		hits = append(hits, *volume.metadata())
+	}
+
+	return hits, nil
+}
+
+func (g *GoogleBooksProvider) volumes(ctx context.Context, q string, limit int) ([]googleVolume, error) {
+	u, err := url.JoinPath(g.baseURL, "volumes")
+	if err != nil {
+		return nil, fmt.Errorf("failed to join URL path: %w", err)
+	}
+
+	// Google Books answers with at most 40 volumes.
+	params := url.Values{"q": {q}, "maxResults": {strconv.Itoa(min(max(limit, 1), 40))}}
+	if g.apiKey != "" {
+		params.Set("key", g.apiKey)
+	}
+	u += "?" + params.Encode()
+
+	var response googleBooksResponse
+	if err := getJSON(ctx, g.client, u, nil, &response); err != nil {
+		return nil, err
+	}
+
+	return response.Items, nil
+}
+
+func (v *googleVolume) metadata() *BookMetadata {
+	volume := v.VolumeInfo
+	metadata := &BookMetadata{
+		Title:         volume.Title,
+		Authors:       volume.Authors,
+		Publisher:     volume.Publisher,
+		PublishedDate: volume.PublishedDate,
+		Description:   volume.Description,
+		PageCount:     volume.PageCount,
+		Rating:        volume.AverageRating,
+		// Google serves covers over plain HTTP by default.
+		CoverURL: strings.Replace(volume.ImageLinks.Thumbnail, "http://", "https://", 1),
+	}
+
+	if volume.Subtitle != "" {
+		metadata.Title = volume.Title + ": " + volume.Subtitle
+	}
+
+	for _, identifier := range volume.IndustryIdentifiers {
+		switch identifier.Type {
+		case "ISBN_10":
+			metadata.Isbn10 = identifier.Identifier
+		case "ISBN_13":
+			metadata.Isbn13 = identifier.Identifier
+		}
+	}
+
+	return metadata
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestGoogleBooksLookupISBN(t *testing.T) {
+	var query string
+	server := newFixtureServer(t, map[string]http.HandlerFunc{
+		"/volumes": func(w http.ResponseWriter, r *http.Request) {
+			query = r.URL.Query().Get("q")
+			fixture(t, "google_volumes.json")(w, r)
+		},
+	})
+	provider := newTestGoogleBooksProvider(server)
+
+	metadata, err := provider.LookupISBN(context.Background(), "9780441172719")
+	if err != nil {
+		t.Fatalf("LookupISBN() error = %v", err)
+	}
+
+	if query != "isbn:9780441172719" {
+		t.Errorf("q = %q, want %q", query, "isbn:9780441172719")
+	}
+
+	if metadata.Title != "Dune" || metadata.Publisher != "Penguin" || metadata.PageCount != 528 || metadata.Rating != 4.5 {
+		t.Errorf("LookupISBN() = %+v", metadata)
+	}
+
+	if metadata.Isbn13 != "9780441172719" || metadata.Isbn10 != "0441172717" {
+		t.Errorf("ISBNs = %q, %q", metadata.Isbn13, metadata.Isbn10)
+	}
+
+	if metadata.CoverURL != "https://books.google.com/books/content?id=B1hSG45JCX4C&zoom=1" {
+		t.Errorf("CoverURL = %q, want an https URL", metadata.CoverURL)
+	}
+}
+
+func TestGoogleBooksLookupISBNNotFound(t *testing.T) {
+	server := newFixtureServer(t, map[string]http.HandlerFunc{
+		"/volumes": fixture(t, "google_volumes_empty.json"),
+	})
+	provider := newTestGoogleBooksProvider(server)
+
+	_, err := provider.LookupISBN(context.Background(), "9780000000002")
+	if !errors.Is(err, ErrMetadataNotFound) {
+		t.Fatalf("LookupISBN() error = %v, want %v", err, ErrMetadataNotFound)
+	}
+}
+
+func TestGoogleBooksLookupISBNRateLimited(t *testing.T) {
+	server := newFixtureServer(t, map[string]http.HandlerFunc{
+		"/volumes": status(http.StatusTooManyRequests),
+	})
+	provider := newTestGoogleBooksProvider(server)
+
+	_, err := provider.LookupISBN(context.Background(), "9780441172719")
+	if !errors.Is(err, ErrMetadataRateLimited) {
+		t.Fatalf("LookupISBN() error = %v, want %v", err, ErrMetadataRateLimited)
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// kindleSeparator ends every entry of a Kindle "My Clippings.txt" file.
+const kindleSeparator = "=========="
+
+var (
+	kindleTitleLine = regexp.MustCompile(`^(.*?)\s*\(([^()]*)\)\s*$`)
+	kindlePage      = regexp.MustCompile(`(?i)\b(?:page|seite|página|pagina)\s+(\d+)`)
+	kindleLocation  = regexp.MustCompile(`(?i)\b(?:location|emplacement|position|posición|posizione|posição|locatie)\s+(\d+)(?:-(\d+))?`)
+)
+
+// kindleKinds are the words telling the kind of an entry in its metadata
+// line, in the languages Kindles are commonly set to: English, French,
+// German, Spanish, Italian, Portuguese and Dutch. Highlights are looked for
+// first since their metadata line may contain the other words.
+var kindleKinds = []struct {
+	kind  string
+	words []string
+}{
+	{"highlight", []string{"highlight", "surlignement", "markierung", "subrayado", "evidenziazione", "destaque", "markering"}},
+	{"bookmark", []string{"bookmark", "signet", "lesezeichen", "marcador", "segnalibro", "bladwijzer"}},
+	{"note", []string{"note", "notiz", "nota", "notitie"}},
+}
+
+// KindleHighlight is a passage highlighted on a Kindle, along with the note
+// typed on it if any.
+type KindleHighlight struct {
+	Title    string
+	Author   string
+	Text     string
+	Page     *int
+	Location *string
+	Note     *string
+
+	locationStart int
+	locationEnd   int
+}
+
+type kindleEntry struct {
+	title         string
+	author        string
+	kind          string
+	text          string
+	page          *int
+	locationStart int
+	locationEnd   int
+}
+
+// ParseKindleClippings reads a "My Clippings.txt" file and returns its
+// highlights in file order. Notes are attached to the highlight of the same
+// book whose location range holds them, and bookmarks are left out. It also
+// returns the number of entries skipped because they could not be read, such
+// as the ones of a Kindle set to an unsupported language.
+func ParseKindleClippings(r io.Reader) ([]KindleHighlight, int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var (
+		entries []kindleEntry
+		lines   []string
+		skipped int
+	)
+
+	add := func(lines []string) {
+		entry, ok := parseKindleEntry(lines)
+		switch {
+		case !ok && hasText(lines):
+			skipped++
+		case ok && entry.kind == "":
+			skipped++
+		case ok:
+			entries = append(entries, entry)
+		}
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(entries) == 0 && len(lines) == 0 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		if strings.TrimSpace(line) == kindleSeparator {
+			add(lines)
+			lines = nil
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	add(lines)
+
+	highlights := []KindleHighlight{}
+	for _, entry := range entries {
+		if entry.kind != "highlight" {
+			continue
+		}
+
+		highlight := KindleHighlight{
+			Title:         entry.title,
+			Author:        entry.author,
+			Text:          entry.text,
+			Page:          entry.page,
+			locationStart: entry.locationStart,
+			locationEnd:   entry.locationEnd,
+		}
+
+		if entry.locationStart > 0 {
+			location := strconv.Itoa(entry.locationStart)
+			if entry.locationEnd > entry.locationStart {
+				location += "-" + strconv.Itoa(entry.locationEnd)
+			}
+			highlight.Location = &location
+		}
+
+		highlights = append(highlights, highlight)
+	}
+
+	for _, entry := range entries {
+		if entry.kind != "note" || entry.locationStart == 0 {
+			continue
+		}
+
+		for i := range highlights {
+			highlight := &highlights[i]
+			if highlight.Title != entry.title || highlight.Note != nil {
+				continue
+			}
+
+			if entry.locationStart >= highlight.locationStart && entry.locationStart <= max(highlight.locationStart, highlight.locationEnd) {
+				note := entry.text
+				highlight.Note = &note
+				break
+			}
+		}
+	}
+
+	return highlights, skipped, nil
+}
+
+func hasText(lines []string) bool {
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseKindleEntry parses the lines of one entry: the title and author, the
+// metadata line, a blank line and the text. The kind of entries whose
+// metadata line is not understood is left empty.
+func parseKindleEntry(lines []string) (kindleEntry, bool) {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+
+	if len(lines) < 2 {
+		return kindleEntry{}, false
+	}
+
+	var entry kindleEntry
+
+	heading := strings.TrimSpace(lines[0])
+	if match := kindleTitleLine.FindStringSubmatch(heading); match != nil {
+		entry.title, entry.author = strings.TrimSpace(match[1]), strings.TrimSpace(match[2])
+	} else {
+		entry.title = heading
+	}
+
+	metadata := lines[1]
+	lowered := strings.ToLower(metadata)
+	for _, kind := range kindleKinds {
+		if slices.ContainsFunc(kind.words, func(word string) bool { return strings.Contains(lowered, word) }) {
+			entry.kind = kind.kind
+			break
+		}
+	}
+
+	// Bookmarks have no text.
+	if entry.kind == "bookmark" {
+		return entry, true
+	}
+
+	if match := kindlePage.FindStringSubmatch(metadata); match != nil {
+		page, _ := strconv.Atoi(match[1])
+		entry.page = &page
+	}
+
+	if match := kindleLocation.FindStringSubmatch(metadata); match != nil {
+		entry.locationStart, _ = strconv.Atoi(match[1])
+		entry.locationEnd, _ = strconv.Atoi(match[2])
+	}
+
+	entry.text = strings.TrimSpace(strings.Join(lines[2:], "\n"))
+	if entry.text == "" || entry.title == "" {
+		return kindleEntry{}, false
+	}
+
+	return entry, true
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseKindleClippings(t *testing.T) {
+	file, err := os.Open(filepath.Join("testdata", "kindle_clippings.txt"))
+	if err != nil {
+		t.Fatalf("opening clippings: %v", err)
+	}
+	defer file.Close()
+
+	highlights, skipped, err := ParseKindleClippings(file)
+	if err != nil {
+		t.Fatalf("ParseKindleClippings() error = %v", err)
+	}
+
+	// The Japanese entry is not understood.
+	if skipped != 1 {
+		t.Errorf("skipped = %d, want 1", skipped)
+	}
+
+	want := []struct {
+		title    string
+		author   string
+		text     string
+		page     int
+		location string
+		note     string
+	}{
+		{"Dune", "Frank Herbert", "I must not fear. Fear is the mind-killer.", 12, "180-182", "The litany against fear."},
+		{"L'Étranger", "Albert Camus", "Aujourd'hui, maman est morte.", 9, "120-121", ""},
+		{"Der Process", "Franz Kafka", "Jemand mußte Josef K. verleumdet haben.", 0, "55-56", ""},
+		{"Cien años de soledad", "Gabriel García Márquez", "Muchos años después, frente al pelotón de fusilamiento...", 7, "98-99", ""},
+	}
+
+	if len(highlights) != len(want) {
+		t.Fatalf("ParseKindleClippings() returned %d highlights, want %d", len(highlights), len(want))
+	}
+
+	for i, w := range want {
+		got := highlights[i]
+		if got.Title != w.title || got.Author != w.author || got.Text != w.text {
+			t.Errorf("highlight %d = %q by %q: %q, want %q by %q: %q", i, got.Title, got.Author, got.Text, w.title, w.author, w.text)
+		}
+
+		if page := deref(got.Page); page != w.page {
+			t.Errorf("highlight %d page = %d, want %d", i, page, w.page)
+		}
+
+		if location := deref(got.Location); location != w.location {
+			t.Errorf("highlight %d location = %q, want %q", i, location, w.location)
+		}
+
+		if note := deref(got.Note); note != w.note {
+			t.Errorf("highlight %d note = %q, want %q", i, note, w.note)
+		}
+	}
+}
+
+func TestParseKindleClippingsEmpty(t *testing.T) {
+	highlights, skipped, err := ParseKindleClippings(strings.NewReader(""))
+	if err != nil || len(highlights) != 0 || skipped != 0 {
+		t.Errorf("ParseKindleClippings(\"\") = %v, %d, %v", highlights, skipped, err)
+	}
+}
+
+func deref[T any](value *T) T {
+	var zero T
+	if value == nil {
+		return zero
+	}
+
+	return *value
+}
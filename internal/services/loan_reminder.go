@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+const (
+	loanReminderInterval = time.Hour
+	loanRemindEvery      = 24 * time.Hour
+)
+
+// LoanReminder periodically notifies users of their overdue loans, at most
+// once a day per loan.
+type LoanReminder struct {
+	store    store.LoanStore
+	notifier Notifier
+	logger   *log.Logger
+}
+
+func NewLoanReminder(store store.LoanStore, notifier Notifier, logger *log.Logger) *LoanReminder {
+	return &LoanReminder{store: store, notifier: notifier, logger: logger}
+}
+
+// Run checks the overdue loans right away, then every hour, until ctx is done.
+func (r *LoanReminder) Run(ctx context.Context) {
+	ticker := time.NewTicker(loanReminderInterval)
+	defer ticker.Stop()
+
+	for {
+		r.remind(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *LoanReminder) remind(ctx context.Context) {
+	loans, err := r.store.GetLoansToRemind(loanRemindEvery)
+	if err != nil {
+		r.logger.Printf("ERROR: getting loans to remind %v", err)
+		return
+	}
+
+	for _, loan := range loans {
+		if err := r.notifier.Notify(ctx, loanNotification(loan)); err != nil {
+			r.logger.Printf("ERROR: notifying overdue loan %v", err)
+			continue
+		}
+
+		if err := r.store.MarkLoanReminded(loan.ID); err != nil {
+			r.logger.Printf("ERROR: marking loan as reminded %v", err)
+		}
+	}
+}
+
+func loanNotification(loan store.Loan) Notification {
+	due := loan.DueAt.Format(time.DateOnly)
+
+	if loan.Direction == store.LoanDirectionBorrowed {
+		return Notification{
+			UserID:  loan.UserID,
+			Subject: "Borrowed book overdue",
+			Message: fmt.Sprintf("%q borrowed from %s was due back on %s.", loan.BookTitle, loan.CounterpartyName, due),
+		}
+	}
+
+	return Notification{
+		UserID:  loan.UserID,
+		Subject: "Lent book overdue",
+		Message: fmt.Sprintf("%q lent to %s was due back on %s.", loan.BookTitle, loan.CounterpartyName, due),
+	}
+}
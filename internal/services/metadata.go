@@ -0,0 +1,449 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/martialanouman/personal-library/internal/isbn"
+	"github.com/martialanouman/personal-library/internal/outbound"
+)
+
+const (
+	ProviderBigBook     = "bigbook"
+	ProviderOpenLibrary = "openlibrary"
+	ProviderGoogleBooks = "google"
+)
+
+// defaultProviders is the priority chain used when METADATA_PROVIDERS is not
+// set. Providers missing their configuration are left out.
+var defaultProviders = []string{ProviderBigBook, ProviderOpenLibrary, ProviderGoogleBooks}
+
+var (
+	ErrMetadataNotFound    = errors.New("book metadata not found")
+	ErrMetadataRateLimited = errors.New("book metadata provider rate limit exceeded")
+	ErrNoMetadataProvider  = errors.New("no book metadata provider configured")
+	// ErrMetadataUnavailable is returned while a provider is not called
+	// anymore because it kept failing.
+	ErrMetadataUnavailable = errors.New("book metadata provider unavailable")
+	ErrMetadataTimeout     = errors.New("book metadata provider timed out")
+	// ErrMetadataUpstream is returned when a provider answers with an error
+	// or an answer that cannot be read.
+	ErrMetadataUpstream = errors.New("book metadata provider failed")
+)
+
+// providerRates are the requests per second allowed by default to each
+// provider, overridden by the <PROVIDER>_RATE_LIMIT variables.
+var providerRates = map[string]struct {
+	variable string
+	rate     float64
+}{
+	ProviderBigBook:     {"BIG_BOOK_API_RATE_LIMIT", 1},
+	ProviderOpenLibrary: {"OPEN_LIBRARY_RATE_LIMIT", 3},
+	ProviderGoogleBooks: {"GOOGLE_BOOKS_RATE_LIMIT", 5},
+}
+
+type MetadataSeries struct {
+	Name         string  `json:"name"`
+	Position     float64 `json:"position,omitempty"`
+	TotalVolumes int     `json:"total_volumes,omitempty"`
+}
+
+// BookMetadata is what a provider knows about a book. Zero values are unknown
+// fields. Rating is on a 0 to 5 scale and Sources tells which provider each
+// known field comes from.
+type BookMetadata struct {
+	Title         string            `json:"title"`
+	Authors       []string          `json:"authors"`
+	Isbn10        string            `json:"isbn_10,omitempty"`
+	Isbn13        string            `json:"isbn_13,omitempty"`
+	Description   string            `json:"description,omitempty"`
+	CoverURL      string            `json:"cover_url,omitempty"`
+	PageCount     int               `json:"page_count,omitempty"`
+	Publisher     string            `json:"publisher,omitempty"`
+	PublishedDate string            `json:"published_date,omitempty"`
+	Rating        float64           `json:"rating,omitempty"`
+	Series        *MetadataSeries   `json:"series,omitempty"`
+	BigBookID     string            `json:"big_book_id,omitempty"`
+	Sources       map[string]string `json:"sources"`
+}
+
+// CatalogQuery searches external catalogs. At least one of Query, Author and
+// Isbn is set.
+type CatalogQuery struct {
+	Query  string
+	Author string
+	Isbn   string
+	Limit  int
+}
+
+// MetadataProvider looks up book metadata in an external catalog. LookupISBN
+// returns ErrMetadataNotFound when the catalog does not know the ISBN and
+// both methods return ErrMetadataRateLimited when the catalog refuses to
+// answer for now.
+type MetadataProvider interface {
+	Name() string
+	LookupISBN(ctx context.Context, isbn string) (*BookMetadata, error)
+	Search(ctx context.Context, query CatalogQuery) ([]BookMetadata, error)
+}
+
+// merge fills the unknown fields of m with the ones of other, recording
+// provider as their source unless other already knows where they come from.
+func (m *BookMetadata) merge(other *BookMetadata, provider string) {
+	if m.Sources == nil {
+		m.Sources = make(map[string]string)
+	}
+
+	set := func(field string, unknown bool, known bool, apply func()) {
+		if !unknown || !known {
+			return
+		}
+
+		apply()
+		if source, ok := other.Sources[field]; ok {
+			m.Sources[field] = source
+		} else {
+			m.Sources[field] = provider
+		}
+	}
+
+	set("title", m.Title == "", other.Title != "", func() { m.Title = other.Title })
+	set("authors", len(m.Authors) == 0, len(other.Authors) > 0, func() { m.Authors = other.Authors })
+	set("isbn_10", m.Isbn10 == "", other.Isbn10 != "", func() { m.Isbn10 = other.Isbn10 })
+	set("isbn_13", m.Isbn13 == "", other.Isbn13 != "", func() { m.Isbn13 = other.Isbn13 })
+	set("description", m.Description == "", other.Description != "", func() { m.Description = other.Description })
+	set("cover_url", m.CoverURL == "", other.CoverURL != "", func() { m.CoverURL = other.CoverURL })
+	set("page_count", m.PageCount == 0, other.PageCount > 0, func() { m.PageCount = other.PageCount })
+	set("publisher", m.Publisher == "", other.Publisher != "", func() { m.Publisher = other.Publisher })
+	set("published_date", m.PublishedDate == "", other.PublishedDate != "", func() { m.PublishedDate = other.PublishedDate })
+	set("rating", m.Rating == 0, other.Rating > 0, func() { m.Rating = other.Rating })
+	set("series", m.Series == nil, other.Series != nil, func() { m.Series = other.Series })
+	set("big_book_id", m.BigBookID == "", other.BigBookID != "", func() { m.BigBookID = other.BigBookID })
+}
+
+// complete tells whether every field worth asking another provider for is
+// known.
+func (m *BookMetadata) complete() bool {
+	return m.Title != "" && len(m.Authors) > 0 && m.Isbn13 != "" && m.Description != "" && m.CoverURL != "" && m.PageCount > 0
+}
+
+// MetadataChain queries providers in priority order. A provider that does
+// not know the book or is rate limited is skipped, and the following ones
+// are asked for the fields the previous ones did not know.
+type MetadataChain struct {
+	providers []MetadataProvider
+	cache     *MetadataCache
+	logger    *log.Logger
+}
+
+func NewMetadataChain(providers []MetadataProvider, cache *MetadataCache, logger *log.Logger) *MetadataChain {
+	return &MetadataChain{providers: providers, cache: cache, logger: logger}
+}
+
+func (c *MetadataChain) Name() string {
+	return "chain"
+}
+
+// Providers returns the names of the providers in priority order.
+func (c *MetadataChain) Providers() []string {
+	names := make([]string, len(c.providers))
+	for i, provider := range c.providers {
+		names[i] = provider.Name()
+	}
+
+	return names
+}
+
+func (c *MetadataChain) LookupISBN(ctx context.Context, isbn string) (*BookMetadata, error) {
+	if len(c.providers) == 0 {
+		return nil, ErrNoMetadataProvider
+	}
+
+	var (
+		result  *BookMetadata
+		lastErr error
+	)
+
+	for _, provider := range c.providers {
+		metadata, err := c.lookupISBN(ctx, provider, isbn)
+		if errors.Is(err, ErrMetadataNotFound) {
+			continue
+		}
+
+		if err != nil {
+			c.logger.Printf("WARNING: looking up isbn %s with %s failed, falling back: %v", isbn, provider.Name(), err)
+			lastErr = fmt.Errorf("%s: %w", provider.Name(), err)
+			continue
+		}
+
+		if result == nil {
+			result = &BookMetadata{}
+		}
+		result.merge(metadata, provider.Name())
+
+		if result.complete() {
+			break
+		}
+	}
+
+	if result != nil {
+		return result, nil
+	}
+
+	// A provider that failed may know the book.
+	if lastErr != nil {
+		return nil, lastErr
+	}
+
+	return nil, ErrMetadataNotFound
+}
+
+// lookupISBN asks the provider for the ISBN through the cache.
+func (c *MetadataChain) lookupISBN(ctx context.Context, provider MetadataProvider, isbn string) (*BookMetadata, error) {
+	var metadata BookMetadata
+	err := c.cache.Fetch(ctx, provider.Name(), "isbn:"+isbn, &metadata, func(ctx context.Context) (any, error) {
+		return provider.LookupISBN(ctx, isbn)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &metadata, nil
+}
+
+// Search queries the providers in priority order until enough books are
+// found. The same book found by several providers, as told by its ISBN, is
+// returned once with their fields merged.
+func (c *MetadataChain) Search(ctx context.Context, query CatalogQuery) ([]BookMetadata, error) {
+	if len(c.providers) == 0 {
+		return nil, ErrNoMetadataProvider
+	}
+
+	var (
+		results []BookMetadata
+		lastErr error
+	)
+	byIsbn := make(map[string]int)
+
+	for _, provider := range c.providers {
+		hits, err := provider.Search(ctx, query)
+		if errors.Is(err, ErrMetadataNotFound) {
+			continue
+		}
+
+		if err != nil {
+			c.logger.Printf("WARNING: searching with %s failed, falling back: %v", provider.Name(), err)
+			lastErr = fmt.Errorf("%s: %w", provider.Name(), err)
+			continue
+		}
+
+		for i := range hits {
+			key := hits[i].CanonicalIsbn()
+			if index, ok := byIsbn[key]; ok && key != "" {
+				results[index].merge(&hits[i], provider.Name())
+				continue
+			}
+
+			var result BookMetadata
+			result.merge(&hits[i], provider.Name())
+			results = append(results, result)
+			if key != "" {
+				byIsbn[key] = len(results) - 1
+			}
+		}
+
+		if len(results) >= query.Limit {
+			break
+		}
+	}
+
+	if len(results) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+
+	if len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+
+	return results, nil
+}
+
+// CanonicalIsbn returns the ISBN-13 of the book, converting its ISBN-10 if
+// needed, or an empty string when it has no valid ISBN.
+func (m *BookMetadata) CanonicalIsbn() string {
+	for _, candidate := range []string{m.Isbn13, m.Isbn10} {
+		if normalized, err := isbn.Parse(candidate); err == nil {
+			return normalized
+		}
+	}
+
+	return ""
+}
+
+// Complete asks the providers for the fields of metadata that are still
+// unknown, looking the book up by its ISBN. Failures are only logged since
+// metadata stays usable as is.
+func (c *MetadataChain) Complete(ctx context.Context, metadata *BookMetadata) {
+	isbn := metadata.Isbn13
+	if isbn == "" {
+		isbn = metadata.Isbn10
+	}
+
+	if isbn == "" || metadata.complete() || len(c.providers) == 0 {
+		return
+	}
+
+	other, err := c.LookupISBN(ctx, isbn)
+	if err != nil {
+		if !errors.Is(err, ErrMetadataNotFound) {
+			c.logger.Printf("ERROR: completing metadata of isbn %s %v", isbn, err)
+		}
+		return
+	}
+
+	metadata.merge(other, c.Name())
+}
+
+// NewMetadataProviders builds the providers listed in the comma separated
+// METADATA_PROVIDERS variable, in priority order. An empty variable disables
+// lookups altogether, and providers missing their configuration are skipped
+// with a warning so that the application starts anyway.
+func NewMetadataProviders(cache *MetadataCache, logger *log.Logger) []MetadataProvider {
+	names := defaultProviders
+	if value, ok := os.LookupEnv("METADATA_PROVIDERS"); ok {
+		names = nil
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	httpClient := newHTTPClient()
+	providers := []MetadataProvider{}
+	for _, name := range names {
+		client := outbound.New(name, httpClient, providerPolicy(name, logger))
+
+		switch name {
+		case ProviderBigBook:
+			provider, err := NewBigBookProvider(client, cache, logger)
+			if err != nil {
+				logger.Printf("WARNING: %s metadata provider disabled: %v", name, err)
+				continue
+			}
+			providers = append(providers, provider)
+		case ProviderOpenLibrary:
+			providers = append(providers, NewOpenLibraryProvider(client, logger))
+		case ProviderGoogleBooks:
+			providers = append(providers, NewGoogleBooksProvider(client, logger))
+		default:
+			logger.Printf("WARNING: unknown metadata provider %q ignored", name)
+		}
+	}
+
+	return providers
+}
+
+// newHTTPClient returns the client shared by the providers. It has no
+// timeout of its own: each attempt is bounded by the outbound policy.
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     90 * time.Second,
+			DisableCompression:  false,
+			DisableKeepAlives:   false,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+
+			return nil
+		},
+	}
+}
+
+// providerPolicy returns the default outbound policy limited to the rate of
+// the provider.
+func providerPolicy(name string, logger *log.Logger) outbound.Policy {
+	policy := outbound.DefaultPolicy()
+
+	limit, ok := providerRates[name]
+	if !ok {
+		return policy
+	}
+
+	policy.RatePerSecond = limit.rate
+	if value := os.Getenv(limit.variable); value != "" {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate <= 0 {
+			logger.Printf("WARNING: invalid %s %q, using %g", limit.variable, value, limit.rate)
+		} else {
+			policy.RatePerSecond = rate
+		}
+	}
+	policy.Burst = max(1, int(policy.RatePerSecond))
+
+	return policy
+}
+
+// getJSON fetches the URL and decodes its JSON body into out. Failures are
+// reported as the ErrMetadata errors matching them.
+func getJSON(ctx context.Context, client *outbound.Client, u string, headers map[string]string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	r, err := client.Do(req)
+	if err != nil {
+		var netErr net.Error
+		switch {
+		case errors.Is(err, context.Canceled):
+			return err
+		case errors.Is(err, outbound.ErrCircuitOpen):
+			return fmt.Errorf("%w: %v", ErrMetadataUnavailable, err)
+		case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+			return fmt.Errorf("%w: %v", ErrMetadataTimeout, err)
+		default:
+			return fmt.Errorf("%w: %v", ErrMetadataUpstream, err)
+		}
+	}
+
+	defer r.Body.Close()
+
+	switch r.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return ErrMetadataNotFound
+	case http.StatusTooManyRequests, http.StatusPaymentRequired:
+		return ErrMetadataRateLimited
+	case http.StatusGatewayTimeout:
+		return ErrMetadataTimeout
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w: unauthorized: invalid API credentials", ErrMetadataUpstream)
+	default:
+		return fmt.Errorf("%w: request failed with status code: %d", ErrMetadataUpstream, r.StatusCode)
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(out); err != nil {
+		return fmt.Errorf("%w: failed to decode response body: %v", ErrMetadataUpstream, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/martialanouman/personal-library/internal/outbound"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+// memoryMetadataCacheStore keeps the cache entries of the tests in memory.
+type memoryMetadataCacheStore struct {
+	mu      sync.Mutex
+	entries map[string]*store.MetadataCacheEntry
+}
+
+func (s *memoryMetadataCacheStore) GetMetadataCacheEntry(provider, externalId string) (*store.MetadataCacheEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.entries[provider+":"+externalId], nil
+}
+
+func (s *memoryMetadataCacheStore) SaveMetadataCacheEntry(entry *store.MetadataCacheEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.entries == nil {
+		s.entries = make(map[string]*store.MetadataCacheEntry)
+	}
+	s.entries[entry.Provider+":"+entry.ExternalID] = entry
+
+	return nil
+}
+
+func (s *memoryMetadataCacheStore) DeleteExpiredMetadataCacheEntries(expiredBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for key, entry := range s.entries {
+		if entry.ExpiresAt.Before(expiredBefore) {
+			delete(s.entries, key)
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func testLogger() *log.Logger {
+	return log.New(io.Discard, "", 0)
+}
+
+func newTestMetadataCache() *MetadataCache {
+	return NewMetadataCache(&memoryMetadataCacheStore{}, testLogger())
+}
+
+// fixture answers with the recorded JSON answer in testdata.
+func fixture(t *testing.T, name string) http.HandlerFunc {
+	t.Helper()
+
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("reading fixture %s: %v", name, err)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}
+}
+
+func status(code int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	}
+}
+
+// fixtureServer serves the handlers by path and counts the requests made to
+// each path.
+type fixtureServer struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func newFixtureServer(t *testing.T, routes map[string]http.HandlerFunc) *fixtureServer {
+	t.Helper()
+
+	s := &fixtureServer{calls: make(map[string]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.calls[r.URL.Path]++
+		s.mu.Unlock()
+
+		handler, ok := routes[r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected request to %s", r.URL)
+			http.NotFound(w, r)
+			return
+		}
+
+		handler(w, r)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *fixtureServer) Calls(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[path]
+}
+
+// client calls the server without retries, rate limit nor circuit breaker.
+func (s *fixtureServer) client(name string) *outbound.Client {
+	return outbound.New(name, s.Server.Client(), outbound.Policy{})
+}
+
+func newTestBigBookProvider(s *fixtureServer, cache *MetadataCache) *BigBookProvider {
+	return &BigBookProvider{client: s.client(ProviderBigBook), baseURL: s.URL, token: "token", cache: cache, logger: testLogger()}
+}
+
+func newTestOpenLibraryProvider(s *fixtureServer) *OpenLibraryProvider {
+	return &OpenLibraryProvider{client: s.client(ProviderOpenLibrary), baseURL: s.URL, logger: testLogger()}
+}
+
+func newTestGoogleBooksProvider(s *fixtureServer) *GoogleBooksProvider {
+	return &GoogleBooksProvider{client: s.client(ProviderGoogleBooks), baseURL: s.URL, logger: testLogger()}
+}
+
+func TestMetadataChainFallsBackWhenRateLimited(t *testing.T) {
+	bigBook := newFixtureServer(t, map[string]http.HandlerFunc{
+		"/search-books": status(http.StatusTooManyRequests),
+	})
+	openLibrary := newFixtureServer(t, map[string]http.HandlerFunc{
+		"/api/books": fixture(t, "openlibrary_book.json"),
+	})
+
+	cache := newTestMetadataCache()
+	chain := NewMetadataChain([]MetadataProvider{
+		newTestBigBookProvider(bigBook, cache),
+		newTestOpenLibraryProvider(openLibrary),
+	}, cache, testLogger())
+
+	metadata, err := chain.LookupISBN(context.Background(), "9780441172719")
+	if err != nil {
+		t.Fatalf("LookupISBN() error = %v", err)
+	}
+
+	if bigBook.Calls("/search-books") != 1 {
+		t.Errorf("big book was called %d times, want 1", bigBook.Calls("/search-books"))
+	}
+
+	if metadata.Title != "Dune: Deluxe Edition" {
+		t.Errorf("Title = %q, want %q", metadata.Title, "Dune: Deluxe Edition")
+	}
+
+	for field, source := range metadata.Sources {
+		if source != ProviderOpenLibrary {
+			t.Errorf("Sources[%s] = %q, want %q", field, source, ProviderOpenLibrary)
+		}
+	}
+}
+
+func TestMetadataChainRateLimitedEverywhere(t *testing.T) {
+	server := newFixtureServer(t, map[string]http.HandlerFunc{
+		"/api/books": status(http.StatusTooManyRequests),
+		"/volumes":   status(http.StatusTooManyRequests),
+	})
+
+	chain := NewMetadataChain([]MetadataProvider{
+		newTestOpenLibraryProvider(server),
+		newTestGoogleBooksProvider(server),
+	}, newTestMetadataCache(), testLogger())
+
+	_, err := chain.LookupISBN(context.Background(), "9780441172719")
+	if !errors.Is(err, ErrMetadataRateLimited) {
+		t.Fatalf("LookupISBN() error = %v, want %v", err, ErrMetadataRateLimited)
+	}
+}
+
+func TestMetadataChainMergeOrder(t *testing.T) {
+	bigBook := newFixtureServer(t, map[string]http.HandlerFunc{
+		"/search-books": fixture(t, "bigbook_search.json"),
+		"/1834217":      fixture(t, "bigbook_book.json"),
+	})
+	openLibrary := newFixtureServer(t, map[string]http.HandlerFunc{
+		"/api/books": fixture(t, "openlibrary_book.json"),
+	})
+	google := newFixtureServer(t, map[string]http.HandlerFunc{
+		"/volumes": fixture(t, "google_volumes.json"),
+	})
+
+	cache := newTestMetadataCache()
+	chain := NewMetadataChain([]MetadataProvider{
+		newTestBigBookProvider(bigBook, cache),
+		newTestOpenLibraryProvider(openLibrary),
+		newTestGoogleBooksProvider(google),
+	}, cache, testLogger())
+
+	metadata, err := chain.LookupISBN(context.Background(), "9780441172719")
+	if err != nil {
+		t.Fatalf("LookupISBN() error = %v", err)
+	}
+
+	// Big Book knows everything but the page count, which Open Library
+	// completes, so Google Books is not asked.
+	if google.Calls("/volumes") != 0 {
+		t.Errorf("google books was called %d times, want 0", google.Calls("/volumes"))
+	}
+
+	tests := []struct {
+		field  string
+		got    any
+		want   any
+		source string
+	}{
+		{"title", metadata.Title, "Dune", ProviderBigBook},
+		{"description", metadata.Description, "Set on the desert planet Arrakis, Dune is the story of the boy Paul Atreides.", ProviderBigBook},
+		{"cover_url", metadata.CoverURL, "https://covers.bigbookapi.com/1834217.jpg", ProviderBigBook},
+		{"page_count", metadata.PageCount, 604, ProviderOpenLibrary},
+		{"publisher", metadata.Publisher, "Ace", ProviderOpenLibrary},
+		{"published_date", metadata.PublishedDate, "2005", ProviderOpenLibrary},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.field, tt.got, tt.want)
+		}
+
+		if metadata.Sources[tt.field] != tt.source {
+			t.Errorf("Sources[%s] = %q, want %q", tt.field, metadata.Sources[tt.field], tt.source)
+		}
+	}
+}
+
+func TestMetadataChainSearchMergesSameIsbn(t *testing.T) {
+	openLibrary := newFixtureServer(t, map[string]http.HandlerFunc{
+		"/search.json": fixture(t, "openlibrary_search.json"),
+	})
+	google := newFixtureServer(t, map[string]http.HandlerFunc{
+		"/volumes": fixture(t, "google_volumes.json"),
+	})
+
+	chain := NewMetadataChain([]MetadataProvider{
+		newTestOpenLibraryProvider(openLibrary),
+		newTestGoogleBooksProvider(google),
+	}, newTestMetadataCache(), testLogger())
+
+	hits, err := chain.Search(context.Background(), CatalogQuery{Query: "dune", Limit: 5})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+
+	if len(hits) != 2 {
+		t.Fatalf("Search() returned %d hits, want 2", len(hits))
+	}
+
+	dune := hits[0]
+	if dune.Publisher != "Ace" || dune.Sources["publisher"] != ProviderOpenLibrary {
+		t.Errorf("publisher = %q from %q, want %q from %q", dune.Publisher, dune.Sources["publisher"], "Ace", ProviderOpenLibrary)
+	}
+
+	if dune.Description != "Frank Herbert's classic masterpiece." || dune.Sources["description"] != ProviderGoogleBooks {
+		t.Errorf("description = %q from %q, want the one of %q", dune.Description, dune.Sources["description"], ProviderGoogleBooks)
+	}
+}
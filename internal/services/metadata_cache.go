@@ -0,0 +1,260 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/martialanouman/personal-library/internal/store"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultMetadataCacheTTL         = 7 * 24 * time.Hour
+	defaultMetadataCacheNegativeTTL = time.Hour
+	defaultMetadataCacheSize        = 1000
+)
+
+// MetadataCacheStats counts how lookups were answered since the start.
+type MetadataCacheStats struct {
+	MemoryHits     int64 `json:"memory_hits"`
+	StoreHits      int64 `json:"store_hits"`
+	NegativeHits   int64 `json:"negative_hits"`
+	Misses         int64 `json:"misses"`
+	Coalesced      int64 `json:"coalesced"`
+	StaleServed    int64 `json:"stale_served"`
+	UpstreamErrors int64 `json:"upstream_errors"`
+	Entries        int   `json:"entries"`
+}
+
+type metadataCacheItem struct {
+	key   string
+	entry *store.MetadataCacheEntry
+}
+
+// MetadataCache keeps the answers of the metadata providers in an in-process
+// LRU backed by the database. Answers expire after a TTL, not found answers
+// after a shorter one, and concurrent lookups of the same book only reach the
+// provider once. When a provider fails, an expired answer is served if there
+// is one.
+type MetadataCache struct {
+	store       store.MetadataCacheStore
+	ttl         time.Duration
+	negativeTTL time.Duration
+	size        int
+	logger      *log.Logger
+
+	mu    sync.Mutex
+	lru   *list.List
+	items map[string]*list.Element
+	group singleflight.Group
+
+	memoryHits     atomic.Int64
+	storeHits      atomic.Int64
+	negativeHits   atomic.Int64
+	misses         atomic.Int64
+	coalesced      atomic.Int64
+	staleServed    atomic.Int64
+	upstreamErrors atomic.Int64
+}
+
+// NewMetadataCache reads its settings from the METADATA_CACHE_TTL and
+// METADATA_CACHE_NEGATIVE_TTL durations and the METADATA_CACHE_SIZE number
+// of entries kept in memory.
+func NewMetadataCache(store store.MetadataCacheStore, logger *log.Logger) *MetadataCache {
+	return &MetadataCache{
+		store:       store,
+		ttl:         durationFromEnv("METADATA_CACHE_TTL", defaultMetadataCacheTTL, logger),
+		negativeTTL: durationFromEnv("METADATA_CACHE_NEGATIVE_TTL", defaultMetadataCacheNegativeTTL, logger),
+		size:        sizeFromEnv("METADATA_CACHE_SIZE", defaultMetadataCacheSize, logger),
+		logger:      logger,
+		lru:         list.New(),
+		items:       make(map[string]*list.Element),
+	}
+}
+
+func durationFromEnv(name string, fallback time.Duration, logger *log.Logger) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		logger.Printf("WARNING: invalid %s %q, using %s", name, value, fallback)
+		return fallback
+	}
+
+	return duration
+}
+
+func sizeFromEnv(name string, fallback int, logger *log.Logger) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	size, err := strconv.Atoi(value)
+	if err != nil || size <= 0 {
+		logger.Printf("WARNING: invalid %s %q, using %d", name, value, fallback)
+		return fallback
+	}
+
+	return size
+}
+
+// Fetch decodes into out the answer of provider for the book it knows as
+// externalId, calling fetch when no fresh answer is cached. It returns
+// ErrMetadataNotFound when the provider does not know the book.
+func (c *MetadataCache) Fetch(ctx context.Context, provider, externalId string, out any, fetch func(ctx context.Context) (any, error)) error {
+	key := provider + ":" + externalId
+
+	if entry := c.get(key); entry != nil && entry.ExpiresAt.After(time.Now()) {
+		c.memoryHits.Add(1)
+		return c.decode(entry, out)
+	}
+
+	// The lookup is shared by every caller waiting for it, so it must not be
+	// cancelled when the first one goes away.
+	value, err, shared := c.group.Do(key, func() (any, error) {
+		return c.load(context.WithoutCancel(ctx), key, provider, externalId, fetch)
+	})
+	if shared {
+		c.coalesced.Add(1)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return c.decode(value.(*store.MetadataCacheEntry), out)
+}
+
+func (c *MetadataCache) load(ctx context.Context, key, provider, externalId string, fetch func(ctx context.Context) (any, error)) (*store.MetadataCacheEntry, error) {
+	stale := c.get(key)
+
+	entry, err := c.store.GetMetadataCacheEntry(provider, externalId)
+	if err != nil {
+		c.logger.Printf("ERROR: reading metadata cache %v", err)
+	}
+
+	if entry != nil {
+		if entry.ExpiresAt.After(time.Now()) {
+			c.storeHits.Add(1)
+			c.put(key, entry)
+			return entry, nil
+		}
+		stale = entry
+	}
+
+	c.misses.Add(1)
+	now := time.Now()
+	entry = &store.MetadataCacheEntry{Provider: provider, ExternalID: externalId, FetchedAt: now}
+
+	value, err := fetch(ctx)
+	switch {
+	case errors.Is(err, ErrMetadataNotFound):
+		entry.NotFound = true
+		entry.ExpiresAt = now.Add(c.negativeTTL)
+	case err != nil:
+		c.upstreamErrors.Add(1)
+		if stale != nil {
+			c.logger.Printf("WARNING: serving stale %s metadata of %s: %v", provider, externalId, err)
+			c.staleServed.Add(1)
+			return stale, nil
+		}
+		return nil, err
+	default:
+		payload, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		entry.Payload = payload
+		entry.ExpiresAt = now.Add(c.ttl)
+	}
+
+	if err := c.store.SaveMetadataCacheEntry(entry); err != nil {
+		c.logger.Printf("ERROR: writing metadata cache %v", err)
+	}
+	c.put(key, entry)
+
+	return entry, nil
+}
+
+func (c *MetadataCache) decode(entry *store.MetadataCacheEntry, out any) error {
+	if entry.NotFound {
+		c.negativeHits.Add(1)
+		return ErrMetadataNotFound
+	}
+
+	return json.Unmarshal(entry.Payload, out)
+}
+
+func (c *MetadataCache) get(key string) *store.MetadataCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+
+	c.lru.MoveToFront(element)
+	return element.Value.(*metadataCacheItem).entry
+}
+
+func (c *MetadataCache) put(key string, entry *store.MetadataCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		element.Value.(*metadataCacheItem).entry = entry
+		c.lru.MoveToFront(element)
+		return
+	}
+
+	c.items[key] = c.lru.PushFront(&metadataCacheItem{key: key, entry: entry})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.items, oldest.Value.(*metadataCacheItem).key)
+	}
+}
+
+func (c *MetadataCache) Stats() MetadataCacheStats {
+	c.mu.Lock()
+	entries := c.lru.Len()
+	c.mu.Unlock()
+
+	return MetadataCacheStats{
+		MemoryHits:     c.memoryHits.Load(),
+		StoreHits:      c.storeHits.Load(),
+		NegativeHits:   c.negativeHits.Load(),
+		Misses:         c.misses.Load(),
+		Coalesced:      c.coalesced.Load(),
+		StaleServed:    c.staleServed.Load(),
+		UpstreamErrors: c.upstreamErrors.Load(),
+		Entries:        entries,
+	}
+}
+
+// PurgeExpired deletes the stored answers that expired more than a TTL ago.
+// Expired answers are kept that long to be served while a provider fails.
+func (c *MetadataCache) PurgeExpired() {
+	count, err := c.store.DeleteExpiredMetadataCacheEntries(time.Now().Add(-c.ttl))
+	if err != nil {
+		c.logger.Printf("ERROR: purging metadata cache %v", err)
+		return
+	}
+
+	if count > 0 {
+		c.logger.Printf("purged %d expired metadata cache entries", count)
+	}
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"context"
+	"log"
+)
+
+// Notification is a message sent to a user about their library.
+type Notification struct {
+	UserID  string
+	Subject string
+	Message string
+}
+
+// Notifier delivers notifications to users. Implementations may send emails,
+// push notifications or anything else.
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// LogNotifier writes the notifications to the logger. It is the notifier used
+// until a delivery channel is configured.
+type LogNotifier struct {
+	logger *log.Logger
+}
+
+func NewLogNotifier(logger *log.Logger) *LogNotifier {
+	return &LogNotifier{logger: logger}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, notification Notification) error {
+	n.logger.Printf("NOTIFY: user %s: %s: %s", notification.UserID, notification.Subject, notification.Message)
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/martialanouman/personal-library/internal/outbound"
+)
+
+// OpenLibraryProvider looks books up in Open Library, which needs no
+// credentials.
+type OpenLibraryProvider struct {
+	client  *outbound.Client
+	baseURL string
+	logger  *log.Logger
+}
+
+type openLibraryBook struct {
+	Title         string `json:"title"`
+	Subtitle      string `json:"subtitle"`
+	NumberOfPages int    `json:"number_of_pages"`
+	PublishDate   string `json:"publish_date"`
+	Authors       []struct {
+		Name string `json:"name"`
+	} `json:"authors"`
+	Publishers []struct {
+		Name string `json:"name"`
+	} `json:"publishers"`
+	Identifiers struct {
+		Isbn10 []string `json:"isbn_10"`
+		Isbn13 []string `json:"isbn_13"`
+	} `json:"identifiers"`
+	Cover struct {
+		Large  string `json:"large"`
+		Medium string `json:"medium"`
+	} `json:"cover"`
+	Excerpts []struct {
+		Text string `json:"text"`
+	} `json:"excerpts"`
+}
+
+type openLibrarySearchResponse struct {
+	Docs []struct {
+		Title      string   `json:"title"`
+		AuthorName []string `json:"author_name"`
+		Isbn       []string `json:"isbn"`
+		CoverID    int      `json:"cover_i"`
+		PageCount  int      `json:"number_of_pages_median"`
+		Publisher  []string `json:"publisher"`
+		FirstYear  int      `json:"first_publish_year"`
+	} `json:"docs"`
+}
+
+func NewOpenLibraryProvider(client *outbound.Client, logger *log.Logger) *OpenLibraryProvider {
+	baseURL := os.Getenv("OPEN_LIBRARY_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://openlibrary.org"
+	}
+
+	return &OpenLibraryProvider{client: client, baseURL: baseURL, logger: logger}
+}
+
+func (o *OpenLibraryProvider) Name() string {
+	return ProviderOpenLibrary
+}
+
+// LookupISBN uses the books API, which answers with an empty object for
+// unknown ISBNs.
+func (o *OpenLibraryProvider) LookupISBN(ctx context.Context, isbn string) (*BookMetadata, error) {
+	u, err := url.JoinPath(o.baseURL, "api", "books")
+	if err != nil {
+		return nil, fmt.Errorf("failed to join URL path: %w", err)
+	}
+
+	key := "ISBN:" + isbn
+	u += "?" + url.Values{"bibkeys": {key}, "format": {"json"}, "jscmd": {"data"}}.Encode()
+
+	var response map[string]openLibraryBook
+	if err := getJSON(ctx, o.client, u, nil, &response); err != nil {
+		return nil, err
+	}
+
+	book, ok := response[key]
+	if !ok {
+		return nil, ErrMetadataNotFound
+	}
+
+	metadata := &BookMetadata{
+		Title:         book.Title,
+		PageCount:     book.NumberOfPages,
+		PublishedDate: book.PublishDate,
+		CoverURL:      book.Cover.Large,
+	}
+
+	if book.Subtitle != "" {
+		metadata.Title = book.Title + ": " + book.Subtitle
+	}
+
+	if metadata.CoverURL == "" {
+		metadata.CoverURL = book.Cover.Medium
+	}
+
+	for _, author := range book.Authors {
+		metadata.Authors = append(metadata.Authors, author.Name)
+	}
+
+	if len(book.Publishers) > 0 {
+		metadata.Publisher = book.Publishers[0].Name
+	}
+
+	if len(book.Identifiers.Isbn10) > 0 {
+		metadata.Isbn10 = book.Identifiers.Isbn10[0]
+	}
+
+	if len(book.Identifiers.Isbn13) > 0 {
+		metadata.Isbn13 = book.Identifiers.Isbn13[0]
+	}
+
+	if len(book.Excerpts) > 0 {
+		metadata.Description = strings.TrimSpace(book.Excerpts[0].Text)
+	}
+
+	return metadata, nil
+}
+
+func (o *OpenLibraryProvider) Search(ctx context.Context, query CatalogQuery) ([]BookMetadata, error) {
+	u, err := url.JoinPath(o.baseURL, "search.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to join URL path: %w", err)
+	}
+
+	params := url.Values{
+		"limit":  {strconv.Itoa(query.Limit)},
+		"fields": {"title,author_name,isbn,cover_i,number_of_pages_median,publisher,first_publish_year"},
+	}
+	if query.Query != "" {
+		params.Set("q", query.Query)
+	}
+	if query.Author != "" {
+		params.Set("author", query.Author)
+	}
+	if query.Isbn != "" {
+		params.Set("isbn", query.Isbn)
+	}
+	u += "?" + params.Encode()
+
+	var response openLibrarySearchResponse
+	if err := getJSON(ctx, o.client, u, nil, &response); err != nil {
+		return nil, err
+	}
+
+	hits := make([]BookMetadata, 0, len(response.Docs))
+	for _, doc := range response.Docs {
+		metadata := BookMetadata{
+			Title:     doc.Title,
+			Authors:   doc.AuthorName,
+			PageCount: doc.PageCount,
+		}
+
+		if doc.CoverID > 0 {
+			metadata.CoverURL = fmt.Sprintf("https://covers.openlibrary.org/b/id/%d-L.jpg", doc.CoverID)
+		}
+
+		if len(doc.Publisher) > 0 {
+			metadata.Publisher = doc.Publisher[0]
+		}
+
+		if doc.FirstYear > 0 {
+			metadata.PublishedDate = strconv.Itoa(doc.FirstYear)
+		}
+
+		// Works list the ISBNs of all their editions: keep the one searched
+		// for, or else the first ISBN-13.
+		for _, candidate := range doc.Isbn {
+			if query.Isbn != "" && candidate != query.Isbn {
+				continue
+			}
+
+			switch len(candidate) {
+			case 13:
+				metadata.Isbn13 = candidate
+			case 10:
+				metadata.Isbn10 = candidate
+			}
+
+			if metadata.Isbn13 != "" {
+				break
+			}
+		}
+
+		hits = append(hits, metadata)
+	}
+
+	return hits, nil
+}
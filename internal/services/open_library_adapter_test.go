@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestOpenLibraryLookupISBN(t *testing.T) {
+	server := newFixtureServer(t, map[string]http.HandlerFunc{
+		"/api/books": fixture(t, "openlibrary_book.json"),
+	})
+	provider := newTestOpenLibraryProvider(server)
+
+	metadata, err := provider.LookupISBN(context.Background(), "9780441172719")
+	if err != nil {
+		t.Fatalf("LookupISBN() error = %v", err)
+	}
+
+	want := BookMetadata{
+		Title:         "Dune: Deluxe Edition",
+		Isbn10:        "0441172717",
+		Isbn13:        "9780441172719",
+		Description:   "In the week before their departure to Arrakis...",
+		CoverURL:      "https://covers.openlibrary.org/b/id/8231991-L.jpg",
+		PageCount:     604,
+		Publisher:     "Ace",
+		PublishedDate: "2005",
+	}
+
+	if metadata.Title != want.Title || metadata.Isbn10 != want.Isbn10 || metadata.Isbn13 != want.Isbn13 ||
+		metadata.Description != want.Description || metadata.CoverURL != want.CoverURL || metadata.PageCount != want.PageCount ||
+		metadata.Publisher != want.Publisher || metadata.PublishedDate != want.PublishedDate {
+		t.Errorf("LookupISBN() = %+v, want %+v", metadata, want)
+	}
+
+	if len(metadata.Authors) != 1 || metadata.Authors[0] != "Frank Herbert" {
+		t.Errorf("Authors = %v, want [Frank Herbert]", metadata.Authors)
+	}
+}
+
+func TestOpenLibraryLookupISBNNotFound(t *testing.T) {
+	server := newFixtureServer(t, map[string]http.HandlerFunc{
+		"/api/books": fixture(t, "openlibrary_book_empty.json"),
+	})
+	provider := newTestOpenLibraryProvider(server)
+
+	_, err := provider.LookupISBN(context.Background(), "9780000000002")
+	if !errors.Is(err, ErrMetadataNotFound) {
+		t.Fatalf("LookupISBN() error = %v, want %v", err, ErrMetadataNotFound)
+	}
+}
+
+func TestOpenLibrarySearch(t *testing.T) {
+	server := newFixtureServer(t, map[string]http.HandlerFunc{
+		"/search.json": fixture(t, "openlibrary_search.json"),
+	})
+	provider := newTestOpenLibraryProvider(server)
+
+	hits, err := provider.Search(context.Background(), CatalogQuery{Query: "dune", Limit: 5})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+
+	if len(hits) != 2 {
+		t.Fatalf("Search() returned %d hits, want 2", len(hits))
+	}
+
+	// The first ISBN-13 of the work is kept.
+	if hits[0].Isbn13 != "9780441172719" || hits[0].Isbn10 != "0441172717" {
+		t.Errorf("ISBNs = %q, %q", hits[0].Isbn13, hits[0].Isbn10)
+	}
+
+	if hits[0].CoverURL != "https://covers.openlibrary.org/b/id/8231991-L.jpg" || hits[0].PublishedDate != "1965" {
+		t.Errorf("Search()[0] = %+v", hits[0])
+	}
+
+	if hits[1].CoverURL != "" {
+		t.Errorf("CoverURL = %q, want none", hits[1].CoverURL)
+	}
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+const (
+	RecommendationKindBook = "book"
+	RecommendationKindWish = "wish"
+
+	authorWeight = 0.6
+	genreWeight  = 0.4
+)
+
+type Recommendation struct {
+	Kind    string   `json:"kind"`
+	ID      string   `json:"id"`
+	Title   string   `json:"title"`
+	Author  *string  `json:"author,omitempty"`
+	Genre   *string  `json:"genre,omitempty"`
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// affinity aggregates how a user engaged with the books sharing an author or a genre.
+type affinity struct {
+	rated     int
+	ratingSum float64
+	fiveStars int
+	started   int
+	finished  int
+}
+
+func (a *affinity) averageRating() float64 {
+	if a.rated == 0 {
+		return 0
+	}
+
+	return a.ratingSum / float64(a.rated)
+}
+
+// weight returns a value in [-1, 1]: ratings are centered on 3 stars so that
+// disliked authors or genres push candidates down, and abandoned books lower
+// the weight through the finish rate.
+func (a *affinity) weight() float64 {
+	if a.rated == 0 && a.started == 0 {
+		return 0
+	}
+
+	ratingScore := 0.0
+	if a.rated > 0 {
+		ratingScore = (a.averageRating() - 3) / 2
+	}
+
+	finishRate := 1.0
+	if a.started > 0 {
+		finishRate = float64(a.finished) / float64(a.started)
+	}
+
+	if a.rated == 0 {
+		// Started but never finished nor rated: only the finish rate speaks.
+		return (finishRate - 0.5) / 2
+	}
+
+	confidence := 1 - 1/float64(a.rated+1)
+
+	return ratingScore * (0.5 + 0.5*finishRate) * confidence
+}
+
+type affinities map[string]*affinity
+
+func (m affinities) record(key string, book store.Book) {
+	// A book being read is neither finished nor abandoned yet.
+	if key == "" || book.Status == "reading" {
+		return
+	}
+
+	a, ok := m[key]
+	if !ok {
+		a = &affinity{}
+		m[key] = a
+	}
+
+	a.started++
+	if book.Status == "read" {
+		a.finished++
+	}
+
+	// Books imported from a catalog are not rated until the user rates them.
+	if book.Status == "read" && book.Rating > 0 {
+		a.rated++
+		a.ratingSum += book.Rating
+		if book.Rating == 5 {
+			a.fiveStars++
+		}
+	}
+}
+
+type readerProfile struct {
+	authors affinities
+	genres  affinities
+}
+
+type Recommender struct{}
+
+func NewRecommender() *Recommender {
+	return &Recommender{}
+}
+
+// Recommend scores the user's to_read books and pending wishes against a
+// profile built from the books they already started or finished.
+func (r *Recommender) Recommend(books []store.Book, wishes []store.Wish, limit int) []Recommendation {
+	profile := buildProfile(books)
+	recommendations := []Recommendation{}
+
+	for _, book := range books {
+		if book.Status != "to_read" || book.DateStarted != nil {
+			continue
+		}
+
+		author := book.Author
+		rec := Recommendation{
+			Kind:   RecommendationKindBook,
+			ID:     book.ID,
+			Title:  book.Title,
+			Author: &author,
+			Genre:  book.Genre,
+		}
+
+		if profile.score(&rec) {
+			recommendations = append(recommendations, rec)
+		}
+	}
+
+	for _, wish := range wishes {
+		rec := Recommendation{
+			Kind:   RecommendationKindWish,
+			ID:     wish.ID,
+			Title:  wish.Title,
+			Author: wish.Author,
+		}
+
+		if profile.score(&rec) {
+			recommendations = append(recommendations, rec)
+		}
+	}
+
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		return recommendations[i].Score > recommendations[j].Score
+	})
+
+	if limit > 0 && len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+
+	return recommendations
+}
+
+func buildProfile(books []store.Book) *readerProfile {
+	profile := &readerProfile{
+		authors: make(affinities),
+		genres:  make(affinities),
+	}
+
+	for _, book := range books {
+		started := book.Status != "to_read" || book.DateStarted != nil
+		if !started {
+			continue
+		}
+
+		profile.authors.record(normalizeKey(book.Author), book)
+		if book.Genre != nil {
+			profile.genres.record(normalizeKey(*book.Genre), book)
+		}
+	}
+
+	return profile
+}
+
+// score fills the score and reasons of the recommendation and reports
+// whether it should be suggested at all.
+func (p *readerProfile) score(rec *Recommendation) bool {
+	reasons := []string{}
+	score := 0.0
+
+	if rec.Author != nil {
+		if a, ok := p.authors[normalizeKey(*rec.Author)]; ok {
+			score += authorWeight * a.weight()
+			reasons = append(reasons, a.reasons("by this author")...)
+		}
+	}
+
+	if rec.Genre != nil {
+		if a, ok := p.genres[normalizeKey(*rec.Genre)]; ok {
+			score += genreWeight * a.weight()
+			reasons = append(reasons, a.reasons(fmt.Sprintf("in %s", *rec.Genre))...)
+		}
+	}
+
+	if score <= 0 {
+		return false
+	}
+
+	rec.Score = float64(int(score*1000+0.5)) / 1000
+	rec.Reasons = reasons
+
+	return true
+}
+
+func (a *affinity) reasons(scope string) []string {
+	reasons := []string{}
+
+	switch {
+	case a.rated > 0 && a.fiveStars == a.rated:
+		reasons = append(reasons, fmt.Sprintf("you rated %s %s 5 stars", pluralize(a.rated, "other book"), scope))
+	case a.rated > 0:
+		reasons = append(reasons, fmt.Sprintf("you rated %s %s %.1f stars on average", pluralize(a.rated, "other book"), scope, a.averageRating()))
+	}
+
+	if a.started > a.finished {
+		reasons = append(reasons, fmt.Sprintf("you finished %d of the %s you started %s", a.finished, pluralize(a.started, "book"), scope))
+	}
+
+	return reasons
+}
+
+func normalizeKey(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+func TestRecommendFinishRate(t *testing.T) {
+	started := time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)
+	candidate := store.Book{ID: "candidate", Title: "Next", Author: "Ursula K. Le Guin", Status: "to_read"}
+	finished := store.Book{ID: "finished", Title: "Finished", Author: "Ursula K. Le Guin", Status: "read", Rating: 5}
+
+	tests := []struct {
+		name    string
+		other   store.Book
+		reasons []string
+	}{
+		{
+			name:    "book being read",
+			other:   store.Book{ID: "other", Title: "Current", Author: "Ursula K. Le Guin", Status: "reading", DateStarted: &started},
+			reasons: []string{"you rated 1 other book by this author 5 stars"},
+		},
+		{
+			name:  "abandoned book",
+			other: store.Book{ID: "other", Title: "Abandoned", Author: "Ursula K. Le Guin", Status: "to_read", DateStarted: &started},
+			reasons: []string{
+				"you rated 1 other book by this author 5 stars",
+				"you finished 1 of the 2 books you started by this author",
+			},
+		},
+	}
+
+	alone := NewRecommender().Recommend([]store.Book{candidate, finished}, nil, 0)
+	if len(alone) != 1 {
+		t.Fatalf("Recommend() = %v, want the candidate only", alone)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recommendations := NewRecommender().Recommend([]store.Book{candidate, finished, tt.other}, nil, 0)
+			if len(recommendations) != 1 || recommendations[0].ID != candidate.ID {
+				t.Fatalf("Recommend() = %v, want the candidate only", recommendations)
+			}
+
+			if got := recommendations[0].Reasons; !slices.Equal(got, tt.reasons) {
+				t.Errorf("Reasons = %q, want %q", got, tt.reasons)
+			}
+
+			abandoned := tt.other.Status != "reading"
+			if lowered := recommendations[0].Score < alone[0].Score; lowered != abandoned {
+				t.Errorf("Score = %v next to %v alone, want lowered = %v", recommendations[0].Score, alone[0].Score, abandoned)
+			}
+		})
+	}
+}
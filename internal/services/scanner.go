@@ -0,0 +1,46 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/martialanouman/personal-library/internal/barcode"
+	"github.com/martialanouman/personal-library/internal/isbn"
+)
+
+// MaxScanSize is the largest photo accepted for barcode scanning, in bytes.
+const MaxScanSize = 10 << 20
+
+// ErrInvalidImage is returned for files that are not a JPEG, PNG or GIF
+// image.
+var ErrInvalidImage = errors.New("invalid image")
+
+// ScannedBarcode is an EAN-13 barcode found in a photo. Isbn is set when the
+// barcode is the ISBN-13 of a book.
+type ScannedBarcode struct {
+	Barcode string `json:"barcode"`
+	Isbn    string `json:"isbn,omitempty"`
+}
+
+// ScanBarcodes returns the EAN-13 barcodes found in the photo. The photo
+// should be sharp, with bars at least two pixels wide.
+func ScanBarcodes(data []byte) ([]ScannedBarcode, error) {
+	img, _, err := decodeImage(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	codes := barcode.Decode(img)
+	scanned := make([]ScannedBarcode, 0, len(codes))
+	for _, code := range codes {
+		result := ScannedBarcode{Barcode: code}
+		// Books use the 978 and 979 prefixes, other products carry EAN-13 too.
+		if isbn13, err := isbn.Parse(code); err == nil && (strings.HasPrefix(code, "978") || strings.HasPrefix(code, "979")) {
+			result.Isbn = isbn13
+		}
+		scanned = append(scanned, result)
+	}
+
+	return scanned, nil
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/martialanouman/personal-library/internal/store"
+)
+
+const (
+	defaultTrashRetentionDays = 30
+	trashPurgeInterval        = time.Hour
+	trashPurgeBatchSize       = 100
+)
+
+// TrashRetention is how long deleted books and wishes stay in the trash, read
+// from the TRASH_RETENTION_DAYS number of days.
+func TrashRetention(logger *log.Logger) time.Duration {
+	days := sizeFromEnv("TRASH_RETENTION_DAYS", defaultTrashRetentionDays, logger)
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// TrashPurger deletes for good the books and wishes that stayed in the trash
+// longer than the retention, with the cover files no other book uses.
+type TrashPurger struct {
+	store     store.TrashStore
+	covers    *CoverService
+	retention time.Duration
+	logger    *log.Logger
+}
+
+func NewTrashPurger(store store.TrashStore, covers *CoverService, retention time.Duration, logger *log.Logger) *TrashPurger {
+	return &TrashPurger{store: store, covers: covers, retention: retention, logger: logger}
+}
+
+// Run purges the expired trash every hour until ctx is done.
+func (p *TrashPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *TrashPurger) purge(ctx context.Context) {
+	for ctx.Err() == nil {
+		expired, err := p.store.GetExpiredTrash(time.Now().Add(-p.retention), trashPurgeBatchSize)
+		if err != nil {
+			p.logger.Printf("ERROR: getting expired trash %v", err)
+			return
+		}
+
+		purged := 0
+		for _, item := range expired {
+			if ctx.Err() != nil {
+				return
+			}
+
+			hashes, err := p.store.PurgeItem(item.Type, item.ID)
+			if errors.Is(err, pgx.ErrNoRows) {
+				// Restored or purged in the meantime.
+				continue
+			}
+
+			if err != nil {
+				p.logger.Printf("ERROR: purging %s %s %v", item.Type, item.ID, err)
+				continue
+			}
+
+			p.covers.DeleteFiles(ctx, hashes)
+			purged++
+		}
+
+		// A batch that purged nothing would be picked up again as is.
+		if len(expired) < trashPurgeBatchSize || purged == 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Package storage keeps files such as book covers out of the database.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+
+	defaultLocalPath = "./data"
+)
+
+var ErrNotFound = errors.New("file not found")
+
+// Storage saves files under slash separated keys.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get returns ErrNotFound when no file is saved under key.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// New returns the storage selected by the STORAGE_BACKEND variable. Only the
+// local backend, saving files under STORAGE_PATH, is available for now.
+func New() (Storage, error) {
+	backend := os.Getenv("STORAGE_BACKEND")
+	if backend == "" {
+		backend = BackendLocal
+	}
+
+	switch backend {
+	case BackendLocal:
+		path := os.Getenv("STORAGE_PATH")
+		if path == "" {
+			path = defaultLocalPath
+		}
+		return NewLocalStorage(path)
+	case BackendS3:
+		return nil, errors.New("the s3 storage backend is not available yet")
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
@@ -0,0 +1,242 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	AuthorRoleAuthor      = "author"
+	AuthorRoleTranslator  = "translator"
+	AuthorRoleIllustrator = "illustrator"
+	AuthorRoleEditor      = "editor"
+)
+
+var AuthorRoles = []string{AuthorRoleAuthor, AuthorRoleTranslator, AuthorRoleIllustrator, AuthorRoleEditor}
+
+type Author struct {
+	ID             string    `json:"id" db:"id"`
+	Name           string    `json:"name" db:"name"`
+	NormalizedName string    `json:"-" db:"normalized_name"`
+	BooksCount     int       `json:"books_count" db:"books_count"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type BookAuthor struct {
+	AuthorID string `json:"author_id" db:"author_id"`
+	Name     string `json:"name" db:"name"`
+	Role     string `json:"role" db:"role"`
+	Position int    `json:"-" db:"position"`
+}
+
+type AuthorStore interface {
+	GetUserAuthors(userId string) ([]Author, error)
+	GetAuthorById(id, userId string) (*Author, error)
+	GetAuthorBooks(authorId, userId string) ([]Book, error)
+	GetBookAuthors(bookId string) ([]BookAuthor, error)
+}
+
+type PostgresAuthorStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresAuthorStore(db *pgxpool.Pool) *PostgresAuthorStore {
+	return &PostgresAuthorStore{db}
+}
+
+// unaccentLetters are the letters the unaccent extension transliterates but
+// NFKD does not decompose, lower cased.
+var unaccentLetters = map[rune]string{
+	'Æ': "ae", 'æ': "ae", 'Ð': "d", 'ð': "d", 'Ø': "o", 'ø': "o", 'Þ': "th", 'þ': "th",
+	'ß': "ss", 'ẞ': "ss", 'Đ': "d", 'đ': "d", 'Ħ': "h", 'ħ': "h", 'ı': "i", 'ĸ': "q",
+	'Ł': "l", 'ł': "l", 'Ŋ': "n", 'ŋ': "n", 'Œ': "oe", 'œ': "oe", 'Ŧ': "t", 'ŧ': "t",
+	'ƀ': "b", 'Ɓ': "b", 'Ɨ': "i", 'ɨ': "i", 'Ƶ': "z", 'ƶ': "z",
+}
+
+// NormalizeAuthorName reduces a name to the key used to deduplicate authors:
+// accents and case are dropped and any run of punctuation or spaces becomes a
+// single space, so "J.R.R. Tolkien" and "J. R. R.  Tolkien" are the same
+// author. It gives the same keys as the normalize_author_name SQL function,
+// which relies on unaccent: "Strauß" and "Strauss" are the same author too.
+func NormalizeAuthorName(name string) string {
+	var b strings.Builder
+	pendingSpace := false
+
+	write := func(s string) {
+		if pendingSpace && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		pendingSpace = false
+		b.WriteString(s)
+	}
+
+	for _, r := range norm.NFKD.String(name) {
+		if letters, ok := unaccentLetters[r]; ok {
+			write(letters)
+			continue
+		}
+
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			write(string(unicode.ToLower(r)))
+		default:
+			pendingSpace = true
+		}
+	}
+
+	return b.String()
+}
+
+// AuthorsDisplayName joins the names of the main authors, the way it is
+// stored in books.author.
+func AuthorsDisplayName(authors []BookAuthor) string {
+	names := []string{}
+	for _, author := range authors {
+		if author.Role == AuthorRoleAuthor {
+			names = append(names, author.Name)
+		}
+	}
+
+	return strings.Join(names, ", ")
+}
+
+const authorColumns = `
+	a.id, a.name, a.normalized_name,
+	(SELECT COUNT(DISTINCT ba.book_id) FROM book_authors ba JOIN books b ON b.id = ba.book_id WHERE ba.author_id = a.id AND b.user_id = $1 AND b.deleted_at IS NULL)::INTEGER AS books_count,
+	a.created_at, a.updated_at
+`
+
+func (s *PostgresAuthorStore) GetUserAuthors(userId string) ([]Author, error) {
+	query := "SELECT " + authorColumns + `
+		FROM authors a
+		WHERE EXISTS (SELECT 1 FROM book_authors ba JOIN books b ON b.id = ba.book_id WHERE ba.author_id = a.id AND b.user_id = $1 AND b.deleted_at IS NULL)
+		ORDER BY a.name
+	`
+
+	rows, err := s.db.Query(context.Background(), query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	authors, err := pgx.CollectRows(rows, pgx.RowToStructByName[Author])
+	if err != nil {
+		return nil, err
+	}
+
+	return authors, nil
+}
+
+// GetAuthorById returns the author with the number of books of the user they
+// took part in.
+func (s *PostgresAuthorStore) GetAuthorById(id, userId string) (*Author, error) {
+	query := "SELECT " + authorColumns + " FROM authors a WHERE a.id = $2"
+
+	rows, _ := s.db.Query(context.Background(), query, userId, id)
+	author, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[Author])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return author, nil
+}
+
+func (s *PostgresAuthorStore) GetAuthorBooks(authorId, userId string) ([]Book, error) {
+	query := `
+		SELECT b.*
+		FROM books b
+		WHERE b.user_id = $1 AND b.deleted_at IS NULL AND EXISTS (SELECT 1 FROM book_authors ba WHERE ba.book_id = b.id AND ba.author_id = $2)
+		ORDER BY b.created_at DESC
+	`
+
+	rows, err := s.db.Query(context.Background(), query, userId, authorId)
+	if err != nil {
+		return nil, err
+	}
+
+	books, err := pgx.CollectRows(rows, pgx.RowToStructByName[Book])
+	if err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+func (s *PostgresAuthorStore) GetBookAuthors(bookId string) ([]BookAuthor, error) {
+	return getBookAuthors(context.Background(), s.db, bookId)
+}
+
+func getBookAuthors(ctx context.Context, q querier, bookId string) ([]BookAuthor, error) {
+	query := `
+		SELECT ba.author_id::TEXT AS author_id, a.name, ba.role::TEXT AS role, ba.position
+		FROM book_authors ba
+		JOIN authors a ON a.id = ba.author_id
+		WHERE ba.book_id = $1
+		ORDER BY ba.position
+	`
+
+	rows, err := q.Query(ctx, query, bookId)
+	if err != nil {
+		return nil, err
+	}
+
+	authors, err := pgx.CollectRows(rows, pgx.RowToStructByName[BookAuthor])
+	if err != nil {
+		return nil, err
+	}
+
+	return authors, nil
+}
+
+// setBookAuthors replaces the contributors of the book, creating the authors
+// that are not known yet. The authors are updated with their id and position.
+func setBookAuthors(ctx context.Context, q querier, bookId string, authors []BookAuthor) error {
+	if _, err := q.Exec(ctx, "DELETE FROM book_authors WHERE book_id = $1", bookId); err != nil {
+		return err
+	}
+
+	upsertQuery := `
+		INSERT INTO authors (name, normalized_name)
+		VALUES ($1, $2)
+		ON CONFLICT (normalized_name) DO UPDATE SET normalized_name = EXCLUDED.normalized_name
+		RETURNING id, name
+	`
+
+	linkQuery := `
+		INSERT INTO book_authors (book_id, author_id, role, position)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING
+	`
+
+	for i := range authors {
+		author := &authors[i]
+		normalized := NormalizeAuthorName(author.Name)
+		if normalized == "" {
+			continue
+		}
+
+		if err := q.QueryRow(ctx, upsertQuery, strings.TrimSpace(author.Name), normalized).Scan(&author.AuthorID, &author.Name); err != nil {
+			return err
+		}
+
+		author.Position = i
+		if _, err := q.Exec(ctx, linkQuery, bookId, author.AuthorID, author.Role, author.Position); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package store
+
+import "testing"
+
+func TestNormalizeAuthorName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"J.R.R. Tolkien", "j r r tolkien"},
+		{"  J. R. R.  Tolkien ", "j r r tolkien"},
+		{"Ursula K. Le Guin", "ursula k le guin"},
+		// Composed and decomposed accents.
+		{"Émile Zola", "emile zola"},
+		{"Émile Zola", "emile zola"},
+		{"Gabriel García Márquez", "gabriel garcia marquez"},
+		{"Gabriel García Márquez", "gabriel garcia marquez"},
+		{"Björk", "bjork"},
+		{"Björk", "bjork"},
+		// Letters unaccent transliterates without a decomposition.
+		{"Johann Strauß", "johann strauss"},
+		{"Johann Strauss", "johann strauss"},
+		{"Søren Kierkegaard", "soren kierkegaard"},
+		{"Stanisław Lem", "stanislaw lem"},
+		{"Œdipe", "oedipe"},
+		{"Þórbergur Þórðarson", "thorbergur thordarson"},
+		// Compatibility forms.
+		{"ﬁnn", "finn"},
+		{"Ｈａｒｕｋｉ Murakami", "haruki murakami"},
+		{"", ""},
+		{"...", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeAuthorName(tt.name); got != tt.want {
+			t.Errorf("NormalizeAuthorName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// BookRead is one reading cycle of a book. A book read several times has one
+// cycle per read, each with its own dates, rating and review.
+type BookRead struct {
+	ID         string     `json:"id" db:"id"`
+	BookID     string     `json:"book_id" db:"book_id"`
+	UserID     string     `json:"user_id" db:"user_id"`
+	StartedAt  *time.Time `json:"started_at,omitempty" db:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty" db:"finished_at"`
+	Rating     *float64   `json:"rating,omitempty" db:"rating"`
+	Review     *string    `json:"review,omitempty" db:"review"`
+	ReviewHTML *string    `json:"review_html,omitempty" db:"-"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type BookReadStore interface {
+	CreateRead(read *BookRead, actor RevisionActor) error
+	GetReadById(id string) (*BookRead, error)
+	GetBookReads(bookId string) ([]BookRead, error)
+	GetUserReads(userId string) ([]BookRead, error)
+	UpdateRead(read *BookRead, actor RevisionActor) error
+	DeleteRead(id string, actor RevisionActor) error
+}
+
+type PostgresBookReadStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresBookReadStore(db *pgxpool.Pool) *PostgresBookReadStore {
+	return &PostgresBookReadStore{db}
+}
+
+// latestReadOrder sorts the reading cycles of a book from the most recent one.
+const latestReadOrder = "COALESCE(started_at, finished_at, created_at) DESC, created_at DESC"
+
+// CreateRead adds a reading cycle to the book, whose dates, status and rating
+// then follow it if it is the latest one.
+func (s *PostgresBookReadStore) CreateRead(read *BookRead, actor RevisionActor) error {
+	ctx := context.Background()
+
+	trx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer trx.Rollback(ctx)
+
+	query := `
+		INSERT INTO book_reads (book_id, user_id, started_at, finished_at, rating, review)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`
+
+	err = trx.QueryRow(
+		ctx, query,
+		read.BookID,
+		read.UserID,
+		read.StartedAt,
+		read.FinishedAt,
+		read.Rating,
+		read.Review,
+	).Scan(&read.ID, &read.CreatedAt, &read.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := syncBookWithLatestRead(ctx, trx, read.BookID, actor); err != nil {
+		return err
+	}
+
+	return trx.Commit(ctx)
+}
+
+func (s *PostgresBookReadStore) GetReadById(id string) (*BookRead, error) {
+	query := "SELECT * FROM book_reads WHERE id = $1"
+
+	rows, _ := s.db.Query(context.Background(), query, id)
+	read, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[BookRead])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return read, nil
+}
+
+func (s *PostgresBookReadStore) GetBookReads(bookId string) ([]BookRead, error) {
+	query := "SELECT * FROM book_reads WHERE book_id = $1 ORDER BY " + latestReadOrder
+
+	rows, err := s.db.Query(context.Background(), query, bookId)
+	if err != nil {
+		return nil, err
+	}
+
+	reads, err := pgx.CollectRows(rows, pgx.RowToStructByName[BookRead])
+	if err != nil {
+		return nil, err
+	}
+
+	return reads, nil
+}
+
+func (s *PostgresBookReadStore) GetUserReads(userId string) ([]BookRead, error) {
+	query := `
+		SELECT * FROM book_reads r
+		WHERE r.user_id = $1 AND EXISTS (SELECT 1 FROM books b WHERE b.id = r.book_id AND b.deleted_at IS NULL)
+		ORDER BY r.book_id, ` + latestReadOrder
+
+	rows, err := s.db.Query(context.Background(), query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	reads, err := pgx.CollectRows(rows, pgx.RowToStructByName[BookRead])
+	if err != nil {
+		return nil, err
+	}
+
+	return reads, nil
+}
+
+func (s *PostgresBookReadStore) UpdateRead(read *BookRead, actor RevisionActor) error {
+	ctx := context.Background()
+
+	trx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer trx.Rollback(ctx)
+
+	query := `
+		UPDATE book_reads
+		SET started_at = $1, finished_at = $2, rating = $3, review = $4, updated_at = NOW()
+		WHERE id = $5
+		RETURNING updated_at
+	`
+
+	err = trx.QueryRow(
+		ctx, query,
+		read.StartedAt,
+		read.FinishedAt,
+		read.Rating,
+		read.Review,
+		read.ID,
+	).Scan(&read.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := syncBookWithLatestRead(ctx, trx, read.BookID, actor); err != nil {
+		return err
+	}
+
+	return trx.Commit(ctx)
+}
+
+func (s *PostgresBookReadStore) DeleteRead(id string, actor RevisionActor) error {
+	ctx := context.Background()
+
+	trx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer trx.Rollback(ctx)
+
+	var bookId string
+	err = trx.QueryRow(ctx, "DELETE FROM book_reads WHERE id = $1 RETURNING book_id", id).Scan(&bookId)
+	if err != nil {
+		return err
+	}
+
+	if err := syncBookWithLatestRead(ctx, trx, bookId, actor); err != nil {
+		return err
+	}
+
+	return trx.Commit(ctx)
+}
+
+// syncBookWithLatestRead derives the dates, status and rating of the book
+// from its latest reading cycle. A book without any cycle left goes back to
+// the reading list. The change is recorded as a revision of the book.
+func syncBookWithLatestRead(ctx context.Context, q querier, bookId string, actor RevisionActor) error {
+	var previousStatus, userId string
+	err := q.QueryRow(ctx, "SELECT status, user_id FROM books WHERE id = $1 FOR UPDATE", bookId).Scan(&previousStatus, &userId)
+	if err != nil {
+		return err
+	}
+
+	before, err := bookSnapshot(ctx, q, bookId, false)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE books b
+		SET date_started = r.started_at,
+			date_finished = r.finished_at,
+			rating = COALESCE(r.rating, b.rating),
+			status = CASE WHEN r.finished_at IS NOT NULL THEN 'read' ELSE 'reading' END::BOOK_STATUS,
+			updated_at = NOW()
+		FROM (SELECT * FROM book_reads WHERE book_id = $1 ORDER BY ` + latestReadOrder + ` LIMIT 1) r
+		WHERE b.id = $1
+		RETURNING b.status
+	`
+
+	var status string
+	err = q.QueryRow(ctx, query, bookId).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		query = `
+			UPDATE books
+			SET date_started = NULL, date_finished = NULL, status = 'to_read', updated_at = NOW()
+			WHERE id = $1
+			RETURNING status
+		`
+
+		err = q.QueryRow(ctx, query, bookId).Scan(&status)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	after, err := bookSnapshot(ctx, q, bookId, false)
+	if err != nil {
+		return err
+	}
+
+	if err := recordBookRevision(ctx, q, bookId, before, after, actor, nil); err != nil {
+		return err
+	}
+
+	return recordStatusChange(ctx, q, bookId, userId, &previousStatus, status)
+}
+
+// syncLatestReadWithBook records the dates and rating set on the book itself
+// in its latest reading cycle. Starting a finished book again opens a new
+// cycle, and so does starting a book for the first time. Putting a book back
+// on the reading list drops the read in progress.
+func syncLatestReadWithBook(ctx context.Context, q querier, book *Book) error {
+	if book.Status == BookStatusToRead {
+		_, err := q.Exec(ctx, "DELETE FROM book_reads WHERE book_id = $1 AND finished_at IS NULL", book.ID)
+		return err
+	}
+
+	// A rating belongs to a finished read.
+	var rating *float64
+	if book.Rating > 0 && (book.Status == BookStatusRead || book.DateFinished != nil) {
+		rating = &book.Rating
+	}
+
+	var (
+		latestId         string
+		latestFinishedAt *time.Time
+	)
+
+	query := "SELECT id, finished_at FROM book_reads WHERE book_id = $1 ORDER BY " + latestReadOrder + " LIMIT 1"
+	err := q.QueryRow(ctx, query, book.ID).Scan(&latestId, &latestFinishedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) || (latestFinishedAt != nil && book.DateFinished == nil && book.Status == BookStatusReading) {
+		query = `
+			INSERT INTO book_reads (book_id, user_id, started_at, finished_at, rating)
+			VALUES ($1, $2, $3, $4, $5)
+		`
+
+		_, err = q.Exec(ctx, query, book.ID, book.UserId, book.DateStarted, book.DateFinished, rating)
+
+		return err
+	}
+
+	query = `
+		UPDATE book_reads
+		SET started_at = $1, finished_at = $2, rating = COALESCE($3, rating), updated_at = NOW()
+		WHERE id = $4
+	`
+
+	_, err = q.Exec(ctx, query, book.DateStarted, book.DateFinished, rating, latestId)
+
+	return err
+}
@@ -0,0 +1,206 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// revisionFields are the columns of a book saved by UpdateBook, whose changes
+// are recorded in its revisions. The contributors are recorded as authors.
+var revisionFields = []string{
+	"title", "author", "isbn", "description", "cover_url", "genre", "status", "rating", "notes", "review",
+	"date_added", "date_started", "date_finished", "series_id", "series_position", "page_count", "metadata_sources",
+}
+
+const revisionAuthorsField = "authors"
+
+// RevisionActor is who made a change: the user and the token they used.
+type RevisionActor struct {
+	UserID  string
+	TokenID *string
+}
+
+// RevisionChange is the value of a field before and after a change.
+type RevisionChange struct {
+	From json.RawMessage `json:"from"`
+	To   json.RawMessage `json:"to"`
+}
+
+// BookRevision is a change made to a book, numbered from 1 in the order the
+// changes were made.
+type BookRevision struct {
+	ID               string                    `json:"id" db:"id"`
+	BookID           string                    `json:"book_id" db:"book_id"`
+	Revision         int                       `json:"revision" db:"revision"`
+	UserID           string                    `json:"user_id" db:"user_id"`
+	TokenID          *string                   `json:"token_id,omitempty" db:"token_id"`
+	Changes          map[string]RevisionChange `json:"changes" db:"changes"`
+	RevertedRevision *int                      `json:"reverted_revision,omitempty" db:"reverted_revision"`
+	CreatedAt        time.Time                 `json:"created_at" db:"created_at"`
+}
+
+type BookRevisionStore interface {
+	GetBookRevisions(bookId string) ([]BookRevision, error)
+	RevertBook(bookId string, revision, version int, actor RevisionActor) (*Book, error)
+}
+
+type PostgresBookRevisionStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresBookRevisionStore(db *pgxpool.Pool) *PostgresBookRevisionStore {
+	return &PostgresBookRevisionStore{db}
+}
+
+// GetBookRevisions returns the revisions of the book, the latest first.
+func (s *PostgresBookRevisionStore) GetBookRevisions(bookId string) ([]BookRevision, error) {
+	query := "SELECT * FROM book_revisions WHERE book_id = $1 ORDER BY revision DESC"
+
+	rows, err := s.db.Query(context.Background(), query, bookId)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[BookRevision])
+}
+
+// RevertBook brings the book back to the state it was in before the revision,
+// undoing it and the revisions after it. The revert is itself recorded as a
+// new revision. It returns pgx.ErrNoRows when the book has no such revision
+// and ErrVersionConflict when the book is not at version anymore.
+func (s *PostgresBookRevisionStore) RevertBook(bookId string, revision, version int, actor RevisionActor) (*Book, error) {
+	ctx := context.Background()
+
+	trx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer trx.Rollback(ctx)
+
+	var state map[string]json.RawMessage
+	err = trx.QueryRow(ctx, "SELECT TO_JSONB(b) FROM books b WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", bookId).Scan(&state)
+	if err != nil {
+		return nil, err
+	}
+
+	var current int
+	if err := json.Unmarshal(state["version"], &current); err != nil {
+		return nil, err
+	}
+
+	if current != version {
+		return nil, ErrVersionConflict
+	}
+
+	rows, _ := trx.Query(ctx, "SELECT * FROM book_revisions WHERE book_id = $1 AND revision >= $2 ORDER BY revision DESC", bookId, revision)
+	revisions, err := pgx.CollectRows(rows, pgx.RowToStructByName[BookRevision])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(revisions) == 0 || revisions[len(revisions)-1].Revision != revision {
+		return nil, pgx.ErrNoRows
+	}
+
+	// Walking back from the latest revision leaves each field with its value
+	// before the first of them that changed it.
+	var authors json.RawMessage
+	for _, r := range revisions {
+		for field, change := range r.Changes {
+			if field == revisionAuthorsField {
+				authors = change.From
+				continue
+			}
+			state[field] = change.From
+		}
+	}
+
+	rows, _ = trx.Query(ctx, "SELECT * FROM JSONB_POPULATE_RECORD(NULL::books, $1)", state)
+	book, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[Book])
+	if err != nil {
+		return nil, err
+	}
+
+	if authors != nil {
+		book.Authors = []BookAuthor{}
+		if err := json.Unmarshal(authors, &book.Authors); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := updateBook(ctx, trx, book, actor, &revision); err != nil {
+		return nil, err
+	}
+
+	if book.Authors == nil {
+		book.Authors, err = getBookAuthors(ctx, trx, book.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return book, trx.Commit(ctx)
+}
+
+// bookSnapshot returns the fields of the book recorded in revisions, with its
+// contributors when withAuthors is set.
+func bookSnapshot(ctx context.Context, q querier, bookId string, withAuthors bool) (map[string]json.RawMessage, error) {
+	var snapshot map[string]json.RawMessage
+	err := q.QueryRow(ctx, "SELECT TO_JSONB(b) FROM books b WHERE id = $1", bookId).Scan(&snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]json.RawMessage, len(revisionFields)+1)
+	for _, field := range revisionFields {
+		fields[field] = snapshot[field]
+	}
+
+	if withAuthors {
+		authors, err := getBookAuthors(ctx, q, bookId)
+		if err != nil {
+			return nil, err
+		}
+
+		fields[revisionAuthorsField], err = json.Marshal(authors)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return fields, nil
+}
+
+// recordBookRevision adds the fields that differ between the snapshots taken
+// before and after a change as the next revision of the book. Nothing is
+// recorded when no field changed.
+func recordBookRevision(ctx context.Context, q querier, bookId string, before, after map[string]json.RawMessage, actor RevisionActor, revertedRevision *int) error {
+	changes := make(map[string]RevisionChange)
+	for field, value := range after {
+		if previous, ok := before[field]; ok && !bytes.Equal(previous, value) {
+			changes[field] = RevisionChange{From: previous, To: value}
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	// The book row is locked by the update, so revision numbers cannot clash.
+	query := `
+		INSERT INTO book_revisions (book_id, revision, user_id, token_id, changes, reverted_revision)
+		SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4, $5
+		FROM book_revisions
+		WHERE book_id = $1
+	`
+
+	_, err := q.Exec(ctx, query, bookId, actor.UserID, actor.TokenID, changes, revertedRevision)
+
+	return err
+}
@@ -3,38 +3,166 @@ package store
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const (
+	BookStatusToRead  = "to_read"
+	BookStatusReading = "reading"
+	BookStatusRead    = "read"
+)
+
+var BookStatuses = []string{BookStatusToRead, BookStatusReading, BookStatusRead}
+
+const (
+	BookOperationCreate = "create"
+	BookOperationUpdate = "update"
+	BookOperationDelete = "delete"
+)
+
 type Book struct {
-	ID           string     `json:"id" db:"id"`
-	UserId       string     `json:"user_id" db:"user_id"`
-	Title        string     `json:"title" db:"title"`
-	Author       string     `json:"author" db:"author"`
-	Isbn         *string    `json:"isbn,omitempty" db:"isbn"`
-	Description  *string    `json:"description,omitempty" db:"description"`
-	CoverUrl     *string    `json:"cover_url,omitempty" db:"cover_url"`
-	Genre        *string    `json:"genre,omitempty" db:"genre"`
-	Status       string     `json:"status" db:"status"`
-	Rating       byte       `json:"rating" db:"rating"`
-	Notes        *string    `json:"notes,omitempty" db:"notes"`
-	DateAdded    time.Time  `json:"date_added" db:"date_added"`
-	DateStarted  *time.Time `json:"date_started,omitempty" db:"date_started"`
-	DateFinished *time.Time `json:"date_finished,omitempty" db:"date_finished"`
-	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+	ID                string            `json:"id" db:"id"`
+	UserId            string            `json:"user_id" db:"user_id"`
+	Title             string            `json:"title" db:"title"`
+	Author            string            `json:"author" db:"author"`
+	Isbn              *string           `json:"isbn,omitempty" db:"isbn"`
+	Description       *string           `json:"description,omitempty" db:"description"`
+	CoverUrl          *string           `json:"cover_url,omitempty" db:"cover_url"`
+	Genre             *string           `json:"genre,omitempty" db:"genre"`
+	Status            string            `json:"status" db:"status"`
+	Rating            float64           `json:"rating" db:"rating"`
+	Notes             *string           `json:"notes,omitempty" db:"notes"`
+	Review            *string           `json:"review,omitempty" db:"review"`
+	ReviewHTML        *string           `json:"review_html,omitempty" db:"-"`
+	DateAdded         time.Time         `json:"date_added" db:"date_added"`
+	DateStarted       *time.Time        `json:"date_started,omitempty" db:"date_started"`
+	DateFinished      *time.Time        `json:"date_finished,omitempty" db:"date_finished"`
+	CreatedAt         time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at" db:"updated_at"`
+	SeriesID          *string           `json:"series_id,omitempty" db:"series_id"`
+	SeriesPosition    *float64          `json:"series_position,omitempty" db:"series_position"`
+	PageCount         *int              `json:"page_count,omitempty" db:"page_count"`
+	WishID            *string           `json:"wish_id,omitempty" db:"wish_id"`
+	BigBookID         *int64            `json:"bb_id,omitempty" db:"big_book_id"`
+	MetadataSources   map[string]string `json:"metadata_sources" db:"metadata_sources"`
+	MetadataCheckedAt *time.Time        `json:"metadata_checked_at,omitempty" db:"metadata_checked_at"`
+	CoverID           *string           `json:"cover_id,omitempty" db:"cover_id"`
+	CoverFetchedAt    *time.Time        `json:"-" db:"cover_fetched_at"`
+	DeletedAt         *time.Time        `json:"deleted_at,omitempty" db:"deleted_at"`
+	Version           int               `json:"version" db:"version"`
+	Authors           []BookAuthor      `json:"authors,omitempty" db:"-"`
+	Progress          *float64          `json:"progress,omitempty" db:"-"`
+	Loaned            bool              `json:"loaned" db:"-"`
+	Borrower          *string           `json:"borrower,omitempty" db:"-"`
+}
+
+// BookStatusEvent is a status change in the history of a book. FromStatus is
+// nil for the status the book was added with.
+type BookStatusEvent struct {
+	ID         string    `json:"id" db:"id"`
+	BookID     string    `json:"book_id" db:"book_id"`
+	UserID     string    `json:"user_id" db:"user_id"`
+	FromStatus *string   `json:"from_status" db:"from_status"`
+	ToStatus   string    `json:"to_status" db:"to_status"`
+	ChangedAt  time.Time `json:"changed_at" db:"changed_at"`
+}
+
+type BookFilters struct {
+	ShelfId  *string
+	Tags     []string
+	TagMode  string
+	Format   *string
+	Location *string
+	Status   *string
+	Ids      []string
+}
+
+// BookOperation is a change of a batch: the book to create, the book to
+// save, or the id of the book to delete.
+type BookOperation struct {
+	Type string
+	Book *Book
+	ID   string
+}
+
+// BookBulkChanges are the changes applied to many books at once, on top of
+// saving them.
+type BookBulkChanges struct {
+	AddTags         []string
+	RemoveTags      []string
+	AddToShelf      *string
+	RemoveFromShelf *string
+}
+
+// filterQuery accumulates the conditions and positional arguments of a
+// filtered listing query.
+type filterQuery struct {
+	conditions []string
+	args       []any
+	orderBy    []string
+}
+
+func (q *filterQuery) arg(value any) string {
+	q.args = append(q.args, value)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+func (q *filterQuery) where() string {
+	return strings.Join(q.conditions, " AND ")
+}
+
+func (f *BookFilters) query(userId string) *filterQuery {
+	q := &filterQuery{}
+	q.conditions = append(q.conditions, "b.user_id = "+q.arg(userId), "b.deleted_at IS NULL")
+
+	if f.ShelfId != nil {
+		shelf := q.arg(*f.ShelfId)
+		q.conditions = append(q.conditions, "EXISTS (SELECT 1 FROM shelf_books sb WHERE sb.book_id = b.id AND sb.shelf_id = "+shelf+")")
+		q.orderBy = append(q.orderBy, "(SELECT sb.position FROM shelf_books sb WHERE sb.book_id = b.id AND sb.shelf_id = "+shelf+")")
+	}
+
+	if len(f.Tags) > 0 {
+		q.conditions = append(q.conditions, tagsCondition(bookTagsTable, "b", f.TagMode, q.arg(f.Tags), q.arg(len(f.Tags))))
+	}
+
+	if f.Format != nil {
+		q.conditions = append(q.conditions, "EXISTS (SELECT 1 FROM copies c WHERE c.book_id = b.id AND c.format = "+q.arg(*f.Format)+")")
+	}
+
+	if f.Location != nil {
+		q.conditions = append(q.conditions, "EXISTS (SELECT 1 FROM copies c WHERE c.book_id = b.id AND c.location ILIKE '%' || "+q.arg(escapeLike(*f.Location))+" || '%')")
+	}
+
+	if f.Status != nil {
+		q.conditions = append(q.conditions, "b.status = "+q.arg(*f.Status))
+	}
+
+	if f.Ids != nil {
+		q.conditions = append(q.conditions, "b.id = ANY("+q.arg(f.Ids)+"::UUID[])")
+	}
+
+	q.orderBy = append(q.orderBy, "b.created_at DESC")
+
+	return q
 }
 
 type BookStore interface {
 	CreateBook(book *Book) error
-	GetBooks(userId string, page, take int) ([]Book, error)
+	GetBooks(userId string, filters BookFilters, page, take int) ([]Book, error)
+	GetUserBooks(userId string) ([]Book, error)
+	GetBookIdsByIsbn(userId string, isbns []string) (map[string]string, error)
 	GetBookById(id string) (*Book, error)
-	UpdateBook(book *Book) error
+	UpdateBook(book *Book, actor RevisionActor) error
 	DeleteBook(id string) error
-	GetBooksCount(userId string) (int, error)
+	GetBooksCount(userId string, filters BookFilters) (int, error)
+	GetBookStatusHistory(bookId string) ([]BookStatusEvent, error)
+	ApplyBookOperations(operations []BookOperation, atomic bool, actor RevisionActor) ([]error, error)
+	BulkUpdateBooks(userId string, bookIds []string, changed []Book, changes BookBulkChanges, actor RevisionActor) error
 }
 
 type PostgresBookStore struct {
@@ -46,14 +174,31 @@ func NewPostgresBookStore(db *pgxpool.Pool) *PostgresBookStore {
 }
 
 func (s *PostgresBookStore) CreateBook(book *Book) error {
+	ctx := context.Background()
+
+	trx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer trx.Rollback(ctx)
+
+	if err := createBook(ctx, trx, book); err != nil {
+		return err
+	}
+
+	return trx.Commit(ctx)
+}
+
+func createBook(ctx context.Context, q querier, book *Book) error {
 	query := `
-		INSERT INTO books (user_id, title, author, isbn, description, cover_url, genre, status, rating, notes, date_added, date_started, date_finished)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
-		RETURNING id, created_at, updated_at
+		INSERT INTO books (user_id, title, author, isbn, description, cover_url, genre, status, rating, notes, date_added, date_started, date_finished, series_id, series_position, page_count, review, big_book_id, metadata_sources)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, COALESCE($19, '{}'::JSONB))
+		RETURNING id, created_at, updated_at, version
 	`
 
-	err := s.db.QueryRow(
-		context.Background(), query,
+	err := q.QueryRow(
+		ctx, query,
 		book.UserId,
 		book.Title,
 		book.Author,
@@ -67,29 +212,96 @@ func (s *PostgresBookStore) CreateBook(book *Book) error {
 		book.DateAdded,
 		book.DateStarted,
 		book.DateFinished,
-	).Scan(&book.ID, &book.CreatedAt, &book.UpdatedAt)
+		book.SeriesID,
+		book.SeriesPosition,
+		book.PageCount,
+		book.Review,
+		book.BigBookID,
+		book.MetadataSources,
+	).Scan(&book.ID, &book.CreatedAt, &book.UpdatedAt, &book.Version)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if len(book.Authors) > 0 {
+		if err := setBookAuthors(ctx, q, book.ID, book.Authors); err != nil {
+			return err
+		}
+	}
+
+	if err := recordStatusChange(ctx, q, book.ID, book.UserId, nil, book.Status); err != nil {
+		return err
+	}
+
+	return syncLatestReadWithBook(ctx, q, book)
 }
 
-func (s *PostgresBookStore) GetBooks(userId string, page, take int) ([]Book, error) {
-	query := "SELECT * FROM books WHERE user_id = $1 LIMIT $2 OFFSET $3 ORDER BY created_at DESC"
+func (s *PostgresBookStore) GetBooks(userId string, filters BookFilters, page, take int) ([]Book, error) {
+	q := filters.query(userId)
+	query := fmt.Sprintf(
+		"SELECT b.* FROM books b WHERE %s ORDER BY %s LIMIT %s OFFSET %s",
+		q.where(), strings.Join(q.orderBy, ", "), q.arg(take), q.arg((page-1)*take),
+	)
 
-	rows, _ := s.db.Query(context.Background(), query, userId, take, (page-1)*take)
+	rows, _ := s.db.Query(context.Background(), query, q.args...)
 	books, err := pgx.CollectRows(rows, pgx.RowToStructByName[Book])
 	if err != nil {
 		return nil, err
 	}
 
+	if err := fillProgress(context.Background(), s.db, books); err != nil {
+		return nil, err
+	}
+
+	if err := fillLoans(context.Background(), s.db, books); err != nil {
+		return nil, err
+	}
+
 	return books, nil
 }
 
+func (s *PostgresBookStore) GetUserBooks(userId string) ([]Book, error) {
+	query := "SELECT * FROM books WHERE user_id = $1 AND deleted_at IS NULL ORDER BY created_at DESC"
+
+	rows, err := s.db.Query(context.Background(), query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	books, err := pgx.CollectRows(rows, pgx.RowToStructByName[Book])
+	if err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+// GetBookIdsByIsbn returns the ids of the books of the user having one of
+// the ISBNs, by ISBN.
+func (s *PostgresBookStore) GetBookIdsByIsbn(userId string, isbns []string) (map[string]string, error) {
+	query := "SELECT isbn, id::TEXT FROM books WHERE user_id = $1 AND isbn = ANY($2) AND deleted_at IS NULL"
+
+	rows, err := s.db.Query(context.Background(), query, userId, isbns)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[string]string)
+	for rows.Next() {
+		var isbn, id string
+		if err := rows.Scan(&isbn, &id); err != nil {
+			return nil, err
+		}
+		ids[isbn] = id
+	}
+
+	return ids, rows.Err()
+}
+
 func (s *PostgresBookStore) GetBookById(id string) (*Book, error) {
 	var book *Book
-	const query = "SELECT * FROM books WHERE id = $1"
+	const query = "SELECT * FROM books WHERE id = $1 AND deleted_at IS NULL"
 
 	rows, _ := s.db.Query(context.Background(), query, id)
 	book, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[Book])
@@ -101,19 +313,76 @@ func (s *PostgresBookStore) GetBookById(id string) (*Book, error) {
 		return nil, err
 	}
 
-	return book, nil
+	book.Authors, err = getBookAuthors(context.Background(), s.db, book.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	books := []Book{*book}
+	if err := fillProgress(context.Background(), s.db, books); err != nil {
+		return nil, err
+	}
+
+	if err := fillLoans(context.Background(), s.db, books); err != nil {
+		return nil, err
+	}
+
+	return &books[0], nil
 }
 
-func (s *PostgresBookStore) UpdateBook(book *Book) error {
+// UpdateBook saves the book. Its contributors are replaced as well unless
+// book.Authors is nil, its dates and rating are recorded in its latest
+// reading cycle and a status change is added to its history. The changed
+// fields are recorded as a revision made by actor. A new cover_url is
+// downloaded again. ErrVersionConflict is returned when the book was changed
+// since book was read.
+func (s *PostgresBookStore) UpdateBook(book *Book, actor RevisionActor) error {
+	ctx := context.Background()
+
+	trx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer trx.Rollback(ctx)
+
+	if err := updateBook(ctx, trx, book, actor, nil); err != nil {
+		return err
+	}
+
+	return trx.Commit(ctx)
+}
+
+// updateBook saves the book and records its revision, which reverts the
+// book to the state before revertedRevision when set.
+func updateBook(ctx context.Context, q querier, book *Book, actor RevisionActor, revertedRevision *int) error {
+	var (
+		previousStatus string
+		version        int
+	)
+	err := q.QueryRow(ctx, "SELECT status, version FROM books WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", book.ID).Scan(&previousStatus, &version)
+	if err != nil {
+		return err
+	}
+
+	if version != book.Version {
+		return ErrVersionConflict
+	}
+
+	before, err := bookSnapshot(ctx, q, book.ID, book.Authors != nil)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE books
-		SET title = $1, author = $2, isbn = $3, description = $4, cover_url = $5, genre = $6, status = $7, rating = $8, notes = $9, date_added = $10, date_started = $11, date_finished = $12, updated_at = NOW()
-		WHERE id = $13
-		RETURNING updated_at
+		SET title = $1, author = $2, isbn = $3, description = $4, cover_url = $5, genre = $6, status = $7, rating = $8, notes = $9, date_added = $10, date_started = $11, date_finished = $12, series_id = $13, series_position = $14, page_count = $15, review = $16, metadata_sources = COALESCE($17, '{}'::JSONB), cover_fetched_at = CASE WHEN cover_url IS DISTINCT FROM $5 THEN NULL ELSE cover_fetched_at END, updated_at = NOW()
+		WHERE id = $18
+		RETURNING updated_at, version
 	`
 
-	err := s.db.QueryRow(
-		context.Background(), query,
+	err = q.QueryRow(
+		ctx, query,
 		book.Title,
 		book.Author,
 		book.Isbn,
@@ -126,18 +395,48 @@ func (s *PostgresBookStore) UpdateBook(book *Book) error {
 		book.DateAdded,
 		book.DateStarted,
 		book.DateFinished,
+		book.SeriesID,
+		book.SeriesPosition,
+		book.PageCount,
+		book.Review,
+		book.MetadataSources,
 		book.ID,
-	).Scan(&book.UpdatedAt)
+	).Scan(&book.UpdatedAt, &book.Version)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if book.Authors != nil {
+		if err := setBookAuthors(ctx, q, book.ID, book.Authors); err != nil {
+			return err
+		}
+	}
+
+	if err := recordStatusChange(ctx, q, book.ID, book.UserId, &previousStatus, book.Status); err != nil {
+		return err
+	}
+
+	if err := syncLatestReadWithBook(ctx, q, book); err != nil {
+		return err
+	}
+
+	after, err := bookSnapshot(ctx, q, book.ID, book.Authors != nil)
+	if err != nil {
+		return err
+	}
+
+	return recordBookRevision(ctx, q, book.ID, before, after, actor, revertedRevision)
 }
 
+// DeleteBook moves the book to the trash, from which it can be restored until
+// it is purged.
 func (s *PostgresBookStore) DeleteBook(id string) error {
-	query := "DELETE FROM books WHERE id = $1"
-	commandTag, err := s.db.Exec(context.Background(), query, id)
+	return deleteBook(context.Background(), s.db, id)
+}
+
+func deleteBook(ctx context.Context, q querier, id string) error {
+	query := "UPDATE books SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL"
+	commandTag, err := q.Exec(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -149,11 +448,130 @@ func (s *PostgresBookStore) DeleteBook(id string) error {
 	return nil
 }
 
-func (s *PostgresBookStore) GetBooksCount(userId string) (int, error) {
+// ApplyBookOperations runs the operations in a single transaction and
+// returns the error of each of them, nil for those that succeeded. In atomic
+// mode the first failing operation ends the batch and nothing is saved.
+// Otherwise each operation runs in its own savepoint, so that only the failing
+// ones are rolled back.
+func (s *PostgresBookStore) ApplyBookOperations(operations []BookOperation, atomic bool, actor RevisionActor) ([]error, error) {
+	ctx := context.Background()
+	errs := make([]error, len(operations))
+
+	trx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer trx.Rollback(ctx)
+
+	for i, operation := range operations {
+		if atomic {
+			if errs[i] = applyBookOperation(ctx, trx, operation, actor); errs[i] != nil {
+				return errs, nil
+			}
+			continue
+		}
+
+		savepoint, err := trx.Begin(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if errs[i] = applyBookOperation(ctx, savepoint, operation, actor); errs[i] != nil {
+			if err := savepoint.Rollback(ctx); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err := savepoint.Commit(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return errs, trx.Commit(ctx)
+}
+
+func applyBookOperation(ctx context.Context, q querier, operation BookOperation, actor RevisionActor) error {
+	switch operation.Type {
+	case BookOperationCreate:
+		return createBook(ctx, q, operation.Book)
+	case BookOperationUpdate:
+		return updateBook(ctx, q, operation.Book, actor, nil)
+	case BookOperationDelete:
+		return deleteBook(ctx, q, operation.ID)
+	default:
+		return fmt.Errorf("unknown book operation %q", operation.Type)
+	}
+}
+
+// BulkUpdateBooks applies the tag and shelf changes to the books of the user
+// and saves the changed ones, all or nothing.
+func (s *PostgresBookStore) BulkUpdateBooks(userId string, bookIds []string, changed []Book, changes BookBulkChanges, actor RevisionActor) error {
+	ctx := context.Background()
+
+	trx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer trx.Rollback(ctx)
+
+	for i := range changed {
+		if err := updateBook(ctx, trx, &changed[i], actor, nil); err != nil {
+			return err
+		}
+	}
+
+	if len(changes.AddTags) > 0 {
+		tagIds, err := upsertTags(ctx, trx, userId, changes.AddTags)
+		if err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO book_tags (book_id, tag_id)
+			SELECT b.id, t.id FROM UNNEST($1::UUID[]) AS b(id) CROSS JOIN UNNEST($2::UUID[]) AS t(id)
+			ON CONFLICT DO NOTHING
+		`
+		if _, err := trx.Exec(ctx, query, bookIds, tagIds); err != nil {
+			return err
+		}
+	}
+
+	if len(changes.RemoveTags) > 0 {
+		query := `
+			DELETE FROM book_tags bt
+			USING tags t
+			WHERE bt.tag_id = t.id AND t.user_id = $1 AND t.name = ANY($2) AND bt.book_id = ANY($3::UUID[])
+		`
+		if _, err := trx.Exec(ctx, query, userId, changes.RemoveTags, bookIds); err != nil {
+			return err
+		}
+	}
+
+	if changes.AddToShelf != nil {
+		if _, err := addShelfBooks(ctx, trx, *changes.AddToShelf, userId, bookIds); err != nil {
+			return err
+		}
+	}
+
+	if changes.RemoveFromShelf != nil {
+		query := "DELETE FROM shelf_books WHERE shelf_id = $1 AND book_id = ANY($2::UUID[])"
+		if _, err := trx.Exec(ctx, query, *changes.RemoveFromShelf, bookIds); err != nil {
+			return err
+		}
+	}
+
+	return trx.Commit(ctx)
+}
+
+func (s *PostgresBookStore) GetBooksCount(userId string, filters BookFilters) (int, error) {
 	var count int
 
-	query := "SELECT COUNT(*) FROM books WHERE user_id = $1"
-	err := s.db.QueryRow(context.Background(), query, userId).Scan(&count)
+	q := filters.query(userId)
+	query := "SELECT COUNT(*) FROM books b WHERE " + q.where()
+	err := s.db.QueryRow(context.Background(), query, q.args...).Scan(&count)
 
 	if err != nil {
 		return 0, err
@@ -161,3 +579,36 @@ func (s *PostgresBookStore) GetBooksCount(userId string) (int, error) {
 
 	return count, nil
 }
+
+func (s *PostgresBookStore) GetBookStatusHistory(bookId string) ([]BookStatusEvent, error) {
+	query := "SELECT * FROM book_status_history WHERE book_id = $1 ORDER BY changed_at, id"
+
+	rows, err := s.db.Query(context.Background(), query, bookId)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := pgx.CollectRows(rows, pgx.RowToStructByName[BookStatusEvent])
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// recordStatusChange adds the move from one status to another to the history
+// of the book. Nothing is recorded when the status did not change.
+func recordStatusChange(ctx context.Context, q querier, bookId, userId string, from *string, to string) error {
+	if from != nil && *from == to {
+		return nil
+	}
+
+	query := `
+		INSERT INTO book_status_history (book_id, user_id, from_status, to_status)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	_, err := q.Exec(ctx, query, bookId, userId, from, to)
+
+	return err
+}
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	CopyFormatHardcover = "hardcover"
+	CopyFormatPaperback = "paperback"
+	CopyFormatEbook     = "ebook"
+	CopyFormatAudiobook = "audiobook"
+
+	CopyConditionNew     = "new"
+	CopyConditionLikeNew = "like_new"
+	CopyConditionGood    = "good"
+	CopyConditionFair    = "fair"
+	CopyConditionPoor    = "poor"
+)
+
+var (
+	CopyFormats    = []string{CopyFormatHardcover, CopyFormatPaperback, CopyFormatEbook, CopyFormatAudiobook}
+	CopyConditions = []string{CopyConditionNew, CopyConditionLikeNew, CopyConditionGood, CopyConditionFair, CopyConditionPoor}
+)
+
+// Copy is one physical or digital copy of a book owned by the user.
+type Copy struct {
+	ID          string     `json:"id" db:"id"`
+	BookID      string     `json:"book_id" db:"book_id"`
+	UserID      string     `json:"user_id" db:"user_id"`
+	Format      string     `json:"format" db:"format"`
+	Edition     *string    `json:"edition,omitempty" db:"edition"`
+	Isbn        *string    `json:"isbn,omitempty" db:"isbn"`
+	PurchasedAt *time.Time `json:"purchased_at,omitempty" db:"purchased_at"`
+	Price       *float64   `json:"price,omitempty" db:"price"`
+	Currency    *string    `json:"currency,omitempty" db:"currency"`
+	Condition   *string    `json:"condition,omitempty" db:"condition"`
+	Location    *string    `json:"location,omitempty" db:"location"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type CopyStore interface {
+	CreateCopy(bookCopy *Copy) error
+	GetCopyById(id string) (*Copy, error)
+	GetBookCopies(bookId string) ([]Copy, error)
+	GetUserCopies(userId string) ([]Copy, error)
+	UpdateCopy(bookCopy *Copy) error
+	DeleteCopy(id string) error
+}
+
+type PostgresCopyStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresCopyStore(db *pgxpool.Pool) *PostgresCopyStore {
+	return &PostgresCopyStore{db}
+}
+
+func (s *PostgresCopyStore) CreateCopy(bookCopy *Copy) error {
+	query := `
+		INSERT INTO copies (book_id, user_id, format, edition, isbn, purchased_at, price, currency, condition, location)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := s.db.QueryRow(
+		context.Background(), query,
+		bookCopy.BookID,
+		bookCopy.UserID,
+		bookCopy.Format,
+		bookCopy.Edition,
+		bookCopy.Isbn,
+		bookCopy.PurchasedAt,
+		bookCopy.Price,
+		bookCopy.Currency,
+		bookCopy.Condition,
+		bookCopy.Location,
+	).Scan(&bookCopy.ID, &bookCopy.CreatedAt, &bookCopy.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *PostgresCopyStore) GetCopyById(id string) (*Copy, error) {
+	query := "SELECT * FROM copies WHERE id = $1"
+
+	rows, _ := s.db.Query(context.Background(), query, id)
+	bookCopy, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[Copy])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return bookCopy, nil
+}
+
+func (s *PostgresCopyStore) GetBookCopies(bookId string) ([]Copy, error) {
+	query := "SELECT * FROM copies WHERE book_id = $1 ORDER BY created_at"
+
+	rows, err := s.db.Query(context.Background(), query, bookId)
+	if err != nil {
+		return nil, err
+	}
+
+	copies, err := pgx.CollectRows(rows, pgx.RowToStructByName[Copy])
+	if err != nil {
+		return nil, err
+	}
+
+	return copies, nil
+}
+
+func (s *PostgresCopyStore) GetUserCopies(userId string) ([]Copy, error) {
+	query := "SELECT c.* FROM copies c JOIN books b ON b.id = c.book_id WHERE c.user_id = $1 AND b.deleted_at IS NULL ORDER BY c.created_at"
+
+	rows, err := s.db.Query(context.Background(), query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	copies, err := pgx.CollectRows(rows, pgx.RowToStructByName[Copy])
+	if err != nil {
+		return nil, err
+	}
+
+	return copies, nil
+}
+
+func (s *PostgresCopyStore) UpdateCopy(bookCopy *Copy) error {
+	query := `
+		UPDATE copies
+		SET format = $1, edition = $2, isbn = $3, purchased_at = $4, price = $5, currency = $6, condition = $7, location = $8, updated_at = NOW()
+		WHERE id = $9
+		RETURNING updated_at
+	`
+
+	err := s.db.QueryRow(
+		context.Background(), query,
+		bookCopy.Format,
+		bookCopy.Edition,
+		bookCopy.Isbn,
+		bookCopy.PurchasedAt,
+		bookCopy.Price,
+		bookCopy.Currency,
+		bookCopy.Condition,
+		bookCopy.Location,
+		bookCopy.ID,
+	).Scan(&bookCopy.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *PostgresCopyStore) DeleteCopy(id string) error {
+	query := "DELETE FROM copies WHERE id = $1"
+
+	commandTag, err := s.db.Exec(context.Background(), query, id)
+	if err != nil {
+		return err
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Cover is an image stored for a book. Its files are saved under its hash, so
+// books sharing the same cover share its files.
+type Cover struct {
+	ID          string    `json:"id" db:"id"`
+	BookID      string    `json:"book_id" db:"book_id"`
+	UserID      string    `json:"user_id" db:"user_id"`
+	SourceUrl   *string   `json:"source_url,omitempty" db:"source_url"`
+	Hash        string    `json:"hash" db:"hash"`
+	ContentType string    `json:"content_type" db:"content_type"`
+	Width       int       `json:"width" db:"width"`
+	Height      int       `json:"height" db:"height"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// CoverFetch is a book whose cover_url is still to be downloaded.
+type CoverFetch struct {
+	BookID   string `db:"id"`
+	UserID   string `db:"user_id"`
+	CoverUrl string `db:"cover_url"`
+}
+
+type CoverStore interface {
+	SetBookCover(cover *Cover) ([]string, error)
+	GetCoverById(id string) (*Cover, error)
+	RemoveBookCover(bookId string) ([]string, error)
+	GetCoversToFetch(failedBefore time.Time, limit int) ([]CoverFetch, error)
+	MarkCoverFetched(bookId string) error
+	IsCoverHashUsed(hash string) (bool, error)
+}
+
+type PostgresCoverStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresCoverStore(db *pgxpool.Pool) *PostgresCoverStore {
+	return &PostgresCoverStore{db}
+}
+
+// SetBookCover saves the cover and makes it the cover of its book, replacing
+// the previous one. A downloaded cover is only set while the book still points
+// at its source URL and has no uploaded cover, so that it never overwrites a
+// change made during the download; pgx.ErrNoRows is returned otherwise. It
+// returns the hashes of the replaced covers no other book uses anymore, whose
+// files can be deleted.
+func (s *PostgresCoverStore) SetBookCover(cover *Cover) ([]string, error) {
+	ctx := context.Background()
+
+	trx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer trx.Rollback(ctx)
+
+	query := `
+		INSERT INTO covers (book_id, user_id, source_url, hash, content_type, width, height)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+
+	err = trx.QueryRow(
+		ctx, query,
+		cover.BookID,
+		cover.UserID,
+		cover.SourceUrl,
+		cover.Hash,
+		cover.ContentType,
+		cover.Width,
+		cover.Height,
+	).Scan(&cover.ID, &cover.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	bookQuery := `
+		UPDATE books
+		SET cover_id = $1, cover_fetched_at = CASE WHEN $3::TEXT IS NULL THEN cover_fetched_at ELSE NOW() END
+		WHERE id = $2 AND (
+			$3::TEXT IS NULL
+			OR (cover_url = $3 AND NOT EXISTS (SELECT 1 FROM covers c WHERE c.id = books.cover_id AND c.source_url IS NULL))
+		)
+	`
+
+	commandTag, err := trx.Exec(ctx, bookQuery, cover.ID, cover.BookID, cover.SourceUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return nil, pgx.ErrNoRows
+	}
+
+	hashes, err := deleteBookCovers(ctx, trx, cover.BookID, &cover.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return hashes, trx.Commit(ctx)
+}
+
+func (s *PostgresCoverStore) GetCoverById(id string) (*Cover, error) {
+	query := "SELECT c.* FROM covers c JOIN books b ON b.id = c.book_id WHERE c.id = $1 AND b.deleted_at IS NULL"
+
+	rows, _ := s.db.Query(context.Background(), query, id)
+	cover, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[Cover])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cover, nil
+}
+
+// RemoveBookCover deletes the covers of the book, which falls back to its
+// cover_url until it is downloaded again. It returns the hashes no other book
+// uses anymore, or pgx.ErrNoRows when the book has no cover.
+func (s *PostgresCoverStore) RemoveBookCover(bookId string) ([]string, error) {
+	ctx := context.Background()
+
+	trx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer trx.Rollback(ctx)
+
+	commandTag, err := trx.Exec(ctx, "UPDATE books SET cover_id = NULL, cover_fetched_at = NULL WHERE id = $1 AND cover_id IS NOT NULL", bookId)
+	if err != nil {
+		return nil, err
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return nil, pgx.ErrNoRows
+	}
+
+	hashes, err := deleteBookCovers(ctx, trx, bookId, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return hashes, trx.Commit(ctx)
+}
+
+// deleteBookCovers deletes the covers of the book other than keep, and
+// returns the deleted hashes no remaining cover uses.
+func deleteBookCovers(ctx context.Context, trx pgx.Tx, bookId string, keep *string) ([]string, error) {
+	// The select sees the covers as they were before the delete.
+	query := `
+		WITH deleted AS (
+			DELETE FROM covers
+			WHERE book_id = $1 AND ($2::UUID IS NULL OR id <> $2)
+			RETURNING id, hash
+		)
+		SELECT DISTINCT d.hash
+		FROM deleted d
+		WHERE NOT EXISTS (
+			SELECT 1 FROM covers c
+			WHERE c.hash = d.hash AND c.id NOT IN (SELECT id FROM deleted)
+		)
+	`
+
+	rows, err := trx.Query(ctx, query, bookId, keep)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// GetCoversToFetch returns the books with a cover_url and no cover downloaded
+// from it, leaving out those with an uploaded cover and those whose last
+// download failed after failedBefore.
+func (s *PostgresCoverStore) GetCoversToFetch(failedBefore time.Time, limit int) ([]CoverFetch, error) {
+	query := `
+		SELECT b.id, b.user_id, b.cover_url
+		FROM books b
+		LEFT JOIN covers c ON c.id = b.cover_id
+		WHERE b.cover_url IS NOT NULL
+			AND b.deleted_at IS NULL
+			AND (c.id IS NULL OR (c.source_url IS NOT NULL AND c.source_url <> b.cover_url))
+			AND (b.cover_fetched_at IS NULL OR b.cover_fetched_at < $1)
+		ORDER BY b.cover_fetched_at NULLS FIRST
+		LIMIT $2
+	`
+
+	rows, err := s.db.Query(context.Background(), query, failedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[CoverFetch])
+}
+
+// MarkCoverFetched records a failed download of the cover_url of the book.
+func (s *PostgresCoverStore) MarkCoverFetched(bookId string) error {
+	_, err := s.db.Exec(context.Background(), "UPDATE books SET cover_fetched_at = NOW() WHERE id = $1", bookId)
+	return err
+}
+
+// IsCoverHashUsed tells whether a cover still uses the files stored under
+// hash.
+func (s *PostgresCoverStore) IsCoverHashUsed(hash string) (bool, error) {
+	var used bool
+	err := s.db.QueryRow(context.Background(), "SELECT EXISTS (SELECT 1 FROM covers WHERE hash = $1)", hash).Scan(&used)
+
+	return used, err
+}
@@ -2,12 +2,27 @@ package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// querier is implemented by both the pool and transactions so that store
+// helpers can run inside or outside of a transaction.
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// ErrVersionConflict is returned when saving a row that was changed since it
+// was read, which its version tells.
+var ErrVersionConflict = errors.New("version conflict")
+
 func Open() (*pgxpool.Pool, error) {
 	databaseUrl := os.Getenv("DATABASE_URL")
 	conn, err := pgxpool.New(context.Background(), databaseUrl)
@@ -17,3 +32,9 @@ func Open() (*pgxpool.Pool, error) {
 
 	return conn, nil
 }
+
+// IsUniqueViolation reports whether err was raised by a UNIQUE constraint.
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
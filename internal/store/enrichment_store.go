@@ -0,0 +1,303 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	EnrichmentTargetBook = "book"
+	EnrichmentTargetWish = "wish"
+
+	JobStatusPending = "pending"
+	JobStatusRunning = "running"
+	JobStatusDone    = "done"
+	JobStatusFailed  = "failed"
+
+	// MetadataSourceUser is the source of the fields set by the user, which
+	// enrichment never overwrites.
+	MetadataSourceUser = "user"
+)
+
+// enrichmentTables maps the enrichment targets to their table.
+var enrichmentTables = map[string]string{
+	EnrichmentTargetBook: "books",
+	EnrichmentTargetWish: "wishlists",
+}
+
+type EnrichmentJob struct {
+	ID         string    `json:"id" db:"id"`
+	TargetType string    `json:"target_type" db:"target_type"`
+	TargetID   string    `json:"target_id" db:"target_id"`
+	Status     string    `json:"status" db:"status"`
+	Attempts   int       `json:"attempts" db:"attempts"`
+	RunAt      time.Time `json:"run_at" db:"run_at"`
+	LastError  *string   `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// EnrichmentTarget is what identifies a book or a wish in the external
+// catalogs.
+type EnrichmentTarget struct {
+	Isbn      *string `db:"isbn"`
+	BigBookID *int64  `db:"big_book_id"`
+}
+
+// EnrichedField is a value found for a column of a book or a wish by the
+// Source provider.
+type EnrichedField struct {
+	Column string
+	Value  any
+	Source string
+}
+
+type EnrichmentStore interface {
+	ScheduleEnrichments(staleBefore time.Time, limit int) (int, error)
+	ClaimEnrichmentJobs(limit int) ([]EnrichmentJob, error)
+	ResetRunningEnrichmentJobs() (int, error)
+	CompleteEnrichmentJob(job *EnrichmentJob) error
+	RetryEnrichmentJob(job *EnrichmentJob, lastError string, runAt time.Time) error
+	FailEnrichmentJob(job *EnrichmentJob, lastError string) error
+	GetEnrichmentTarget(targetType, id string) (*EnrichmentTarget, error)
+	ApplyEnrichment(targetType, id string, fields []EnrichedField) error
+}
+
+type PostgresEnrichmentStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresEnrichmentStore(db *pgxpool.Pool) *PostgresEnrichmentStore {
+	return &PostgresEnrichmentStore{db}
+}
+
+// WithMetadataSource returns the sources with the fields recorded as coming
+// from source.
+func WithMetadataSource(sources map[string]string, source string, fields ...string) map[string]string {
+	if sources == nil {
+		sources = make(map[string]string)
+	}
+
+	for _, field := range fields {
+		sources[field] = source
+	}
+
+	return sources
+}
+
+// ScheduleEnrichments queues the books and wishes with an ISBN or a Big Book
+// id that were never checked and miss some fields, or that were last checked
+// before staleBefore and have fields filled by a provider. It returns the
+// number of jobs queued.
+func (s *PostgresEnrichmentStore) ScheduleEnrichments(staleBefore time.Time, limit int) (int, error) {
+	queries := map[string]string{
+		EnrichmentTargetBook: "t.cover_url IS NULL OR t.description IS NULL OR t.page_count IS NULL OR t.big_book_id IS NULL",
+		EnrichmentTargetWish: "t.cover_url IS NULL OR t.author IS NULL OR t.isbn IS NULL OR t.big_book_id IS NULL",
+	}
+
+	scheduled := 0
+	for _, targetType := range []string{EnrichmentTargetBook, EnrichmentTargetWish} {
+		query := fmt.Sprintf(`
+			INSERT INTO enrichment_jobs (target_type, target_id)
+			SELECT $1, t.id
+			FROM %s t
+			WHERE t.deleted_at IS NULL
+				AND (t.isbn IS NOT NULL OR t.big_book_id IS NOT NULL)
+				AND (
+					(t.metadata_checked_at IS NULL AND (%s))
+					OR (t.metadata_checked_at < $2 AND t.metadata_sources <> '{}')
+				)
+				AND NOT EXISTS (
+					SELECT 1 FROM enrichment_jobs j
+					WHERE j.target_type = $1 AND j.target_id = t.id AND j.status IN ('pending', 'running')
+				)
+			ORDER BY t.metadata_checked_at NULLS FIRST
+			LIMIT $3
+			ON CONFLICT DO NOTHING
+		`, enrichmentTables[targetType], queries[targetType])
+
+		commandTag, err := s.db.Exec(context.Background(), query, targetType, staleBefore, limit)
+		if err != nil {
+			return scheduled, err
+		}
+
+		scheduled += int(commandTag.RowsAffected())
+	}
+
+	return scheduled, nil
+}
+
+// ClaimEnrichmentJobs marks up to limit pending jobs due to run as running
+// and returns them. Jobs claimed by another worker are skipped.
+func (s *PostgresEnrichmentStore) ClaimEnrichmentJobs(limit int) ([]EnrichmentJob, error) {
+	query := `
+		UPDATE enrichment_jobs
+		SET status = 'running', attempts = attempts + 1, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM enrichment_jobs
+			WHERE status = 'pending' AND run_at <= NOW()
+			ORDER BY run_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *
+	`
+
+	rows, err := s.db.Query(context.Background(), query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs, err := pgx.CollectRows(rows, pgx.RowToStructByName[EnrichmentJob])
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// ResetRunningEnrichmentJobs puts back in the queue the jobs left running
+// when the application stopped.
+func (s *PostgresEnrichmentStore) ResetRunningEnrichmentJobs() (int, error) {
+	query := "UPDATE enrichment_jobs SET status = 'pending', updated_at = NOW() WHERE status = 'running'"
+
+	commandTag, err := s.db.Exec(context.Background(), query)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(commandTag.RowsAffected()), nil
+}
+
+func (s *PostgresEnrichmentStore) CompleteEnrichmentJob(job *EnrichmentJob) error {
+	return s.finishJob(job, JobStatusDone, nil)
+}
+
+func (s *PostgresEnrichmentStore) FailEnrichmentJob(job *EnrichmentJob, lastError string) error {
+	return s.finishJob(job, JobStatusFailed, &lastError)
+}
+
+// finishJob closes the job and records that its target was checked, so that
+// it is not scheduled again before it gets stale.
+func (s *PostgresEnrichmentStore) finishJob(job *EnrichmentJob, status string, lastError *string) error {
+	ctx := context.Background()
+
+	trx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer trx.Rollback(ctx)
+
+	query := `
+		UPDATE enrichment_jobs
+		SET status = $1, last_error = $2, updated_at = NOW()
+		WHERE id = $3
+		RETURNING updated_at
+	`
+
+	if err := trx.QueryRow(ctx, query, status, lastError, job.ID).Scan(&job.UpdatedAt); err != nil {
+		return err
+	}
+
+	targetQuery := fmt.Sprintf("UPDATE %s SET metadata_checked_at = NOW() WHERE id = $1", enrichmentTables[job.TargetType])
+	if _, err := trx.Exec(ctx, targetQuery, job.TargetID); err != nil {
+		return err
+	}
+
+	job.Status = status
+	job.LastError = lastError
+
+	return trx.Commit(ctx)
+}
+
+func (s *PostgresEnrichmentStore) RetryEnrichmentJob(job *EnrichmentJob, lastError string, runAt time.Time) error {
+	query := `
+		UPDATE enrichment_jobs
+		SET status = 'pending', last_error = $1, run_at = $2, updated_at = NOW()
+		WHERE id = $3
+		RETURNING status, run_at, updated_at
+	`
+
+	err := s.db.QueryRow(context.Background(), query, lastError, runAt, job.ID).Scan(&job.Status, &job.RunAt, &job.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	job.LastError = &lastError
+
+	return nil
+}
+
+// GetEnrichmentTarget returns nil when the book or wish does not exist
+// anymore.
+func (s *PostgresEnrichmentStore) GetEnrichmentTarget(targetType, id string) (*EnrichmentTarget, error) {
+	query := fmt.Sprintf("SELECT isbn, big_book_id FROM %s WHERE id = $1", enrichmentTables[targetType])
+
+	rows, _ := s.db.Query(context.Background(), query, id)
+	target, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[EnrichmentTarget])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return target, nil
+}
+
+// ApplyEnrichment sets the fields of the book or wish that are empty or that
+// were filled by a provider, and records where their new value comes from.
+// Fields set by the user are left alone. The check happens in the update
+// itself so that an edit made meanwhile is never overwritten. The book or wish
+// is left untouched, updated_at included, when no field changes.
+func (s *PostgresEnrichmentStore) ApplyEnrichment(targetType, id string, fields []EnrichedField) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	q := &filterQuery{}
+	assignments := make([]string, 0, len(fields)+1)
+	sources := make([]string, 0, len(fields))
+	changes := make([]string, 0, len(fields))
+
+	for _, field := range fields {
+		// Columns come from the enrichment worker, never from users.
+		writable := fmt.Sprintf(
+			"(COALESCE(metadata_sources->>'%[1]s', '') <> '%[2]s' AND (%[1]s IS NULL OR metadata_sources->>'%[1]s' IS NOT NULL))",
+			field.Column, MetadataSourceUser,
+		)
+		assignments = append(assignments, fmt.Sprintf("%[1]s = CASE WHEN %[2]s THEN %[3]s ELSE %[1]s END", field.Column, writable, q.arg(field.Value)))
+		sources = append(sources, fmt.Sprintf("CASE WHEN %s THEN JSONB_BUILD_OBJECT('%s', %s::TEXT) ELSE '{}' END", writable, field.Column, q.arg(field.Source)))
+		changes = append(changes, fmt.Sprintf(
+			"(%[1]s AND (%[2]s IS DISTINCT FROM %[3]s OR metadata_sources->>'%[2]s' IS DISTINCT FROM %[4]s::TEXT))",
+			writable, field.Column, q.arg(field.Value), q.arg(field.Source),
+		))
+
+		if targetType == EnrichmentTargetBook && field.Column == "cover_url" {
+			// Have the new cover downloaded again.
+			assignments = append(assignments, fmt.Sprintf(
+				"cover_fetched_at = CASE WHEN %s AND cover_url IS DISTINCT FROM %s THEN NULL ELSE cover_fetched_at END",
+				writable, q.arg(field.Value),
+			))
+		}
+	}
+
+	// Assignments all see the row as it was before the update.
+	assignments = append(assignments, "metadata_sources = metadata_sources || "+strings.Join(sources, " || "))
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s, updated_at = NOW() WHERE id = %s AND (%s)",
+		enrichmentTables[targetType], strings.Join(assignments, ", "), q.arg(id), strings.Join(changes, " OR "),
+	)
+
+	_, err := s.db.Exec(context.Background(), query, q.args...)
+	return err
+}
@@ -0,0 +1,275 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	GoalMetricBooks         = "books"
+	GoalMetricPages         = "pages"
+	GoalMetricWishlistBooks = "wishlist_books"
+
+	GoalPeriodYear    = "year"
+	GoalPeriodQuarter = "quarter"
+	GoalPeriodMonth   = "month"
+
+	GoalEventCompleted = "completed"
+)
+
+var (
+	GoalMetrics = []string{GoalMetricBooks, GoalMetricPages, GoalMetricWishlistBooks}
+	GoalPeriods = []string{GoalPeriodYear, GoalPeriodQuarter, GoalPeriodMonth}
+)
+
+type Goal struct {
+	ID          string     `json:"id" db:"id"`
+	UserID      string     `json:"user_id" db:"user_id"`
+	Metric      string     `json:"metric" db:"metric"`
+	Period      string     `json:"period" db:"period"`
+	Year        int        `json:"year" db:"year"`
+	PeriodIndex int        `json:"period_index" db:"period_index"`
+	Target      int        `json:"target" db:"target"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type GoalEvent struct {
+	ID        string    `json:"id" db:"id"`
+	GoalID    string    `json:"goal_id" db:"goal_id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Kind      string    `json:"kind" db:"kind"`
+	Progress  int       `json:"progress" db:"progress"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type GoalStore interface {
+	CreateGoal(goal *Goal) error
+	GetGoals(userId string) ([]Goal, error)
+	GetGoalById(id string) (*Goal, error)
+	UpdateGoal(goal *Goal) error
+	DeleteGoal(id string) error
+	GetGoalProgress(goal *Goal, start, end time.Time) (int, error)
+	RecordCompletion(goal *Goal, progress int) (*GoalEvent, error)
+	ClearCompletion(goal *Goal) error
+	GetGoalEvents(userId string, since *time.Time) ([]GoalEvent, error)
+}
+
+type PostgresGoalStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresGoalStore(db *pgxpool.Pool) *PostgresGoalStore {
+	return &PostgresGoalStore{db}
+}
+
+func (s *PostgresGoalStore) CreateGoal(goal *Goal) error {
+	query := `
+		INSERT INTO goals (user_id, metric, period, year, period_index, target)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := s.db.QueryRow(
+		context.Background(), query,
+		goal.UserID,
+		goal.Metric,
+		goal.Period,
+		goal.Year,
+		goal.PeriodIndex,
+		goal.Target,
+	).Scan(&goal.ID, &goal.CreatedAt, &goal.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *PostgresGoalStore) GetGoals(userId string) ([]Goal, error) {
+	query := "SELECT * FROM goals WHERE user_id = $1 ORDER BY year DESC, period, period_index, metric"
+
+	rows, err := s.db.Query(context.Background(), query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	goals, err := pgx.CollectRows(rows, pgx.RowToStructByName[Goal])
+	if err != nil {
+		return nil, err
+	}
+
+	return goals, nil
+}
+
+func (s *PostgresGoalStore) GetGoalById(id string) (*Goal, error) {
+	query := "SELECT * FROM goals WHERE id = $1"
+
+	rows, _ := s.db.Query(context.Background(), query, id)
+	goal, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[Goal])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return goal, nil
+}
+
+// UpdateGoal saves the target of the goal. Raising the target of a completed
+// goal makes it incomplete again.
+func (s *PostgresGoalStore) UpdateGoal(goal *Goal) error {
+	query := `
+		UPDATE goals
+		SET target = $1,
+			completed_at = CASE WHEN $1 > target THEN NULL ELSE completed_at END,
+			updated_at = NOW()
+		WHERE id = $2
+		RETURNING completed_at, updated_at
+	`
+
+	err := s.db.QueryRow(context.Background(), query, goal.Target, goal.ID).Scan(&goal.CompletedAt, &goal.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *PostgresGoalStore) DeleteGoal(id string) error {
+	query := "DELETE FROM goals WHERE id = $1"
+
+	commandTag, err := s.db.Exec(context.Background(), query, id)
+	if err != nil {
+		return err
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetGoalProgress measures the goal between start and end. Books count once
+// per reading cycle finished in the period, which is what books.date_finished
+// reflects for the latest one, so re-reads count as well.
+func (s *PostgresGoalStore) GetGoalProgress(goal *Goal, start, end time.Time) (int, error) {
+	var measure string
+	switch goal.Metric {
+	case GoalMetricPages:
+		measure = "COALESCE(SUM(b.page_count), 0)"
+	case GoalMetricWishlistBooks:
+		measure = "COUNT(*) FILTER (WHERE b.wish_id IS NOT NULL)"
+	default:
+		measure = "COUNT(*)"
+	}
+
+	query := `
+		SELECT ` + measure + `::INTEGER
+		FROM book_reads r
+		JOIN books b ON b.id = r.book_id
+		WHERE b.user_id = $1 AND b.deleted_at IS NULL AND r.finished_at >= $2 AND r.finished_at < $3
+	`
+
+	var progress int
+	err := s.db.QueryRow(context.Background(), query, goal.UserID, start, end).Scan(&progress)
+	if err != nil {
+		return 0, err
+	}
+
+	return progress, nil
+}
+
+// RecordCompletion marks the goal as completed and records the event the
+// client is notified with. It returns nil if the goal was already completed.
+func (s *PostgresGoalStore) RecordCompletion(goal *Goal, progress int) (*GoalEvent, error) {
+	ctx := context.Background()
+
+	trx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer trx.Rollback(ctx)
+
+	query := `
+		UPDATE goals
+		SET completed_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND completed_at IS NULL
+		RETURNING completed_at
+	`
+
+	err = trx.QueryRow(ctx, query, goal.ID).Scan(&goal.CompletedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		INSERT INTO goal_events (goal_id, user_id, kind, progress)
+		VALUES ($1, $2, $3, $4)
+		RETURNING *
+	`
+
+	rows, _ := trx.Query(ctx, query, goal.ID, goal.UserID, GoalEventCompleted, progress)
+	event, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[GoalEvent])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := trx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// ClearCompletion makes the goal incomplete again, once the reads that
+// completed it were deleted or moved out of its period. The completion event
+// stays, as the client was notified of it.
+func (s *PostgresGoalStore) ClearCompletion(goal *Goal) error {
+	query := `
+		UPDATE goals
+		SET completed_at = NULL, updated_at = NOW()
+		WHERE id = $1
+		RETURNING completed_at, updated_at
+	`
+
+	err := s.db.QueryRow(context.Background(), query, goal.ID).Scan(&goal.CompletedAt, &goal.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *PostgresGoalStore) GetGoalEvents(userId string, since *time.Time) ([]GoalEvent, error) {
+	query := `
+		SELECT * FROM goal_events
+		WHERE user_id = $1 AND ($2::TIMESTAMPTZ IS NULL OR created_at > $2)
+		ORDER BY created_at DESC
+	`
+
+	rows, err := s.db.Query(context.Background(), query, userId, since)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := pgx.CollectRows(rows, pgx.RowToStructByName[GoalEvent])
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,245 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	LoanDirectionLent     = "lent"
+	LoanDirectionBorrowed = "borrowed"
+)
+
+var LoanDirections = []string{LoanDirectionLent, LoanDirectionBorrowed}
+
+type Loan struct {
+	ID                  string     `json:"id" db:"id"`
+	BookID              string     `json:"book_id" db:"book_id"`
+	BookTitle           string     `json:"book_title" db:"book_title"`
+	UserID              string     `json:"user_id" db:"user_id"`
+	Direction           string     `json:"direction" db:"direction"`
+	CounterpartyName    string     `json:"counterparty_name" db:"counterparty_name"`
+	CounterpartyContact *string    `json:"counterparty_contact,omitempty" db:"counterparty_contact"`
+	LoanedAt            time.Time  `json:"loaned_at" db:"loaned_at"`
+	DueAt               *time.Time `json:"due_at,omitempty" db:"due_at"`
+	ReturnedAt          *time.Time `json:"returned_at,omitempty" db:"returned_at"`
+	RemindedAt          *time.Time `json:"reminded_at,omitempty" db:"reminded_at"`
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type LoanStore interface {
+	CreateLoan(loan *Loan) error
+	GetLoanById(id string) (*Loan, error)
+	GetBookLoans(bookId string) ([]Loan, error)
+	GetOpenLoans(userId string, direction *string) ([]Loan, error)
+	GetOverdueLoans(userId string, today time.Time) ([]Loan, error)
+	GetLoansToRemind(remindEvery time.Duration) ([]Loan, error)
+	MarkLoanReminded(id string) error
+	ReturnLoan(loan *Loan) error
+	DeleteLoan(id string) error
+}
+
+type PostgresLoanStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresLoanStore(db *pgxpool.Pool) *PostgresLoanStore {
+	return &PostgresLoanStore{db}
+}
+
+const loanColumns = `
+	l.id, l.book_id, b.title AS book_title, l.user_id, l.direction, l.counterparty_name, l.counterparty_contact,
+	l.loaned_at, l.due_at, l.returned_at, l.reminded_at, l.created_at, l.updated_at
+`
+
+func (s *PostgresLoanStore) collectLoans(query string, args ...any) ([]Loan, error) {
+	rows, err := s.db.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	loans, err := pgx.CollectRows(rows, pgx.RowToStructByName[Loan])
+	if err != nil {
+		return nil, err
+	}
+
+	return loans, nil
+}
+
+func (s *PostgresLoanStore) CreateLoan(loan *Loan) error {
+	query := `
+		INSERT INTO loans (book_id, user_id, direction, counterparty_name, counterparty_contact, loaned_at, due_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := s.db.QueryRow(
+		context.Background(), query,
+		loan.BookID,
+		loan.UserID,
+		loan.Direction,
+		loan.CounterpartyName,
+		loan.CounterpartyContact,
+		loan.LoanedAt,
+		loan.DueAt,
+	).Scan(&loan.ID, &loan.CreatedAt, &loan.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *PostgresLoanStore) GetLoanById(id string) (*Loan, error) {
+	query := "SELECT " + loanColumns + " FROM loans l JOIN books b ON b.id = l.book_id WHERE l.id = $1 AND b.deleted_at IS NULL"
+
+	rows, _ := s.db.Query(context.Background(), query, id)
+	loan, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[Loan])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return loan, nil
+}
+
+func (s *PostgresLoanStore) GetBookLoans(bookId string) ([]Loan, error) {
+	query := "SELECT " + loanColumns + `
+		FROM loans l JOIN books b ON b.id = l.book_id
+		WHERE l.book_id = $1 AND b.deleted_at IS NULL
+		ORDER BY l.loaned_at DESC, l.created_at DESC
+	`
+
+	return s.collectLoans(query, bookId)
+}
+
+// GetOpenLoans returns the loans of the user not returned yet, optionally
+// restricted to one direction, the ones due first coming first.
+func (s *PostgresLoanStore) GetOpenLoans(userId string, direction *string) ([]Loan, error) {
+	query := "SELECT " + loanColumns + `
+		FROM loans l JOIN books b ON b.id = l.book_id
+		WHERE l.user_id = $1 AND b.deleted_at IS NULL AND l.returned_at IS NULL AND ($2::LOAN_DIRECTION IS NULL OR l.direction = $2)
+		ORDER BY l.due_at NULLS LAST, l.loaned_at
+	`
+
+	return s.collectLoans(query, userId, direction)
+}
+
+// GetOverdueLoans returns the open loans of the user that were due before
+// today, the most overdue first.
+func (s *PostgresLoanStore) GetOverdueLoans(userId string, today time.Time) ([]Loan, error) {
+	query := "SELECT " + loanColumns + `
+		FROM loans l JOIN books b ON b.id = l.book_id
+		WHERE l.user_id = $1 AND b.deleted_at IS NULL AND l.returned_at IS NULL AND l.due_at < $2
+		ORDER BY l.due_at
+	`
+
+	return s.collectLoans(query, userId, today.Format(time.DateOnly))
+}
+
+// GetLoansToRemind returns the overdue loans of every user, in the time zone of
+// each user, that were not reminded during the last remindEvery.
+func (s *PostgresLoanStore) GetLoansToRemind(remindEvery time.Duration) ([]Loan, error) {
+	query := "SELECT " + loanColumns + `
+		FROM loans l
+		JOIN books b ON b.id = l.book_id
+		JOIN users u ON u.id = l.user_id
+		WHERE l.returned_at IS NULL
+			AND b.deleted_at IS NULL
+			AND l.due_at < (NOW() AT TIME ZONE u.timezone)::DATE
+			AND (l.reminded_at IS NULL OR l.reminded_at < NOW() - MAKE_INTERVAL(secs => $1))
+		ORDER BY l.due_at
+	`
+
+	return s.collectLoans(query, remindEvery.Seconds())
+}
+
+func (s *PostgresLoanStore) MarkLoanReminded(id string) error {
+	query := "UPDATE loans SET reminded_at = NOW() WHERE id = $1"
+
+	_, err := s.db.Exec(context.Background(), query, id)
+
+	return err
+}
+
+func (s *PostgresLoanStore) ReturnLoan(loan *Loan) error {
+	query := `
+		UPDATE loans
+		SET returned_at = $1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING updated_at
+	`
+
+	err := s.db.QueryRow(context.Background(), query, loan.ReturnedAt, loan.ID).Scan(&loan.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *PostgresLoanStore) DeleteLoan(id string) error {
+	query := "DELETE FROM loans WHERE id = $1"
+
+	commandTag, err := s.db.Exec(context.Background(), query, id)
+	if err != nil {
+		return err
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// fillLoans flags the books currently lent and sets who they are lent to.
+func fillLoans(ctx context.Context, q querier, books []Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(books))
+	for i := range books {
+		ids[i] = books[i].ID
+	}
+
+	query := `
+		SELECT book_id::TEXT, counterparty_name
+		FROM loans
+		WHERE book_id = ANY($1::UUID[]) AND direction = 'lent' AND returned_at IS NULL
+	`
+
+	rows, err := q.Query(ctx, query, ids)
+	if err != nil {
+		return err
+	}
+
+	borrowers := make(map[string]string)
+	var bookId, borrower string
+
+	_, err = pgx.ForEachRow(rows, []any{&bookId, &borrower}, func() error {
+		borrowers[bookId] = borrower
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range books {
+		if name, ok := borrowers[books[i].ID]; ok {
+			books[i].Loaned = true
+			books[i].Borrower = &name
+		}
+	}
+
+	return nil
+}
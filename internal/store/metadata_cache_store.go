@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MetadataCacheEntry is the answer of a metadata provider for a book. Payload
+// holds the JSON answer and is empty when the provider did not know the book.
+type MetadataCacheEntry struct {
+	Provider   string    `json:"provider" db:"provider"`
+	ExternalID string    `json:"external_id" db:"external_id"`
+	Payload    []byte    `json:"payload" db:"payload"`
+	NotFound   bool      `json:"not_found" db:"not_found"`
+	FetchedAt  time.Time `json:"fetched_at" db:"fetched_at"`
+	ExpiresAt  time.Time `json:"expires_at" db:"expires_at"`
+}
+
+type MetadataCacheStore interface {
+	GetMetadataCacheEntry(provider, externalId string) (*MetadataCacheEntry, error)
+	SaveMetadataCacheEntry(entry *MetadataCacheEntry) error
+	DeleteExpiredMetadataCacheEntries(expiredBefore time.Time) (int64, error)
+}
+
+type PostgresMetadataCacheStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresMetadataCacheStore(db *pgxpool.Pool) *PostgresMetadataCacheStore {
+	return &PostgresMetadataCacheStore{db}
+}
+
+// GetMetadataCacheEntry returns the entry even when it has expired, so that
+// callers can fall back on it when the provider cannot answer.
+func (s *PostgresMetadataCacheStore) GetMetadataCacheEntry(provider, externalId string) (*MetadataCacheEntry, error) {
+	query := "SELECT * FROM book_metadata_cache WHERE provider = $1 AND external_id = $2"
+
+	rows, _ := s.db.Query(context.Background(), query, provider, externalId)
+	entry, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[MetadataCacheEntry])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (s *PostgresMetadataCacheStore) SaveMetadataCacheEntry(entry *MetadataCacheEntry) error {
+	query := `
+		INSERT INTO book_metadata_cache (provider, external_id, payload, not_found, fetched_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (provider, external_id) DO UPDATE
+		SET payload = EXCLUDED.payload, not_found = EXCLUDED.not_found,
+			fetched_at = EXCLUDED.fetched_at, expires_at = EXCLUDED.expires_at
+	`
+
+	var payload any
+	if !entry.NotFound {
+		payload = string(entry.Payload)
+	}
+
+	_, err := s.db.Exec(
+		context.Background(), query,
+		entry.Provider,
+		entry.ExternalID,
+		payload,
+		entry.NotFound,
+		entry.FetchedAt,
+		entry.ExpiresAt,
+	)
+
+	return err
+}
+
+// DeleteExpiredMetadataCacheEntries deletes the entries that expired before
+// expiredBefore and returns how many were deleted.
+func (s *PostgresMetadataCacheStore) DeleteExpiredMetadataCacheEntries(expiredBefore time.Time) (int64, error) {
+	commandTag, err := s.db.Exec(context.Background(), "DELETE FROM book_metadata_cache WHERE expires_at < $1", expiredBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return commandTag.RowsAffected(), nil
+}
@@ -0,0 +1,294 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Quote struct {
+	ID        string    `json:"id" db:"id"`
+	BookID    string    `json:"book_id" db:"book_id"`
+	BookTitle string    `json:"book_title" db:"book_title"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Text      string    `json:"text" db:"text"`
+	Page      *int      `json:"page,omitempty" db:"page"`
+	Location  *string   `json:"location,omitempty" db:"location"`
+	Note      *string   `json:"note,omitempty" db:"note"`
+	Tags      []string  `json:"tags" db:"tags"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type QuoteFilters struct {
+	Query   string
+	Tags    []string
+	TagMode string
+}
+
+type QuoteStore interface {
+	CreateQuote(quote *Quote) error
+	GetQuoteById(id string) (*Quote, error)
+	GetBookQuotes(bookId string) ([]Quote, error)
+	GetUserQuotes(userId string) ([]Quote, error)
+	SearchQuotes(userId string, filters QuoteFilters, page, take int) ([]Quote, error)
+	SearchQuotesCount(userId string, filters QuoteFilters) (int, error)
+	GetQuoteOfTheDay(userId, day string) (*Quote, error)
+	ImportQuotes(quotes []Quote) (int, error)
+	UpdateQuote(quote *Quote) error
+	DeleteQuote(id string) error
+}
+
+type PostgresQuoteStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresQuoteStore(db *pgxpool.Pool) *PostgresQuoteStore {
+	return &PostgresQuoteStore{db}
+}
+
+const quoteColumns = `
+	q.id, q.book_id, b.title AS book_title, q.user_id, q.text, q.page, q.location, q.note,
+	ARRAY(SELECT t.name FROM quote_tags qt JOIN tags t ON t.id = qt.tag_id WHERE qt.quote_id = q.id ORDER BY t.name) AS tags,
+	q.created_at, q.updated_at
+`
+
+// quoteDocument is the text searched by the full-text search. It must stay in
+// sync with the expression of the quotes_search_idx index.
+const quoteDocument = "TO_TSVECTOR('simple', q.text || ' ' || COALESCE(q.note, ''))"
+
+func (f *QuoteFilters) query(userId string) *filterQuery {
+	q := &filterQuery{}
+	q.conditions = append(q.conditions, "q.user_id = "+q.arg(userId), "EXISTS (SELECT 1 FROM books b WHERE b.id = q.book_id AND b.deleted_at IS NULL)")
+
+	if f.Query != "" {
+		search := q.arg(f.Query)
+		q.conditions = append(q.conditions, quoteDocument+" @@ WEBSEARCH_TO_TSQUERY('simple', "+search+")")
+		q.orderBy = append(q.orderBy, "TS_RANK("+quoteDocument+", WEBSEARCH_TO_TSQUERY('simple', "+search+")) DESC")
+	}
+
+	if len(f.Tags) > 0 {
+		q.conditions = append(q.conditions, tagsCondition(quoteTagsTable, "q", f.TagMode, q.arg(f.Tags), q.arg(len(f.Tags))))
+	}
+
+	q.orderBy = append(q.orderBy, "q.created_at DESC")
+
+	return q
+}
+
+func (s *PostgresQuoteStore) CreateQuote(quote *Quote) error {
+	query := `
+		INSERT INTO quotes (book_id, user_id, text, page, location, note)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := s.db.QueryRow(
+		context.Background(), query,
+		quote.BookID,
+		quote.UserID,
+		quote.Text,
+		quote.Page,
+		quote.Location,
+		quote.Note,
+	).Scan(&quote.ID, &quote.CreatedAt, &quote.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if quote.Tags == nil {
+		quote.Tags = []string{}
+	}
+
+	return nil
+}
+
+func (s *PostgresQuoteStore) GetQuoteById(id string) (*Quote, error) {
+	query := "SELECT " + quoteColumns + " FROM quotes q JOIN books b ON b.id = q.book_id WHERE q.id = $1 AND b.deleted_at IS NULL"
+
+	rows, _ := s.db.Query(context.Background(), query, id)
+	quote, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[Quote])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return quote, nil
+}
+
+// GetBookQuotes returns the quotes of the book in reading order.
+func (s *PostgresQuoteStore) GetBookQuotes(bookId string) ([]Quote, error) {
+	query := "SELECT " + quoteColumns + `
+		FROM quotes q JOIN books b ON b.id = q.book_id
+		WHERE q.book_id = $1 AND b.deleted_at IS NULL
+		ORDER BY q.page NULLS LAST, q.created_at
+	`
+
+	rows, err := s.db.Query(context.Background(), query, bookId)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes, err := pgx.CollectRows(rows, pgx.RowToStructByName[Quote])
+	if err != nil {
+		return nil, err
+	}
+
+	return quotes, nil
+}
+
+func (s *PostgresQuoteStore) GetUserQuotes(userId string) ([]Quote, error) {
+	query := "SELECT " + quoteColumns + `
+		FROM quotes q JOIN books b ON b.id = q.book_id
+		WHERE q.user_id = $1 AND b.deleted_at IS NULL
+		ORDER BY q.created_at DESC
+	`
+
+	rows, err := s.db.Query(context.Background(), query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes, err := pgx.CollectRows(rows, pgx.RowToStructByName[Quote])
+	if err != nil {
+		return nil, err
+	}
+
+	return quotes, nil
+}
+
+// SearchQuotes returns the quotes of the user matching the filters, the most
+// relevant first when searching by text.
+func (s *PostgresQuoteStore) SearchQuotes(userId string, filters QuoteFilters, page, take int) ([]Quote, error) {
+	q := filters.query(userId)
+	query := fmt.Sprintf(
+		"SELECT %s FROM quotes q JOIN books b ON b.id = q.book_id WHERE %s ORDER BY %s LIMIT %s OFFSET %s",
+		quoteColumns, q.where(), strings.Join(q.orderBy, ", "), q.arg(take), q.arg((page-1)*take),
+	)
+
+	rows, _ := s.db.Query(context.Background(), query, q.args...)
+	quotes, err := pgx.CollectRows(rows, pgx.RowToStructByName[Quote])
+	if err != nil {
+		return nil, err
+	}
+
+	return quotes, nil
+}
+
+func (s *PostgresQuoteStore) SearchQuotesCount(userId string, filters QuoteFilters) (int, error) {
+	var count int
+
+	q := filters.query(userId)
+	query := "SELECT COUNT(*) FROM quotes q WHERE " + q.where()
+	err := s.db.QueryRow(context.Background(), query, q.args...).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetQuoteOfTheDay picks one quote of the user for the given day. Quotes are
+// ordered by a hash of their id salted with the day, so the pick is stable
+// during the day and changes from one day to the next.
+func (s *PostgresQuoteStore) GetQuoteOfTheDay(userId, day string) (*Quote, error) {
+	query := "SELECT " + quoteColumns + `
+		FROM quotes q JOIN books b ON b.id = q.book_id
+		WHERE q.user_id = $1 AND b.deleted_at IS NULL
+		ORDER BY MD5(q.id::TEXT || $2)
+		LIMIT 1
+	`
+
+	rows, _ := s.db.Query(context.Background(), query, userId, day)
+	quote, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[Quote])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return quote, nil
+}
+
+// ImportQuotes saves the quotes, skipping the ones whose text is already saved
+// for the same book. It returns the number of quotes actually inserted.
+func (s *PostgresQuoteStore) ImportQuotes(quotes []Quote) (int, error) {
+	ctx := context.Background()
+
+	trx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	defer trx.Rollback(ctx)
+
+	query := `
+		INSERT INTO quotes (book_id, user_id, text, page, location, note)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (book_id, MD5(text)) DO NOTHING
+	`
+
+	imported := 0
+	for _, quote := range quotes {
+		commandTag, err := trx.Exec(ctx, query, quote.BookID, quote.UserID, quote.Text, quote.Page, quote.Location, quote.Note)
+		if err != nil {
+			return 0, err
+		}
+
+		imported += int(commandTag.RowsAffected())
+	}
+
+	if err := trx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return imported, nil
+}
+
+func (s *PostgresQuoteStore) UpdateQuote(quote *Quote) error {
+	query := `
+		UPDATE quotes
+		SET text = $1, page = $2, location = $3, note = $4, updated_at = NOW()
+		WHERE id = $5
+		RETURNING updated_at
+	`
+
+	err := s.db.QueryRow(
+		context.Background(), query,
+		quote.Text,
+		quote.Page,
+		quote.Location,
+		quote.Note,
+		quote.ID,
+	).Scan(&quote.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *PostgresQuoteStore) DeleteQuote(id string) error {
+	query := "DELETE FROM quotes WHERE id = $1"
+
+	commandTag, err := s.db.Exec(context.Background(), query, id)
+	if err != nil {
+		return err
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,215 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ReadingSession struct {
+	ID           string     `json:"id" db:"id"`
+	BookID       string     `json:"book_id" db:"book_id"`
+	UserID       string     `json:"user_id" db:"user_id"`
+	StartedAt    time.Time  `json:"started_at" db:"started_at"`
+	EndedAt      *time.Time `json:"ended_at,omitempty" db:"ended_at"`
+	StartPage    *int       `json:"start_page,omitempty" db:"start_page"`
+	EndPage      *int       `json:"end_page,omitempty" db:"end_page"`
+	StartPercent *float64   `json:"start_percent,omitempty" db:"start_percent"`
+	EndPercent   *float64   `json:"end_percent,omitempty" db:"end_percent"`
+	Notes        *string    `json:"notes,omitempty" db:"notes"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type ReadingSessionStore interface {
+	CreateSession(session *ReadingSession, startedBook *Book, actor RevisionActor) error
+	GetSessionById(id string) (*ReadingSession, error)
+	GetBookSessions(bookId string) ([]ReadingSession, error)
+	UpdateSession(session *ReadingSession) error
+	DeleteSession(id string) error
+}
+
+type PostgresReadingSessionStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresReadingSessionStore(db *pgxpool.Pool) *PostgresReadingSessionStore {
+	return &PostgresReadingSessionStore{db}
+}
+
+// CreateSession saves the session, along with startedBook when the session
+// starts reading it, so that a version conflict on the book saves nothing.
+func (s *PostgresReadingSessionStore) CreateSession(session *ReadingSession, startedBook *Book, actor RevisionActor) error {
+	ctx := context.Background()
+
+	trx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer trx.Rollback(ctx)
+
+	if startedBook != nil {
+		if err := updateBook(ctx, trx, startedBook, actor, nil); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO reading_sessions (book_id, user_id, started_at, ended_at, start_page, end_page, start_percent, end_percent, notes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, updated_at
+	`
+
+	err = trx.QueryRow(
+		ctx, query,
+		session.BookID,
+		session.UserID,
+		session.StartedAt,
+		session.EndedAt,
+		session.StartPage,
+		session.EndPage,
+		session.StartPercent,
+		session.EndPercent,
+		session.Notes,
+	).Scan(&session.ID, &session.CreatedAt, &session.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return trx.Commit(ctx)
+}
+
+func (s *PostgresReadingSessionStore) GetSessionById(id string) (*ReadingSession, error) {
+	query := "SELECT * FROM reading_sessions WHERE id = $1"
+
+	rows, _ := s.db.Query(context.Background(), query, id)
+	session, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[ReadingSession])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (s *PostgresReadingSessionStore) GetBookSessions(bookId string) ([]ReadingSession, error) {
+	query := "SELECT * FROM reading_sessions WHERE book_id = $1 ORDER BY started_at DESC"
+
+	rows, err := s.db.Query(context.Background(), query, bookId)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := pgx.CollectRows(rows, pgx.RowToStructByName[ReadingSession])
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (s *PostgresReadingSessionStore) UpdateSession(session *ReadingSession) error {
+	query := `
+		UPDATE reading_sessions
+		SET started_at = $1, ended_at = $2, start_page = $3, end_page = $4, start_percent = $5, end_percent = $6, notes = $7, updated_at = NOW()
+		WHERE id = $8
+		RETURNING updated_at
+	`
+
+	err := s.db.QueryRow(
+		context.Background(), query,
+		session.StartedAt,
+		session.EndedAt,
+		session.StartPage,
+		session.EndPage,
+		session.StartPercent,
+		session.EndPercent,
+		session.Notes,
+		session.ID,
+	).Scan(&session.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *PostgresReadingSessionStore) DeleteSession(id string) error {
+	query := "DELETE FROM reading_sessions WHERE id = $1"
+
+	commandTag, err := s.db.Exec(context.Background(), query, id)
+	if err != nil {
+		return err
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// fillProgress derives the reading progress of the books, in percent, from
+// the furthest position of their latest reading session. Finished books are
+// always at 100%.
+func fillProgress(ctx context.Context, q querier, books []Book) error {
+	ids := make([]string, 0, len(books))
+	for i := range books {
+		if books[i].Status == "read" {
+			full := 100.0
+			books[i].Progress = &full
+			continue
+		}
+
+		ids = append(ids, books[i].ID)
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT DISTINCT ON (s.book_id)
+			s.book_id::TEXT,
+			COALESCE(s.end_percent, LEAST(100, s.end_page * 100.0 / b.page_count))::FLOAT8
+		FROM reading_sessions s
+		JOIN books b ON b.id = s.book_id
+		WHERE s.book_id = ANY($1::UUID[])
+			AND (s.end_percent IS NOT NULL OR (s.end_page IS NOT NULL AND b.page_count IS NOT NULL))
+		ORDER BY s.book_id, s.started_at DESC
+	`
+
+	rows, err := q.Query(ctx, query, ids)
+	if err != nil {
+		return err
+	}
+
+	progress := make(map[string]float64)
+	var (
+		bookId  string
+		percent float64
+	)
+
+	_, err = pgx.ForEachRow(rows, []any{&bookId, &percent}, func() error {
+		progress[bookId] = percent
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range books {
+		if p, ok := progress[books[i].ID]; ok {
+			books[i].Progress = &p
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Series struct {
+	ID           string    `json:"id" db:"id"`
+	UserID       string    `json:"user_id" db:"user_id"`
+	Name         string    `json:"name" db:"name"`
+	TotalVolumes *int      `json:"total_volumes,omitempty" db:"total_volumes"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type SeriesStore interface {
+	CreateSeries(series *Series) error
+	FindOrCreateSeries(userId, name string, totalVolumes *int) (*Series, error)
+	GetUserSeries(userId string) ([]Series, error)
+	GetSeriesById(id string) (*Series, error)
+	UpdateSeries(series *Series) error
+	DeleteSeries(id string) error
+	GetSeriesBooks(seriesId string) ([]Book, error)
+	GetSeriesWishes(seriesId string) ([]Wish, error)
+}
+
+type PostgresSeriesStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresSeriesStore(db *pgxpool.Pool) *PostgresSeriesStore {
+	return &PostgresSeriesStore{db}
+}
+
+func (s *PostgresSeriesStore) CreateSeries(series *Series) error {
+	query := `
+		INSERT INTO series (user_id, name, total_volumes)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := s.db.QueryRow(context.Background(), query, series.UserID, series.Name, series.TotalVolumes).
+		Scan(&series.ID, &series.CreatedAt, &series.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FindOrCreateSeries returns the series of the user with the given name,
+// creating it when needed. A known total of volumes is never overwritten.
+func (s *PostgresSeriesStore) FindOrCreateSeries(userId, name string, totalVolumes *int) (*Series, error) {
+	query := `
+		INSERT INTO series (user_id, name, total_volumes)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, name) DO UPDATE SET total_volumes = COALESCE(series.total_volumes, EXCLUDED.total_volumes)
+		RETURNING *
+	`
+
+	rows, _ := s.db.Query(context.Background(), query, userId, name, totalVolumes)
+	series, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[Series])
+	if err != nil {
+		return nil, err
+	}
+
+	return series, nil
+}
+
+func (s *PostgresSeriesStore) GetUserSeries(userId string) ([]Series, error) {
+	query := "SELECT * FROM series WHERE user_id = $1 ORDER BY name"
+
+	rows, err := s.db.Query(context.Background(), query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	series, err := pgx.CollectRows(rows, pgx.RowToStructByName[Series])
+	if err != nil {
+		return nil, err
+	}
+
+	return series, nil
+}
+
+func (s *PostgresSeriesStore) GetSeriesById(id string) (*Series, error) {
+	query := "SELECT * FROM series WHERE id = $1"
+
+	rows, _ := s.db.Query(context.Background(), query, id)
+	series, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[Series])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return series, nil
+}
+
+func (s *PostgresSeriesStore) UpdateSeries(series *Series) error {
+	query := `
+		UPDATE series
+		SET name = $1, total_volumes = $2, updated_at = NOW()
+		WHERE id = $3
+		RETURNING updated_at
+	`
+
+	err := s.db.QueryRow(context.Background(), query, series.Name, series.TotalVolumes, series.ID).Scan(&series.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *PostgresSeriesStore) DeleteSeries(id string) error {
+	query := "DELETE FROM series WHERE id = $1"
+
+	commandTag, err := s.db.Exec(context.Background(), query, id)
+	if err != nil {
+		return err
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (s *PostgresSeriesStore) GetSeriesBooks(seriesId string) ([]Book, error) {
+	query := "SELECT * FROM books WHERE series_id = $1 AND deleted_at IS NULL ORDER BY series_position NULLS LAST, created_at"
+
+	rows, err := s.db.Query(context.Background(), query, seriesId)
+	if err != nil {
+		return nil, err
+	}
+
+	books, err := pgx.CollectRows(rows, pgx.RowToStructByName[Book])
+	if err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+func (s *PostgresSeriesStore) GetSeriesWishes(seriesId string) ([]Wish, error) {
+	query := `
+		SELECT *
+		FROM wishlists
+		WHERE series_id = $1 AND acquired = FALSE AND deleted_at IS NULL
+		ORDER BY series_position NULLS LAST, created_at
+	`
+
+	rows, err := s.db.Query(context.Background(), query, seriesId)
+	if err != nil {
+		return nil, err
+	}
+
+	wishes, err := pgx.CollectRows(rows, pgx.RowToStructByName[Wish])
+	if err != nil {
+		return nil, err
+	}
+
+	return wishes, nil
+}
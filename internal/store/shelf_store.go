@@ -0,0 +1,229 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Shelf struct {
+	ID          string    `json:"id" db:"id"`
+	UserID      string    `json:"user_id" db:"user_id"`
+	Name        string    `json:"name" db:"name"`
+	Description *string   `json:"description,omitempty" db:"description"`
+	BooksCount  int       `json:"books_count" db:"books_count"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type ShelfStore interface {
+	CreateShelf(shelf *Shelf) error
+	GetShelves(userId string) ([]Shelf, error)
+	GetShelfById(id string) (*Shelf, error)
+	UpdateShelf(shelf *Shelf) error
+	DeleteShelf(id string) error
+	GetShelfBooks(shelfId string) ([]Book, error)
+	GetShelfBookIds(shelfId string) ([]string, error)
+	AddBooks(shelfId, userId string, bookIds []string) (int, error)
+	RemoveBooks(shelfId string, bookIds []string) (int, error)
+	ReorderBooks(shelfId string, bookIds []string) error
+}
+
+type PostgresShelfStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresShelfStore(db *pgxpool.Pool) *PostgresShelfStore {
+	return &PostgresShelfStore{db}
+}
+
+const shelfColumns = `
+	s.id, s.user_id, s.name, s.description,
+	(SELECT COUNT(*) FROM shelf_books sb JOIN books b ON b.id = sb.book_id WHERE sb.shelf_id = s.id AND b.deleted_at IS NULL)::INTEGER AS books_count,
+	s.created_at, s.updated_at
+`
+
+func (s *PostgresShelfStore) CreateShelf(shelf *Shelf) error {
+	query := `
+		INSERT INTO shelves (user_id, name, description)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := s.db.QueryRow(context.Background(), query, shelf.UserID, shelf.Name, shelf.Description).
+		Scan(&shelf.ID, &shelf.CreatedAt, &shelf.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *PostgresShelfStore) GetShelves(userId string) ([]Shelf, error) {
+	query := "SELECT " + shelfColumns + " FROM shelves s WHERE s.user_id = $1 ORDER BY s.name"
+
+	rows, err := s.db.Query(context.Background(), query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	shelves, err := pgx.CollectRows(rows, pgx.RowToStructByName[Shelf])
+	if err != nil {
+		return nil, err
+	}
+
+	return shelves, nil
+}
+
+func (s *PostgresShelfStore) GetShelfById(id string) (*Shelf, error) {
+	query := "SELECT " + shelfColumns + " FROM shelves s WHERE s.id = $1"
+
+	rows, _ := s.db.Query(context.Background(), query, id)
+	shelf, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[Shelf])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return shelf, nil
+}
+
+func (s *PostgresShelfStore) UpdateShelf(shelf *Shelf) error {
+	query := `
+		UPDATE shelves
+		SET name = $1, description = $2, updated_at = NOW()
+		WHERE id = $3
+		RETURNING updated_at
+	`
+
+	err := s.db.QueryRow(context.Background(), query, shelf.Name, shelf.Description, shelf.ID).Scan(&shelf.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *PostgresShelfStore) DeleteShelf(id string) error {
+	query := "DELETE FROM shelves WHERE id = $1"
+
+	commandTag, err := s.db.Exec(context.Background(), query, id)
+	if err != nil {
+		return err
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (s *PostgresShelfStore) GetShelfBooks(shelfId string) ([]Book, error) {
+	query := `
+		SELECT b.*
+		FROM books b
+		JOIN shelf_books sb ON sb.book_id = b.id
+		WHERE sb.shelf_id = $1 AND b.deleted_at IS NULL
+		ORDER BY sb.position, sb.added_at
+	`
+
+	rows, err := s.db.Query(context.Background(), query, shelfId)
+	if err != nil {
+		return nil, err
+	}
+
+	books, err := pgx.CollectRows(rows, pgx.RowToStructByName[Book])
+	if err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+func (s *PostgresShelfStore) GetShelfBookIds(shelfId string) ([]string, error) {
+	query := `
+		SELECT sb.book_id::TEXT
+		FROM shelf_books sb
+		JOIN books b ON b.id = sb.book_id
+		WHERE sb.shelf_id = $1 AND b.deleted_at IS NULL
+		ORDER BY sb.position, sb.added_at
+	`
+
+	rows, err := s.db.Query(context.Background(), query, shelfId)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// AddBooks appends the given books at the end of the shelf. Books that do not
+// belong to the user or that are already on the shelf are skipped.
+func (s *PostgresShelfStore) AddBooks(shelfId, userId string, bookIds []string) (int, error) {
+	return addShelfBooks(context.Background(), s.db, shelfId, userId, bookIds)
+}
+
+func addShelfBooks(ctx context.Context, q querier, shelfId, userId string, bookIds []string) (int, error) {
+	query := `
+		INSERT INTO shelf_books (shelf_id, book_id, position)
+		SELECT $1, b.id, COALESCE((SELECT MAX(position) + 1 FROM shelf_books WHERE shelf_id = $1), 0) + o.ord - 1
+		FROM UNNEST($2::UUID[]) WITH ORDINALITY AS o(book_id, ord)
+		JOIN books b ON b.id = o.book_id
+		WHERE b.user_id = $3 AND b.deleted_at IS NULL
+		ON CONFLICT (shelf_id, book_id) DO NOTHING
+	`
+
+	commandTag, err := q.Exec(ctx, query, shelfId, bookIds, userId)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(commandTag.RowsAffected()), nil
+}
+
+func (s *PostgresShelfStore) RemoveBooks(shelfId string, bookIds []string) (int, error) {
+	query := "DELETE FROM shelf_books WHERE shelf_id = $1 AND book_id = ANY($2::UUID[])"
+
+	commandTag, err := s.db.Exec(context.Background(), query, shelfId, bookIds)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(commandTag.RowsAffected()), nil
+}
+
+// ReorderBooks moves the given books to the top of the shelf in the given
+// order. Books left out keep their relative order after them.
+func (s *PostgresShelfStore) ReorderBooks(shelfId string, bookIds []string) error {
+	query := `
+		WITH ordered AS (
+			SELECT sb.book_id, ROW_NUMBER() OVER (ORDER BY o.ord NULLS LAST, sb.position, sb.added_at) - 1 AS new_position
+			FROM shelf_books sb
+			LEFT JOIN UNNEST($2::UUID[]) WITH ORDINALITY AS o(book_id, ord) ON o.book_id = sb.book_id
+			WHERE sb.shelf_id = $1
+		)
+		UPDATE shelf_books sb
+		SET position = ordered.new_position
+		FROM ordered
+		WHERE sb.shelf_id = $1 AND sb.book_id = ordered.book_id
+	`
+
+	_, err := s.db.Exec(context.Background(), query, shelfId, bookIds)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LibraryStats sums up the library and wishlist of a user. A book read
+// several times counts once in Read and once per finished cycle in
+// TotalReads. TotalSpend sums the prices of the copies by currency and
+// Formats counts the copies by format.
+type LibraryStats struct {
+	TotalBooks              int                `json:"total_books"`
+	ToRead                  int                `json:"to_read"`
+	Reading                 int                `json:"reading"`
+	Read                    int                `json:"read"`
+	TotalReads              int                `json:"total_reads"`
+	Rereads                 int                `json:"rereads"`
+	RereadBooks             int                `json:"reread_books"`
+	WishlistCount           int                `json:"wishlist_count"`
+	AverageRating           *float64           `json:"average_rating"`
+	MostReadAuthor          *string            `json:"most_read_author"`
+	AverageWishlistPriority *float64           `json:"average_wishlist_priority"`
+	TotalSpend              map[string]float64 `json:"total_spend"`
+	Formats                 map[string]int     `json:"formats"`
+}
+
+type StatsStore interface {
+	GetUserStats(userId string) (*LibraryStats, error)
+}
+
+type PostgresStatsStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresStatsStore(db *pgxpool.Pool) *PostgresStatsStore {
+	return &PostgresStatsStore{db}
+}
+
+// GetUserStats computes the statistics of the user. Wishlist priorities
+// average on a 1 (low) to 3 (high) scale, and the most read author is the
+// one with the most finished reading cycles.
+func (s *PostgresStatsStore) GetUserStats(userId string) (*LibraryStats, error) {
+	query := `
+		WITH finished_reads AS (
+			SELECT r.book_id, COUNT(*) AS reads
+			FROM book_reads r
+			JOIN books b ON b.id = r.book_id
+			WHERE b.user_id = $1 AND b.deleted_at IS NULL AND r.finished_at IS NOT NULL
+			GROUP BY r.book_id
+		)
+		SELECT
+			(SELECT COUNT(*) FROM books WHERE user_id = $1 AND deleted_at IS NULL)::INTEGER,
+			(SELECT COUNT(*) FROM books WHERE user_id = $1 AND deleted_at IS NULL AND status = 'to_read')::INTEGER,
+			(SELECT COUNT(*) FROM books WHERE user_id = $1 AND deleted_at IS NULL AND status = 'reading')::INTEGER,
+			(SELECT COUNT(*) FROM books WHERE user_id = $1 AND deleted_at IS NULL AND status = 'read')::INTEGER,
+			(SELECT COALESCE(SUM(reads), 0) FROM finished_reads)::INTEGER,
+			(SELECT COALESCE(SUM(reads - 1), 0) FROM finished_reads)::INTEGER,
+			(SELECT COUNT(*) FROM finished_reads WHERE reads > 1)::INTEGER,
+			(SELECT COUNT(*) FROM wishlists WHERE user_id = $1 AND acquired = FALSE AND deleted_at IS NULL)::INTEGER,
+			(SELECT AVG(rating) FROM books WHERE user_id = $1 AND deleted_at IS NULL AND status = 'read' AND rating > 0)::FLOAT8,
+			(
+				SELECT a.name
+				FROM finished_reads fr
+				JOIN book_authors ba ON ba.book_id = fr.book_id AND ba.role = 'author'
+				JOIN authors a ON a.id = ba.author_id
+				GROUP BY a.id, a.name
+				ORDER BY SUM(fr.reads) DESC, a.name
+				LIMIT 1
+			),
+			(
+				SELECT AVG(CASE priority WHEN 'low' THEN 1 WHEN 'normal' THEN 2 WHEN 'high' THEN 3 END)
+				FROM wishlists
+				WHERE user_id = $1 AND acquired = FALSE AND deleted_at IS NULL
+			)::FLOAT8
+	`
+
+	stats := &LibraryStats{}
+	err := s.db.QueryRow(context.Background(), query, userId).Scan(
+		&stats.TotalBooks,
+		&stats.ToRead,
+		&stats.Reading,
+		&stats.Read,
+		&stats.TotalReads,
+		&stats.Rereads,
+		&stats.RereadBooks,
+		&stats.WishlistCount,
+		&stats.AverageRating,
+		&stats.MostReadAuthor,
+		&stats.AverageWishlistPriority,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	spendQuery := `
+		SELECT c.currency, SUM(c.price)::FLOAT8
+		FROM copies c
+		JOIN books b ON b.id = c.book_id
+		WHERE c.user_id = $1 AND c.price IS NOT NULL AND b.deleted_at IS NULL
+		GROUP BY c.currency
+	`
+
+	rows, err := s.db.Query(context.Background(), spendQuery, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.TotalSpend = make(map[string]float64)
+	var (
+		currency string
+		spend    float64
+	)
+
+	_, err = pgx.ForEachRow(rows, []any{&currency, &spend}, func() error {
+		stats.TotalSpend[currency] = spend
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	formatsQuery := `
+		SELECT c.format::TEXT, COUNT(*)::INTEGER
+		FROM copies c
+		JOIN books b ON b.id = c.book_id
+		WHERE c.user_id = $1 AND b.deleted_at IS NULL
+		GROUP BY c.format
+	`
+
+	rows, err = s.db.Query(context.Background(), formatsQuery, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Formats = make(map[string]int)
+	var (
+		format string
+		count  int
+	)
+
+	_, err = pgx.ForEachRow(rows, []any{&format, &count}, func() error {
+		stats.Formats[format] = count
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
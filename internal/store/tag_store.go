@@ -0,0 +1,317 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	TagModeAny = "any"
+	TagModeAll = "all"
+)
+
+type Tag struct {
+	ID         string    `json:"id" db:"id"`
+	UserID     string    `json:"user_id" db:"user_id"`
+	Name       string    `json:"name" db:"name"`
+	UsageCount int       `json:"usage_count" db:"usage_count"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type TagStore interface {
+	GetTags(userId, prefix string, limit int) ([]Tag, error)
+	GetTagById(id string) (*Tag, error)
+	RenameTag(tag *Tag) error
+	MergeTags(targetId string, sourceIds []string) error
+	DeleteTag(id string) error
+	GetBookTags(bookId string) ([]Tag, error)
+	AddBookTags(bookId, userId string, names []string) ([]Tag, error)
+	RemoveBookTag(bookId, tagId string) error
+	GetWishTags(wishId string) ([]Tag, error)
+	AddWishTags(wishId, userId string, names []string) ([]Tag, error)
+	RemoveWishTag(wishId, tagId string) error
+	GetQuoteTags(quoteId string) ([]Tag, error)
+	AddQuoteTags(quoteId, userId string, names []string) ([]Tag, error)
+	RemoveQuoteTag(quoteId, tagId string) error
+}
+
+// taggedTable describes a join table between tags and a taggable entity.
+type taggedTable struct {
+	name   string
+	column string
+}
+
+var (
+	bookTagsTable  = taggedTable{name: "book_tags", column: "book_id"}
+	wishTagsTable  = taggedTable{name: "wish_tags", column: "wish_id"}
+	quoteTagsTable = taggedTable{name: "quote_tags", column: "quote_id"}
+)
+
+type PostgresTagStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresTagStore(db *pgxpool.Pool) *PostgresTagStore {
+	return &PostgresTagStore{db}
+}
+
+const tagColumns = `
+	t.id, t.user_id, t.name,
+	(
+		(SELECT COUNT(*) FROM book_tags bt JOIN books b ON b.id = bt.book_id WHERE bt.tag_id = t.id AND b.deleted_at IS NULL) +
+		(SELECT COUNT(*) FROM wish_tags wt JOIN wishlists w ON w.id = wt.wish_id WHERE wt.tag_id = t.id AND w.deleted_at IS NULL) +
+		(SELECT COUNT(*) FROM quote_tags qt JOIN quotes q ON q.id = qt.quote_id JOIN books b ON b.id = q.book_id WHERE qt.tag_id = t.id AND b.deleted_at IS NULL)
+	)::INTEGER AS usage_count,
+	t.created_at, t.updated_at
+`
+
+// tagsCondition returns the SQL condition matching the rows of the entity
+// aliased as alias tagged with the given names, using placeholder for the
+// names array and count for the number of distinct names.
+func tagsCondition(table taggedTable, alias, mode, placeholder, count string) string {
+	if mode == TagModeAll {
+		return fmt.Sprintf(
+			"(SELECT COUNT(DISTINCT t.name) FROM %s x JOIN tags t ON t.id = x.tag_id WHERE x.%s = %s.id AND t.name = ANY(%s)) = %s",
+			table.name, table.column, alias, placeholder, count,
+		)
+	}
+
+	return fmt.Sprintf(
+		"EXISTS (SELECT 1 FROM %s x JOIN tags t ON t.id = x.tag_id WHERE x.%s = %s.id AND t.name = ANY(%s))",
+		table.name, table.column, alias, placeholder,
+	)
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (s *PostgresTagStore) GetTags(userId, prefix string, limit int) ([]Tag, error) {
+	query := "SELECT " + tagColumns + `
+		FROM tags t
+		WHERE t.user_id = $1 AND t.name LIKE $2 || '%'
+		ORDER BY usage_count DESC, t.name
+		LIMIT $3
+	`
+
+	rows, err := s.db.Query(context.Background(), query, userId, escapeLike(prefix), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := pgx.CollectRows(rows, pgx.RowToStructByName[Tag])
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (s *PostgresTagStore) GetTagById(id string) (*Tag, error) {
+	query := "SELECT " + tagColumns + " FROM tags t WHERE t.id = $1"
+
+	rows, _ := s.db.Query(context.Background(), query, id)
+	tag, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[Tag])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+func (s *PostgresTagStore) RenameTag(tag *Tag) error {
+	query := `
+		UPDATE tags
+		SET name = $1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING updated_at
+	`
+
+	err := s.db.QueryRow(context.Background(), query, tag.Name, tag.ID).Scan(&tag.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// MergeTags moves every book, wish and quote tagged with one of the sources to the
+// target tag, then deletes the sources.
+func (s *PostgresTagStore) MergeTags(targetId string, sourceIds []string) error {
+	ctx := context.Background()
+
+	trx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer trx.Rollback(ctx)
+
+	for _, table := range []taggedTable{bookTagsTable, wishTagsTable, quoteTagsTable} {
+		query := fmt.Sprintf(`
+			INSERT INTO %[1]s (%[2]s, tag_id)
+			SELECT %[2]s, $1 FROM %[1]s WHERE tag_id = ANY($2::UUID[])
+			ON CONFLICT DO NOTHING
+		`, table.name, table.column)
+
+		if _, err := trx.Exec(ctx, query, targetId, sourceIds); err != nil {
+			return err
+		}
+	}
+
+	deleteQuery := "DELETE FROM tags WHERE id = ANY($1::UUID[]) AND id <> $2"
+	if _, err := trx.Exec(ctx, deleteQuery, sourceIds, targetId); err != nil {
+		return err
+	}
+
+	updateQuery := "UPDATE tags SET updated_at = NOW() WHERE id = $1"
+	if _, err := trx.Exec(ctx, updateQuery, targetId); err != nil {
+		return err
+	}
+
+	return trx.Commit(ctx)
+}
+
+func (s *PostgresTagStore) DeleteTag(id string) error {
+	query := "DELETE FROM tags WHERE id = $1"
+
+	commandTag, err := s.db.Exec(context.Background(), query, id)
+	if err != nil {
+		return err
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (s *PostgresTagStore) GetBookTags(bookId string) ([]Tag, error) {
+	return s.getTagsOf(bookTagsTable, bookId)
+}
+
+func (s *PostgresTagStore) AddBookTags(bookId, userId string, names []string) ([]Tag, error) {
+	return s.addTagsTo(bookTagsTable, bookId, userId, names)
+}
+
+func (s *PostgresTagStore) RemoveBookTag(bookId, tagId string) error {
+	return s.removeTagFrom(bookTagsTable, bookId, tagId)
+}
+
+func (s *PostgresTagStore) GetWishTags(wishId string) ([]Tag, error) {
+	return s.getTagsOf(wishTagsTable, wishId)
+}
+
+func (s *PostgresTagStore) AddWishTags(wishId, userId string, names []string) ([]Tag, error) {
+	return s.addTagsTo(wishTagsTable, wishId, userId, names)
+}
+
+func (s *PostgresTagStore) RemoveWishTag(wishId, tagId string) error {
+	return s.removeTagFrom(wishTagsTable, wishId, tagId)
+}
+
+func (s *PostgresTagStore) GetQuoteTags(quoteId string) ([]Tag, error) {
+	return s.getTagsOf(quoteTagsTable, quoteId)
+}
+
+func (s *PostgresTagStore) AddQuoteTags(quoteId, userId string, names []string) ([]Tag, error) {
+	return s.addTagsTo(quoteTagsTable, quoteId, userId, names)
+}
+
+func (s *PostgresTagStore) RemoveQuoteTag(quoteId, tagId string) error {
+	return s.removeTagFrom(quoteTagsTable, quoteId, tagId)
+}
+
+func (s *PostgresTagStore) getTagsOf(table taggedTable, id string) ([]Tag, error) {
+	query := fmt.Sprintf(
+		"SELECT %s FROM tags t JOIN %s x ON x.tag_id = t.id WHERE x.%s = $1 ORDER BY t.name",
+		tagColumns, table.name, table.column,
+	)
+
+	rows, err := s.db.Query(context.Background(), query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := pgx.CollectRows(rows, pgx.RowToStructByName[Tag])
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// addTagsTo creates the missing tags of the user and attaches all of them to
+// the entity. It returns every tag of the entity once done.
+func (s *PostgresTagStore) addTagsTo(table taggedTable, id, userId string, names []string) ([]Tag, error) {
+	ctx := context.Background()
+
+	trx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer trx.Rollback(ctx)
+
+	tagIds, err := upsertTags(ctx, trx, userId, names)
+	if err != nil {
+		return nil, err
+	}
+
+	attachQuery := fmt.Sprintf(`
+		INSERT INTO %s (%s, tag_id)
+		SELECT $1, UNNEST($2::UUID[])
+		ON CONFLICT DO NOTHING
+	`, table.name, table.column)
+
+	if _, err := trx.Exec(ctx, attachQuery, id, tagIds); err != nil {
+		return nil, err
+	}
+
+	if err := trx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.getTagsOf(table, id)
+}
+
+// upsertTags creates the missing tags of the user and returns the ids of all
+// the named tags.
+func upsertTags(ctx context.Context, q querier, userId string, names []string) ([]string, error) {
+	query := `
+		INSERT INTO tags (user_id, name)
+		SELECT $1, UNNEST($2::TEXT[])
+		ON CONFLICT (user_id, name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id::TEXT
+	`
+
+	rows, _ := q.Query(ctx, query, userId, names)
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+func (s *PostgresTagStore) removeTagFrom(table taggedTable, id, tagId string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = $1 AND tag_id = $2", table.name, table.column)
+
+	commandTag, err := s.db.Exec(context.Background(), query, id, tagId)
+	if err != nil {
+		return err
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
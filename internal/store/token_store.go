@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -25,6 +26,11 @@ type Token struct {
 	Scope     string    `json:"-"`
 }
 
+// ID identifies the token without revealing it, from the start of its hash.
+func (t *Token) ID() string {
+	return hex.EncodeToString(t.Hash[:8])
+}
+
 type TokenStore interface {
 	CreateToken(token *Token) error
 	RevokeAllTokens(userId, scope string) error
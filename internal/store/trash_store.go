@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	TrashItemBook = "book"
+	TrashItemWish = "wish"
+)
+
+// trashTables maps the types of trashed items to their table.
+var trashTables = map[string]string{
+	TrashItemBook: "books",
+	TrashItemWish: "wishlists",
+}
+
+// TrashItem is a deleted book or wish, kept until it is restored or purged.
+type TrashItem struct {
+	Type      string    `json:"type" db:"type"`
+	ID        string    `json:"id" db:"id"`
+	UserID    string    `json:"-" db:"user_id"`
+	Title     string    `json:"title" db:"title"`
+	Author    *string   `json:"author,omitempty" db:"author"`
+	Isbn      *string   `json:"isbn,omitempty" db:"isbn"`
+	DeletedAt time.Time `json:"deleted_at" db:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at" db:"-"`
+}
+
+type TrashStore interface {
+	GetTrash(userId string) ([]TrashItem, error)
+	GetTrashItem(itemType, id string) (*TrashItem, error)
+	GetExpiredTrash(deletedBefore time.Time, limit int) ([]TrashItem, error)
+	RestoreItem(itemType, id string) error
+	PurgeItem(itemType, id string) ([]string, error)
+}
+
+type PostgresTrashStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresTrashStore(db *pgxpool.Pool) *PostgresTrashStore {
+	return &PostgresTrashStore{db}
+}
+
+const trashQuery = `
+	SELECT 'book' AS type, id, user_id, title, author, isbn, deleted_at FROM books WHERE deleted_at IS NOT NULL
+	UNION ALL
+	SELECT 'wish' AS type, id, user_id, title, author, isbn, deleted_at FROM wishlists WHERE deleted_at IS NOT NULL
+`
+
+// GetTrash returns the trashed books and wishes of the user, the most
+// recently deleted first.
+func (s *PostgresTrashStore) GetTrash(userId string) ([]TrashItem, error) {
+	query := "SELECT * FROM (" + trashQuery + ") t WHERE t.user_id = $1 ORDER BY t.deleted_at DESC"
+
+	rows, err := s.db.Query(context.Background(), query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[TrashItem])
+}
+
+// GetTrashItem returns the trashed item, or nil when there is no such item
+// in the trash.
+func (s *PostgresTrashStore) GetTrashItem(itemType, id string) (*TrashItem, error) {
+	query := "SELECT * FROM (" + trashQuery + ") t WHERE t.type = $1 AND t.id = $2"
+
+	rows, _ := s.db.Query(context.Background(), query, itemType, id)
+	item, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[TrashItem])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// GetExpiredTrash returns the items of every user deleted before
+// deletedBefore, the oldest first.
+func (s *PostgresTrashStore) GetExpiredTrash(deletedBefore time.Time, limit int) ([]TrashItem, error) {
+	query := "SELECT * FROM (" + trashQuery + ") t WHERE t.deleted_at < $1 ORDER BY t.deleted_at LIMIT $2"
+
+	rows, err := s.db.Query(context.Background(), query, deletedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[TrashItem])
+}
+
+// RestoreItem takes the item out of the trash. It returns pgx.ErrNoRows when
+// the item is not in the trash, and a unique violation when the user added a
+// book with the same ISBN since.
+func (s *PostgresTrashStore) RestoreItem(itemType, id string) error {
+	table, ok := trashTables[itemType]
+	if !ok {
+		return fmt.Errorf("unknown trash item type %q", itemType)
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET deleted_at = NULL, updated_at = NOW() WHERE id = $1 AND deleted_at IS NOT NULL", table)
+	commandTag, err := s.db.Exec(context.Background(), query, id)
+	if err != nil {
+		return err
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// PurgeItem deletes the trashed item for good. For books, it returns the
+// hashes of their covers no other book uses anymore, whose files can be
+// deleted. It returns pgx.ErrNoRows when the item is not in the trash.
+func (s *PostgresTrashStore) PurgeItem(itemType, id string) ([]string, error) {
+	table, ok := trashTables[itemType]
+	if !ok {
+		return nil, fmt.Errorf("unknown trash item type %q", itemType)
+	}
+
+	ctx := context.Background()
+
+	trx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer trx.Rollback(ctx)
+
+	var hashes []string
+	if itemType == TrashItemBook {
+		// The covers are deleted first, so that the files they leave unused
+		// are known before the cascade removes them.
+		var trashedId string
+		err := trx.QueryRow(ctx, "SELECT id::TEXT FROM books WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE", id).Scan(&trashedId)
+		if err != nil {
+			return nil, err
+		}
+
+		hashes, err = deleteBookCovers(ctx, trx, id, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1 AND deleted_at IS NOT NULL", table)
+	commandTag, err := trx.Exec(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return nil, pgx.ErrNoRows
+	}
+
+	return hashes, trx.Commit(ctx)
+}
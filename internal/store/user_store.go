@@ -21,6 +21,7 @@ type User struct {
 	Email        string    `json:"email"`
 	PasswordHash password  `json:"-"`
 	Name         string    `json:"name"`
+	Timezone     string    `json:"timezone"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
@@ -57,6 +58,7 @@ type UserStore interface {
 	GetUserByEmail(email string) (*User, error)
 	GetUserByToken(token string) (*User, error)
 	UpdatePassword(user *User) error
+	UpdateUser(user *User) error
 }
 
 type PostgresUserStore struct {
@@ -79,13 +81,13 @@ func (s *PostgresUserStore) CreateUser(user *User) error {
 	userInsertQuery := `
 		INSERT INTO users (email, name)
 		VALUES ($1, $2)
-		RETURNING id, created_at, updated_at
+		RETURNING id, timezone, created_at, updated_at
 	`
 
 	err = s.db.QueryRow(
 		ctx, userInsertQuery, user.Email, user.Name,
 	).Scan(
-		&user.ID, &user.CreatedAt, &user.UpdatedAt,
+		&user.ID, &user.Timezone, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err != nil {
 		return err
@@ -115,7 +117,7 @@ func (s *PostgresUserStore) GetUserByEmail(email string) (*User, error) {
 	}
 
 	query := `
-		SELECT u.id, u.name, u.email, p.password_hash, u.created_at, u.updated_at
+		SELECT u.id, u.name, u.email, u.timezone, p.password_hash, u.created_at, u.updated_at
 		FROM users u
 		JOIN passwords p ON u.id = p.user_id
 		WHERE email = $1
@@ -125,6 +127,7 @@ func (s *PostgresUserStore) GetUserByEmail(email string) (*User, error) {
 		&user.ID,
 		&user.Name,
 		&user.Email,
+		&user.Timezone,
 		&user.PasswordHash.hash,
 		&user.CreatedAt,
 		&user.UpdatedAt,
@@ -147,7 +150,7 @@ func (s *PostgresUserStore) GetUserByToken(token string) (*User, error) {
 	}
 
 	query := `
-		SELECT u.id, u.name, u.email, u.created_at, u.updated_at, p.password_hash
+		SELECT u.id, u.name, u.email, u.timezone, u.created_at, u.updated_at, p.password_hash
 		FROM users u
 		JOIN passwords p ON u.id = p.user_id
 		JOIN tokens t ON u.id = t.user_id
@@ -160,6 +163,7 @@ func (s *PostgresUserStore) GetUserByToken(token string) (*User, error) {
 		&user.ID,
 		&user.Name,
 		&user.Email,
+		&user.Timezone,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.PasswordHash.hash,
@@ -190,3 +194,19 @@ func (s *PostgresUserStore) UpdatePassword(user *User) error {
 
 	return nil
 }
+
+func (s *PostgresUserStore) UpdateUser(user *User) error {
+	query := `
+		UPDATE users
+		SET name = $1, timezone = $2, updated_at = NOW()
+		WHERE id = $3
+		RETURNING updated_at
+	`
+
+	err := s.db.QueryRow(context.Background(), query, user.Name, user.Timezone, user.ID).Scan(&user.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
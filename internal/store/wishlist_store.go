@@ -2,6 +2,9 @@ package store
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -9,26 +12,54 @@ import (
 )
 
 type Wish struct {
-	ID        string    `json:"id" db:"id"`
-	UserID    string    `json:"user_id" db:"user_id"`
-	Title     string    `json:"title" db:"title"`
-	Author    *string   `json:"author,omitempty" db:"author"`
-	Isbn      *string   `json:"isbn,omitempty" db:"isbn"`
-	BigBookID *int64    `json:"bb_id,omitempty" db:"big_book_id"`
-	Priority  string    `json:"priority" db:"priority"`
-	Acquired  bool      `json:"acquired" db:"acquired"`
-	Notes     *string   `json:"notes" db:"notes"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID                string            `json:"id" db:"id"`
+	UserID            string            `json:"user_id" db:"user_id"`
+	Title             string            `json:"title" db:"title"`
+	Author            *string           `json:"author,omitempty" db:"author"`
+	Isbn              *string           `json:"isbn,omitempty" db:"isbn"`
+	BigBookID         *int64            `json:"bb_id,omitempty" db:"big_book_id"`
+	Priority          string            `json:"priority" db:"priority"`
+	Acquired          bool              `json:"acquired" db:"acquired"`
+	Notes             *string           `json:"notes" db:"notes"`
+	CreatedAt         time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at" db:"updated_at"`
+	SeriesID          *string           `json:"series_id,omitempty" db:"series_id"`
+	SeriesPosition    *float64          `json:"series_position,omitempty" db:"series_position"`
+	CoverUrl          *string           `json:"cover_url,omitempty" db:"cover_url"`
+	MetadataSources   map[string]string `json:"metadata_sources" db:"metadata_sources"`
+	MetadataCheckedAt *time.Time        `json:"metadata_checked_at,omitempty" db:"metadata_checked_at"`
+	DeletedAt         *time.Time        `json:"deleted_at,omitempty" db:"deleted_at"`
+	Version           int               `json:"version" db:"version"`
+}
+
+type WishFilters struct {
+	Tags    []string
+	TagMode string
+}
+
+func (f *WishFilters) query(userId string) *filterQuery {
+	q := &filterQuery{}
+	q.conditions = append(q.conditions, "w.user_id = "+q.arg(userId), "w.acquired = FALSE", "w.deleted_at IS NULL")
+
+	if len(f.Tags) > 0 {
+		q.conditions = append(q.conditions, tagsCondition(wishTagsTable, "w", f.TagMode, q.arg(f.Tags), q.arg(len(f.Tags))))
+	}
+
+	q.orderBy = append(q.orderBy, "w.created_at DESC")
+
+	return q
 }
 
 type WishlistStore interface {
 	AddWish(wish *Wish) error
 	GetWishById(id string) (*Wish, error)
-	GetWishes(userId string, page, take int) ([]Wish, error)
+	GetWishes(userId string, filters WishFilters, page, take int) ([]Wish, error)
+	GetUserWishes(userId string) ([]Wish, error)
+	GetAllUserWishes(userId string) ([]Wish, error)
+	FindWishes(userId string, isbns []string, bigBookIds []int64) ([]Wish, error)
 	DeleteWishById(id string) error
-	MarkAsAcquired(id string) error
-	GetWishesCount(userId string) (int, error)
+	MarkAsAcquired(id string, version int) error
+	GetWishesCount(userId string, filters WishFilters) (int, error)
 }
 
 type PostgresWishlistStore struct {
@@ -42,9 +73,9 @@ func NewPostgresWishlistStore(db *pgxpool.Pool) *PostgresWishlistStore {
 func (s *PostgresWishlistStore) AddWish(wish *Wish) error {
 
 	query := `
-		INSERT INTO wishlists (user_id, title, author, isbn, big_book_id, priority, notes)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, created_at
+		INSERT INTO wishlists (user_id, title, author, isbn, big_book_id, priority, notes, series_id, series_position, cover_url, metadata_sources)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, COALESCE($11, '{}'::JSONB))
+		RETURNING id, created_at, updated_at, version
 	`
 
 	err := s.db.QueryRow(context.Background(),
@@ -56,7 +87,11 @@ func (s *PostgresWishlistStore) AddWish(wish *Wish) error {
 		wish.BigBookID,
 		wish.Priority,
 		wish.Notes,
-	).Scan(&wish.ID, &wish.CreatedAt)
+		wish.SeriesID,
+		wish.SeriesPosition,
+		wish.CoverUrl,
+		wish.MetadataSources,
+	).Scan(&wish.ID, &wish.CreatedAt, &wish.UpdatedAt, &wish.Version)
 	if err != nil {
 		return err
 	}
@@ -65,7 +100,7 @@ func (s *PostgresWishlistStore) AddWish(wish *Wish) error {
 }
 
 func (s *PostgresWishlistStore) GetWishById(id string) (*Wish, error) {
-	query := `SELECT * FROM wishlists WHERE id = $1`
+	query := `SELECT * FROM wishlists WHERE id = $1 AND deleted_at IS NULL`
 
 	rows, err := s.db.Query(context.Background(), query, id)
 	if err != nil {
@@ -73,6 +108,10 @@ func (s *PostgresWishlistStore) GetWishById(id string) (*Wish, error) {
 	}
 
 	wish, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[Wish])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -80,8 +119,10 @@ func (s *PostgresWishlistStore) GetWishById(id string) (*Wish, error) {
 	return wish, nil
 }
 
+// DeleteWishById moves the wish to the trash, from which it can be restored
+// until it is purged.
 func (s *PostgresWishlistStore) DeleteWishById(id string) error {
-	query := `DELETE FROM wishlists WHERE id = $1`
+	query := `UPDATE wishlists SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
 
 	_, err := s.db.Exec(context.Background(), query, id)
 
@@ -92,16 +133,39 @@ func (s *PostgresWishlistStore) DeleteWishById(id string) error {
 	return nil
 }
 
-func (s *PostgresWishlistStore) GetWishes(userId string, page, take int) ([]Wish, error) {
-	query := `
-		SELECT * 
-		FROM wishlists 
-		WHERE user_id = $1 AND acquired = FALSE
-		ORDER BY created_at DESC 
-		LIMIT $2 OFFSET $3`
+func (s *PostgresWishlistStore) GetWishes(userId string, filters WishFilters, page, take int) ([]Wish, error) {
+	q := filters.query(userId)
 	offset := (page - 1) * take
+	query := fmt.Sprintf(`
+		SELECT w.*
+		FROM wishlists w
+		WHERE %s
+		ORDER BY %s
+		LIMIT %s OFFSET %s`, q.where(), strings.Join(q.orderBy, ", "), q.arg(take), q.arg(offset))
+
+	rows, err := s.db.Query(context.Background(), query, q.args...)
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := s.db.Query(context.Background(), query, userId, take, offset)
+	wishes, err := pgx.CollectRows(rows, pgx.RowToStructByName[Wish])
+	if err != nil {
+		return nil, err
+	}
+
+	return wishes, nil
+}
+
+// GetAllUserWishes returns every wish of the user, the acquired ones
+// included, unlike GetUserWishes.
+func (s *PostgresWishlistStore) GetAllUserWishes(userId string) ([]Wish, error) {
+	query := `
+		SELECT *
+		FROM wishlists
+		WHERE user_id = $1 AND deleted_at IS NULL
+		ORDER BY created_at DESC`
+
+	rows, err := s.db.Query(context.Background(), query, userId)
 	if err != nil {
 		return nil, err
 	}
@@ -114,11 +178,53 @@ func (s *PostgresWishlistStore) GetWishes(userId string, page, take int) ([]Wish
 	return wishes, nil
 }
 
-func (s *PostgresWishlistStore) GetWishesCount(userId string) (int, error) {
-	query := "SELECT COUNT(*) FROM wishlists WHERE user_id = $1 AND acquired = FALSE"
+func (s *PostgresWishlistStore) GetUserWishes(userId string) ([]Wish, error) {
+	query := `
+		SELECT *
+		FROM wishlists
+		WHERE user_id = $1 AND acquired = FALSE AND deleted_at IS NULL
+		ORDER BY created_at DESC`
+
+	rows, err := s.db.Query(context.Background(), query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	wishes, err := pgx.CollectRows(rows, pgx.RowToStructByName[Wish])
+	if err != nil {
+		return nil, err
+	}
+
+	return wishes, nil
+}
+
+// FindWishes returns the wishes of the user not acquired yet that have one
+// of the ISBNs or Big Book ids.
+func (s *PostgresWishlistStore) FindWishes(userId string, isbns []string, bigBookIds []int64) ([]Wish, error) {
+	query := `
+		SELECT *
+		FROM wishlists
+		WHERE user_id = $1 AND acquired = FALSE AND deleted_at IS NULL AND (isbn = ANY($2) OR big_book_id = ANY($3))`
+
+	rows, err := s.db.Query(context.Background(), query, userId, isbns, bigBookIds)
+	if err != nil {
+		return nil, err
+	}
+
+	wishes, err := pgx.CollectRows(rows, pgx.RowToStructByName[Wish])
+	if err != nil {
+		return nil, err
+	}
+
+	return wishes, nil
+}
+
+func (s *PostgresWishlistStore) GetWishesCount(userId string, filters WishFilters) (int, error) {
+	q := filters.query(userId)
+	query := "SELECT COUNT(*) FROM wishlists w WHERE " + q.where()
 
 	var count int
-	err := s.db.QueryRow(context.Background(), query, userId).Scan(&count)
+	err := s.db.QueryRow(context.Background(), query, q.args...).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
@@ -126,7 +232,10 @@ func (s *PostgresWishlistStore) GetWishesCount(userId string) (int, error) {
 	return count, nil
 }
 
-func (s *PostgresWishlistStore) MarkAsAcquired(id string) error {
+// MarkAsAcquired marks the wish as acquired and adds its book to the library.
+// It returns pgx.ErrNoRows when the wish was deleted and ErrVersionConflict
+// when it is no longer at version.
+func (s *PostgresWishlistStore) MarkAsAcquired(id string, version int) error {
 	ctx := context.Background()
 
 	trx, err := s.db.Begin(ctx)
@@ -136,6 +245,16 @@ func (s *PostgresWishlistStore) MarkAsAcquired(id string) error {
 
 	defer trx.Rollback(ctx)
 
+	var current int
+	err = trx.QueryRow(ctx, "SELECT version FROM wishlists WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id).Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	if current != version {
+		return ErrVersionConflict
+	}
+
 	query := `
 		UPDATE wishlists 
 		SET acquired = TRUE, updated_at = NOW()
@@ -158,15 +277,34 @@ func (s *PostgresWishlistStore) MarkAsAcquired(id string) error {
 	}
 
 	insertBookQuery := `
-		INSERT INTO books (user_id, title, author, isbn, notes, rating)
-		VALUES ($1, $2, $3, $4, $5, 1)
+		INSERT INTO books (user_id, title, author, isbn, notes, rating, series_id, series_position, wish_id, big_book_id, cover_url, metadata_sources)
+		VALUES ($1, $2, $3, $4, $5, 0, $6, $7, $8, $9, $10, $11)
+		RETURNING id
 	`
 
-	_, err = trx.Exec(ctx, insertBookQuery, wish.UserID, wish.Title, wish.Author, wish.Isbn, wish.Notes)
+	// The book keeps the provenance of the cover filled for the wish.
+	sources := make(map[string]string)
+	if source, ok := wish.MetadataSources["cover_url"]; ok {
+		sources["cover_url"] = source
+	}
+
+	var bookId string
+	err = trx.QueryRow(ctx, insertBookQuery, wish.UserID, wish.Title, wish.Author, wish.Isbn, wish.Notes, wish.SeriesID, wish.SeriesPosition, wish.ID, wish.BigBookID, wish.CoverUrl, sources).Scan(&bookId)
 	if err != nil {
 		return err
 	}
 
+	if wish.Author != nil {
+		authors := []BookAuthor{{Name: *wish.Author, Role: AuthorRoleAuthor}}
+		if err := setBookAuthors(ctx, trx, bookId, authors); err != nil {
+			return err
+		}
+	}
+
+	if err := recordStatusChange(ctx, trx, bookId, wish.UserID, nil, BookStatusToRead); err != nil {
+		return err
+	}
+
 	err = trx.Commit(ctx)
 	if err != nil {
 		return err
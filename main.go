@@ -1,15 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/joho/godotenv"
 	"github.com/martialanouman/personal-library/internal/app"
 	"github.com/martialanouman/personal-library/internal/routes"
 )
 
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	err := godotenv.Load()
 	if err != nil {
@@ -23,6 +32,28 @@ func main() {
 	}
 	defer app.Db.Close()
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var workers sync.WaitGroup
+	workers.Add(4)
+	go func() {
+		defer workers.Done()
+		app.LoanReminder.Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		app.EnrichmentWorker.Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		app.CoverFetcher.Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		app.TrashPurger.Run(ctx)
+	}()
+
 	r := routes.SetupRoutes(app)
 
 	server := http.Server{
@@ -33,9 +64,34 @@ func main() {
 		WriteTimeout: 30 * time.Second,
 	}
 
-	app.Logger.Printf("server running on :3000")
+	go func() {
+		app.Logger.Printf("server running on :3000")
+
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			app.Logger.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	app.Logger.Printf("shutting down")
+
+	// Let in-flight requests finish and the workers put their jobs back.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		app.Logger.Printf("ERROR: shutting down server %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
 
-	if err := server.ListenAndServe(); err != nil {
-		app.Logger.Fatal(err)
+	select {
+	case <-done:
+	case <-shutdownCtx.Done():
+		app.Logger.Printf("WARNING: background workers did not stop in time")
 	}
 }
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tags (
+    id UUID DEFAULT UUIDV7() PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name)
+);
+CREATE INDEX IF NOT EXISTS tags_user_id_name_prefix_idx ON tags (user_id, name text_pattern_ops);
+CREATE TABLE IF NOT EXISTS book_tags (
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (book_id, tag_id)
+);
+CREATE INDEX IF NOT EXISTS book_tags_tag_id_idx ON book_tags (tag_id);
+CREATE TABLE IF NOT EXISTS wish_tags (
+    wish_id UUID NOT NULL REFERENCES wishlists(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (wish_id, tag_id)
+);
+CREATE INDEX IF NOT EXISTS wish_tags_tag_id_idx ON wish_tags (tag_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS wish_tags;
+DROP TABLE IF EXISTS book_tags;
+DROP TABLE IF EXISTS tags;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS series (
+    id UUID DEFAULT UUIDV7() PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    total_volumes INTEGER CHECK (total_volumes > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name)
+);
+ALTER TABLE books
+    ADD COLUMN IF NOT EXISTS series_id UUID REFERENCES series(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS series_position NUMERIC(6, 2) CHECK (series_position > 0);
+ALTER TABLE wishlists
+    ADD COLUMN IF NOT EXISTS series_id UUID REFERENCES series(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS series_position NUMERIC(6, 2) CHECK (series_position > 0);
+CREATE INDEX IF NOT EXISTS books_series_id_idx ON books (series_id);
+CREATE INDEX IF NOT EXISTS wishlists_series_id_idx ON wishlists (series_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE wishlists DROP COLUMN IF EXISTS series_position, DROP COLUMN IF EXISTS series_id;
+ALTER TABLE books DROP COLUMN IF EXISTS series_position, DROP COLUMN IF EXISTS series_id;
+DROP TABLE IF EXISTS series;
+-- +goose StatementEnd
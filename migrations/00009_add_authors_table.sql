@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS unaccent;
+CREATE TYPE AUTHOR_ROLE AS ENUM ('author', 'translator', 'illustrator', 'editor');
+CREATE TABLE IF NOT EXISTS authors (
+    id UUID DEFAULT UUIDV7() PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    normalized_name VARCHAR(255) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS book_authors (
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    author_id UUID NOT NULL REFERENCES authors(id) ON DELETE CASCADE,
+    role AUTHOR_ROLE NOT NULL DEFAULT 'author',
+    position INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (book_id, author_id, role)
+);
+CREATE INDEX IF NOT EXISTS book_authors_author_id_idx ON book_authors (author_id);
+COMMENT ON COLUMN books.author IS 'Display name of the authors, the source of truth is book_authors';
+
+-- Gives the same keys as store.NormalizeAuthorName so that the backfill deduplicates authors
+-- the same way the application does.
+CREATE OR REPLACE FUNCTION normalize_author_name(name TEXT) RETURNS TEXT AS $$
+    SELECT TRIM(REGEXP_REPLACE(LOWER(unaccent(name)), '[^[:alnum:]]+', ' ', 'g'))
+$$ LANGUAGE SQL STABLE;
+
+INSERT INTO authors (name, normalized_name)
+SELECT DISTINCT ON (normalize_author_name(author)) TRIM(author), normalize_author_name(author)
+FROM books
+WHERE normalize_author_name(author) <> ''
+ORDER BY normalize_author_name(author), created_at
+ON CONFLICT (normalized_name) DO NOTHING;
+
+INSERT INTO book_authors (book_id, author_id, role, position)
+SELECT b.id, a.id, 'author', 0
+FROM books b
+JOIN authors a ON a.normalized_name = normalize_author_name(b.author)
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP FUNCTION IF EXISTS normalize_author_name(TEXT);
+COMMENT ON COLUMN books.author IS NULL;
+DROP TABLE IF EXISTS book_authors;
+DROP TABLE IF EXISTS authors;
+DROP TYPE IF EXISTS AUTHOR_ROLE;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE books ADD COLUMN IF NOT EXISTS page_count INTEGER CHECK (page_count > 0);
+CREATE TABLE IF NOT EXISTS reading_sessions (
+    id UUID DEFAULT UUIDV7() PRIMARY KEY,
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ended_at TIMESTAMP WITH TIME ZONE,
+    start_page INTEGER CHECK (start_page >= 0),
+    end_page INTEGER CHECK (end_page >= 0),
+    start_percent NUMERIC(5, 2) CHECK (start_percent BETWEEN 0 AND 100),
+    end_percent NUMERIC(5, 2) CHECK (end_percent BETWEEN 0 AND 100),
+    notes TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (ended_at IS NULL OR ended_at >= started_at),
+    CHECK (start_page IS NULL OR end_page IS NULL OR end_page >= start_page),
+    CHECK (start_percent IS NULL OR end_percent IS NULL OR end_percent >= start_percent)
+);
+CREATE INDEX IF NOT EXISTS reading_sessions_book_id_started_at_idx ON reading_sessions (book_id, started_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS reading_sessions;
+ALTER TABLE books DROP COLUMN IF EXISTS page_count;
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS book_reads (
+    id UUID DEFAULT UUIDV7() PRIMARY KEY,
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    rating INTEGER CHECK (rating >= 1 AND rating <= 5),
+    review TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (started_at IS NULL OR finished_at IS NULL OR finished_at >= started_at)
+);
+CREATE INDEX IF NOT EXISTS book_reads_book_id_idx ON book_reads (book_id);
+
+-- The single pair of dates of each book becomes its first reading cycle. A
+-- finish date before the start date, which books allowed, is clamped to the
+-- start date so that the cycle stays finished.
+INSERT INTO book_reads (book_id, user_id, started_at, finished_at, rating)
+SELECT
+    id, user_id, date_started,
+    CASE WHEN date_finished < date_started THEN date_started ELSE date_finished END,
+    CASE WHEN status = 'read' THEN rating END
+FROM books
+WHERE user_id IS NOT NULL AND (status <> 'to_read' OR date_started IS NOT NULL OR date_finished IS NOT NULL);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS book_reads;
+-- +goose StatementEnd
@@ -0,0 +1,50 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+ALTER TABLE books ADD COLUMN IF NOT EXISTS wish_id UUID REFERENCES wishlists(id) ON DELETE SET NULL;
+COMMENT ON COLUMN books.wish_id IS 'Wish the book was acquired from, if any';
+
+-- Books acquired before wish_id existed are matched by title.
+UPDATE books b
+SET wish_id = w.id
+FROM wishlists w
+WHERE w.acquired = TRUE AND w.user_id = b.user_id AND LOWER(w.title) = LOWER(b.title) AND b.wish_id IS NULL;
+
+CREATE TYPE GOAL_METRIC AS ENUM ('books', 'pages', 'wishlist_books');
+CREATE TYPE GOAL_PERIOD AS ENUM ('year', 'quarter', 'month');
+CREATE TABLE IF NOT EXISTS goals (
+    id UUID DEFAULT UUIDV7() PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    metric GOAL_METRIC NOT NULL,
+    period GOAL_PERIOD NOT NULL,
+    year INTEGER NOT NULL,
+    period_index INTEGER NOT NULL DEFAULT 1,
+    target INTEGER NOT NULL CHECK (target > 0),
+    completed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, metric, period, year, period_index)
+);
+COMMENT ON COLUMN goals.period_index IS 'Month (1-12) or quarter (1-4) of the year, 1 for yearly goals';
+
+CREATE TABLE IF NOT EXISTS goal_events (
+    id UUID DEFAULT UUIDV7() PRIMARY KEY,
+    goal_id UUID NOT NULL REFERENCES goals(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(32) NOT NULL,
+    progress INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS goal_events_user_id_created_at_idx ON goal_events (user_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS goal_events;
+DROP TABLE IF EXISTS goals;
+DROP TYPE IF EXISTS GOAL_PERIOD;
+DROP TYPE IF EXISTS GOAL_METRIC;
+ALTER TABLE books DROP COLUMN IF EXISTS wish_id;
+ALTER TABLE users DROP COLUMN IF EXISTS timezone;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS book_status_history (
+    id UUID DEFAULT UUIDV7() PRIMARY KEY,
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    from_status BOOK_STATUS,
+    to_status BOOK_STATUS NOT NULL,
+    changed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS book_status_history_book_id_idx ON book_status_history (book_id, changed_at);
+
+-- Existing books start their history with their current status.
+INSERT INTO book_status_history (book_id, user_id, from_status, to_status, changed_at)
+SELECT id, user_id, NULL, status, COALESCE(date_finished, date_started, created_at)
+FROM books
+WHERE user_id IS NOT NULL AND status IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS book_status_history;
+-- +goose StatementEnd
@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE books DROP CONSTRAINT IF EXISTS books_rating_check;
+ALTER TABLE books ALTER COLUMN rating TYPE NUMERIC(2, 1) USING rating::NUMERIC(2, 1);
+ALTER TABLE books ADD CONSTRAINT books_rating_check CHECK (rating = 0 OR (rating >= 0.5 AND rating <= 5 AND rating * 2 = TRUNC(rating * 2)));
+COMMENT ON COLUMN books.rating IS 'Half stars from 0.5 to 5, 0 when the book is not rated';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS review TEXT;
+COMMENT ON COLUMN books.review IS 'Long-form review in Markdown, with :::spoiler sections';
+
+ALTER TABLE book_reads DROP CONSTRAINT IF EXISTS book_reads_rating_check;
+ALTER TABLE book_reads ALTER COLUMN rating TYPE NUMERIC(2, 1) USING rating::NUMERIC(2, 1);
+ALTER TABLE book_reads ADD CONSTRAINT book_reads_rating_check CHECK (rating >= 0.5 AND rating <= 5 AND rating * 2 = TRUNC(rating * 2));
+
+CREATE TABLE IF NOT EXISTS quotes (
+    id UUID DEFAULT UUIDV7() PRIMARY KEY,
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    text TEXT NOT NULL,
+    page INTEGER CHECK (page > 0),
+    note TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS quotes_book_id_idx ON quotes (book_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS quotes;
+
+ALTER TABLE book_reads DROP CONSTRAINT IF EXISTS book_reads_rating_check;
+ALTER TABLE book_reads ALTER COLUMN rating TYPE INTEGER USING ROUND(rating)::INTEGER;
+ALTER TABLE book_reads ADD CONSTRAINT book_reads_rating_check CHECK (rating >= 1 AND rating <= 5);
+
+ALTER TABLE books DROP COLUMN IF EXISTS review;
+ALTER TABLE books DROP CONSTRAINT IF EXISTS books_rating_check;
+ALTER TABLE books ALTER COLUMN rating TYPE INTEGER USING NULLIF(ROUND(rating), 0)::INTEGER;
+ALTER TABLE books ADD CONSTRAINT books_rating_check CHECK (rating >= 1 AND rating <= 5);
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS location VARCHAR(64);
+COMMENT ON COLUMN quotes.location IS 'Position in an e-book, like a Kindle location range';
+
+-- The same passage is saved only once per book, which makes imports idempotent.
+DELETE FROM quotes q
+USING quotes other
+WHERE q.book_id = other.book_id AND MD5(q.text) = MD5(other.text) AND q.id > other.id;
+CREATE UNIQUE INDEX IF NOT EXISTS quotes_book_id_text_idx ON quotes (book_id, MD5(text));
+CREATE INDEX IF NOT EXISTS quotes_user_id_idx ON quotes (user_id);
+
+-- Libraries mix languages, so quotes are searched without stemming.
+CREATE INDEX IF NOT EXISTS quotes_search_idx ON quotes USING GIN (TO_TSVECTOR('simple', text || ' ' || COALESCE(note, '')));
+
+CREATE TABLE IF NOT EXISTS quote_tags (
+    quote_id UUID NOT NULL REFERENCES quotes(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (quote_id, tag_id)
+);
+CREATE INDEX IF NOT EXISTS quote_tags_tag_id_idx ON quote_tags (tag_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS quote_tags;
+DROP INDEX IF EXISTS quotes_search_idx;
+DROP INDEX IF EXISTS quotes_user_id_idx;
+DROP INDEX IF EXISTS quotes_book_id_text_idx;
+ALTER TABLE quotes DROP COLUMN IF EXISTS location;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE LOAN_DIRECTION AS ENUM ('lent', 'borrowed');
+CREATE TABLE IF NOT EXISTS loans (
+    id UUID DEFAULT UUIDV7() PRIMARY KEY,
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    direction LOAN_DIRECTION NOT NULL,
+    counterparty_name VARCHAR(255) NOT NULL,
+    counterparty_contact VARCHAR(255),
+    loaned_at DATE NOT NULL DEFAULT CURRENT_DATE,
+    due_at DATE,
+    returned_at DATE,
+    reminded_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (due_at IS NULL OR due_at >= loaned_at),
+    CHECK (returned_at IS NULL OR returned_at >= loaned_at)
+);
+COMMENT ON COLUMN loans.counterparty_name IS 'Person the book was lent to or borrowed from';
+COMMENT ON COLUMN loans.reminded_at IS 'Last time the user was notified that the loan is overdue';
+
+-- A book can only be out once at a time.
+CREATE UNIQUE INDEX IF NOT EXISTS loans_book_id_open_idx ON loans (book_id) WHERE returned_at IS NULL;
+CREATE INDEX IF NOT EXISTS loans_user_id_idx ON loans (user_id);
+CREATE INDEX IF NOT EXISTS loans_due_at_open_idx ON loans (due_at) WHERE returned_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS loans;
+DROP TYPE IF EXISTS LOAN_DIRECTION;
+-- +goose StatementEnd
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+-- ISBNs identify editions, which several users can own.
+ALTER TABLE books DROP CONSTRAINT IF EXISTS books_isbn_key;
+CREATE UNIQUE INDEX IF NOT EXISTS books_user_id_isbn_idx ON books (user_id, isbn);
+
+CREATE TYPE COPY_FORMAT AS ENUM ('hardcover', 'paperback', 'ebook', 'audiobook');
+CREATE TABLE IF NOT EXISTS copies (
+    id UUID DEFAULT UUIDV7() PRIMARY KEY,
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    format COPY_FORMAT NOT NULL,
+    edition VARCHAR(255),
+    isbn VARCHAR(13),
+    purchased_at DATE,
+    price NUMERIC(10, 2) CHECK (price >= 0),
+    currency CHAR(3),
+    condition VARCHAR(16),
+    location VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (price IS NULL OR currency IS NOT NULL)
+);
+COMMENT ON COLUMN copies.currency IS 'ISO 4217 code of the price currency';
+COMMENT ON COLUMN copies.location IS 'Where the copy is kept, like a room or a shelf';
+
+CREATE INDEX IF NOT EXISTS copies_book_id_idx ON copies (book_id);
+CREATE INDEX IF NOT EXISTS copies_user_id_idx ON copies (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS copies;
+DROP TYPE IF EXISTS COPY_FORMAT;
+DROP INDEX IF EXISTS books_user_id_isbn_idx;
+ALTER TABLE books ADD CONSTRAINT books_isbn_key UNIQUE (isbn);
+-- +goose StatementEnd
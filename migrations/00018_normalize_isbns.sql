@@ -0,0 +1,65 @@
+-- +goose Up
+-- +goose StatementBegin
+-- isbn13 strips the separators of an ISBN and converts ISBN-10 to ISBN-13.
+-- Values that are neither an ISBN-13 nor a valid ISBN-10 are left unchanged.
+CREATE FUNCTION pg_temp.isbn13(value TEXT) RETURNS TEXT AS $$
+DECLARE
+    cleaned TEXT := UPPER(REGEXP_REPLACE(value, '[^0-9Xx]', '', 'g'));
+    body TEXT;
+    total INTEGER := 0;
+BEGIN
+    IF cleaned ~ '^[0-9]{13}$' THEN
+        RETURN cleaned;
+    END IF;
+
+    IF cleaned !~ '^[0-9]{9}[0-9X]$' THEN
+        RETURN value;
+    END IF;
+
+    -- The weights of an ISBN-10 go from 10 down to 1 and X stands for 10.
+    FOR i IN 1..9 LOOP
+        total := total + SUBSTRING(cleaned FROM i FOR 1)::INTEGER * (11 - i);
+    END LOOP;
+    total := total + CASE WHEN RIGHT(cleaned, 1) = 'X' THEN 10 ELSE RIGHT(cleaned, 1)::INTEGER END;
+
+    IF total % 11 <> 0 THEN
+        RETURN value;
+    END IF;
+
+    total := 0;
+    body := '978' || SUBSTRING(cleaned FROM 1 FOR 9);
+    FOR i IN 1..12 LOOP
+        total := total + SUBSTRING(body FROM i FOR 1)::INTEGER * CASE WHEN i % 2 = 1 THEN 1 ELSE 3 END;
+    END LOOP;
+
+    RETURN body || ((10 - total % 10) % 10)::TEXT;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+UPDATE books SET isbn = NULL WHERE TRIM(isbn) = '';
+UPDATE wishlists SET isbn = NULL WHERE TRIM(isbn) = '';
+UPDATE copies SET isbn = NULL WHERE TRIM(isbn) = '';
+
+-- A user may hold the same book under both its ISBN-10 and its ISBN-13: the
+-- second one keeps its ISBN as is rather than breaking the unique index.
+UPDATE books b
+SET isbn = n.isbn13
+FROM (
+    SELECT DISTINCT ON (user_id, pg_temp.isbn13(isbn)) id, pg_temp.isbn13(isbn) AS isbn13
+    FROM books
+    WHERE isbn IS NOT NULL
+    ORDER BY user_id, pg_temp.isbn13(isbn), created_at
+) n
+WHERE b.id = n.id
+    AND b.isbn <> n.isbn13
+    AND NOT EXISTS (SELECT 1 FROM books o WHERE o.user_id = b.user_id AND o.isbn = n.isbn13);
+
+UPDATE wishlists SET isbn = pg_temp.isbn13(isbn) WHERE isbn IS NOT NULL;
+UPDATE copies SET isbn = pg_temp.isbn13(isbn) WHERE isbn IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- ISBN-13 cannot be told apart from the ISBN-10 they were converted from.
+SELECT 1;
+-- +goose StatementEnd
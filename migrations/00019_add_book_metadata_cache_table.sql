@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS book_metadata_cache (
+    provider VARCHAR(50) NOT NULL,
+    external_id VARCHAR(255) NOT NULL,
+    payload JSONB,
+    not_found BOOLEAN NOT NULL DEFAULT FALSE,
+    fetched_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (provider, external_id),
+    CHECK (not_found OR payload IS NOT NULL)
+);
+COMMENT ON COLUMN book_metadata_cache.external_id IS 'Id of the book at the provider, or isbn:<ISBN-13> for ISBN lookups';
+COMMENT ON COLUMN book_metadata_cache.not_found IS 'The provider answered that it does not know the book';
+
+CREATE INDEX IF NOT EXISTS book_metadata_cache_expires_at_idx ON book_metadata_cache (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS book_metadata_cache;
+-- +goose StatementEnd
@@ -0,0 +1,51 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE books
+    ADD COLUMN IF NOT EXISTS big_book_id BIGINT,
+    ADD COLUMN IF NOT EXISTS metadata_sources JSONB NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS metadata_checked_at TIMESTAMP WITH TIME ZONE;
+COMMENT ON COLUMN books.metadata_sources IS 'Source of each enrichable field: user or the metadata provider that filled it';
+COMMENT ON COLUMN books.metadata_checked_at IS 'Last time the metadata providers were asked about the book';
+
+ALTER TABLE wishlists
+    ADD COLUMN IF NOT EXISTS cover_url TEXT,
+    ADD COLUMN IF NOT EXISTS metadata_sources JSONB NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS metadata_checked_at TIMESTAMP WITH TIME ZONE;
+COMMENT ON COLUMN wishlists.metadata_sources IS 'Source of each enrichable field: user or the metadata provider that filled it';
+COMMENT ON COLUMN wishlists.metadata_checked_at IS 'Last time the metadata providers were asked about the wish';
+
+CREATE TYPE ENRICHMENT_TARGET AS ENUM ('book', 'wish');
+CREATE TYPE JOB_STATUS AS ENUM ('pending', 'running', 'done', 'failed');
+CREATE TABLE IF NOT EXISTS enrichment_jobs (
+    id UUID DEFAULT UUIDV7() PRIMARY KEY,
+    target_type ENRICHMENT_TARGET NOT NULL,
+    target_id UUID NOT NULL,
+    status JOB_STATUS NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+COMMENT ON COLUMN enrichment_jobs.target_id IS 'Id of the book or wish to enrich';
+COMMENT ON COLUMN enrichment_jobs.run_at IS 'The job is not run before, which delays retries';
+
+-- A target is only queued once at a time.
+CREATE UNIQUE INDEX IF NOT EXISTS enrichment_jobs_target_active_idx ON enrichment_jobs (target_type, target_id) WHERE status IN ('pending', 'running');
+CREATE INDEX IF NOT EXISTS enrichment_jobs_run_at_pending_idx ON enrichment_jobs (run_at) WHERE status = 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS enrichment_jobs;
+DROP TYPE IF EXISTS JOB_STATUS;
+DROP TYPE IF EXISTS ENRICHMENT_TARGET;
+ALTER TABLE wishlists
+    DROP COLUMN IF EXISTS metadata_checked_at,
+    DROP COLUMN IF EXISTS metadata_sources,
+    DROP COLUMN IF EXISTS cover_url;
+ALTER TABLE books
+    DROP COLUMN IF EXISTS metadata_checked_at,
+    DROP COLUMN IF EXISTS metadata_sources,
+    DROP COLUMN IF EXISTS big_book_id;
+-- +goose StatementEnd
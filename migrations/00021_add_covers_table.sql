@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS covers (
+    id UUID DEFAULT UUIDV7() PRIMARY KEY,
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source_url TEXT,
+    hash CHAR(64) NOT NULL,
+    content_type VARCHAR(50) NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+COMMENT ON COLUMN covers.source_url IS 'URL the cover was downloaded from, NULL for uploaded covers';
+COMMENT ON COLUMN covers.hash IS 'SHA-256 of the original image, under which its files are stored';
+
+CREATE INDEX IF NOT EXISTS covers_book_id_idx ON covers (book_id);
+
+ALTER TABLE books
+    ALTER COLUMN cover_url TYPE TEXT,
+    ADD COLUMN IF NOT EXISTS cover_id UUID REFERENCES covers(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS cover_fetched_at TIMESTAMP WITH TIME ZONE;
+COMMENT ON COLUMN books.cover_fetched_at IS 'Last time cover_url was downloaded, successfully or not';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE books
+    DROP COLUMN IF EXISTS cover_fetched_at,
+    DROP COLUMN IF EXISTS cover_id;
+DROP TABLE IF EXISTS covers;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Deleted books and wishes stay in the trash until restored or purged.
+ALTER TABLE books ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE wishlists ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- A trashed book must not keep its ISBN from being added again.
+DROP INDEX IF EXISTS books_user_id_isbn_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS books_user_id_isbn_idx ON books (user_id, isbn) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS books_deleted_at_idx ON books (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS wishlists_deleted_at_idx ON wishlists (deleted_at) WHERE deleted_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM books WHERE deleted_at IS NOT NULL;
+DELETE FROM wishlists WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS wishlists_deleted_at_idx;
+DROP INDEX IF EXISTS books_deleted_at_idx;
+DROP INDEX IF EXISTS books_user_id_isbn_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS books_user_id_isbn_idx ON books (user_id, isbn);
+ALTER TABLE wishlists DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE books DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS book_revisions (
+    id UUID DEFAULT UUIDV7() PRIMARY KEY,
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_id VARCHAR(16),
+    changes JSONB NOT NULL,
+    reverted_revision INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (book_id, revision)
+);
+COMMENT ON COLUMN book_revisions.token_id IS 'Prefix of the hash of the token the change was made with';
+COMMENT ON COLUMN book_revisions.changes IS 'Changed fields with their value before and after the change';
+COMMENT ON COLUMN book_revisions.reverted_revision IS 'Revision the change reverted the book to the state before';
+
+-- Revisions are only ever added; they go away with their book.
+CREATE OR REPLACE FUNCTION book_revisions_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'book revisions cannot be modified';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER book_revisions_append_only
+    BEFORE UPDATE ON book_revisions
+    FOR EACH ROW EXECUTE FUNCTION book_revisions_append_only();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS book_revisions;
+DROP FUNCTION IF EXISTS book_revisions_append_only();
+-- +goose StatementEnd
@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The version of a row goes up with each of its updates and makes its ETag.
+-- Updates only touching bookkeeping columns, such as the last time the
+-- metadata providers were asked, leave it as is.
+ALTER TABLE books ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE wishlists ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION bump_version() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER books_bump_version
+    BEFORE UPDATE ON books
+    FOR EACH ROW
+    WHEN (TO_JSONB(OLD) - '{updated_at,metadata_checked_at,cover_fetched_at}'::TEXT[]
+        IS DISTINCT FROM TO_JSONB(NEW) - '{updated_at,metadata_checked_at,cover_fetched_at}'::TEXT[])
+    EXECUTE FUNCTION bump_version();
+
+CREATE TRIGGER wishlists_bump_version
+    BEFORE UPDATE ON wishlists
+    FOR EACH ROW
+    WHEN (TO_JSONB(OLD) - '{updated_at,metadata_checked_at}'::TEXT[]
+        IS DISTINCT FROM TO_JSONB(NEW) - '{updated_at,metadata_checked_at}'::TEXT[])
+    EXECUTE FUNCTION bump_version();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS wishlists_bump_version ON wishlists;
+DROP TRIGGER IF EXISTS books_bump_version ON books;
+DROP FUNCTION IF EXISTS bump_version();
+ALTER TABLE wishlists DROP COLUMN IF EXISTS version;
+ALTER TABLE books DROP COLUMN IF EXISTS version;
+-- +goose StatementEnd